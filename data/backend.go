@@ -0,0 +1,211 @@
+package data
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// Backend is the storage a Pager reads and writes pages through. *os.File
+// is the default implementation. A backend reports its size through a
+// Size() (int64, error) or Stat() (os.FileInfo, error) method when it has
+// one, otherwise the size is found by probing reads.
+type Backend interface {
+	io.ReaderAt
+	io.WriterAt
+
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+var errMmapUnsupported = errors.New("mmap unsupported")
+
+// file returns the file under the backend, if there is one.
+func (pgr *Pager) file() (*os.File, bool) {
+	switch store := pgr.store.(type) {
+	case *os.File:
+		return store, true
+	case *mmapBackend:
+		return store.osFile(), true
+	default:
+		return nil, false
+	}
+}
+
+func (pgr *Pager) storeSize() (int64, error) {
+	return backendSize(pgr.store)
+}
+
+func backendSize(be Backend) (int64, error) {
+	switch store := be.(type) {
+	case interface{ Size() (int64, error) }:
+		return store.Size()
+	case interface{ Stat() (os.FileInfo, error) }:
+		info, err := store.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	default:
+		return probeSize(store)
+	}
+}
+
+// probeSize finds the size of r by searching for the first offset where
+// a one byte read hits io.EOF.
+func probeSize(r io.ReaderAt) (int64, error) {
+	var b [1]byte
+
+	readable := func(off int64) (bool, error) {
+		if _, err := r.ReadAt(b[:], off); err != nil {
+			if errors.Is(err, io.EOF) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	lo, hi := int64(0), int64(1)
+	for {
+		ok, err := readable(hi - 1)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		lo, hi = hi, hi*2
+	}
+
+	// Offset lo-1 is readable and hi-1 is not, so the size is in
+	// [lo, hi-1].
+	for last := hi - 1; lo < last; {
+		mid := lo + (last-lo+1)/2
+		ok, err := readable(mid - 1)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			last = mid - 1
+		}
+	}
+
+	return lo, nil
+}
+
+// memBackend keeps the whole store in a growable byte slice. It behaves
+// like a file: reads past the end return io.EOF and writes past the end
+// grow the store, filling the gap with zeros.
+type memBackend struct {
+	mu     sync.RWMutex
+	b      []byte
+	closed bool
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{}
+}
+
+func (be *memBackend) ReadAt(b []byte, off int64) (int, error) {
+	be.mu.RLock()
+	defer be.mu.RUnlock()
+
+	if be.closed {
+		return 0, os.ErrClosed
+	}
+
+	if off >= int64(len(be.b)) {
+		return 0, io.EOF
+	}
+
+	n := copy(b, be.b[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (be *memBackend) WriteAt(b []byte, off int64) (int, error) {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+
+	if be.closed {
+		return 0, os.ErrClosed
+	}
+
+	if end := off + int64(len(b)); end > int64(len(be.b)) {
+		be.grow(end)
+	}
+
+	return copy(be.b[off:], b), nil
+}
+
+func (be *memBackend) Sync() error {
+	be.mu.RLock()
+	defer be.mu.RUnlock()
+
+	if be.closed {
+		return os.ErrClosed
+	}
+	return nil
+}
+
+func (be *memBackend) Close() error {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+
+	if be.closed {
+		return os.ErrClosed
+	}
+
+	be.closed = true
+	be.b = nil
+
+	return nil
+}
+
+func (be *memBackend) Size() (int64, error) {
+	be.mu.RLock()
+	defer be.mu.RUnlock()
+
+	if be.closed {
+		return 0, os.ErrClosed
+	}
+	return int64(len(be.b)), nil
+}
+
+func (be *memBackend) Truncate(size int64) error {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+
+	if be.closed {
+		return os.ErrClosed
+	}
+
+	if size <= int64(len(be.b)) {
+		clear(be.b[size:])
+		be.b = be.b[:size]
+		return nil
+	}
+
+	be.grow(size)
+
+	return nil
+}
+
+func (be *memBackend) grow(size int64) {
+	if size <= int64(cap(be.b)) {
+		be.b = be.b[:size]
+		return
+	}
+
+	b := make([]byte, size, max(size, 2*int64(cap(be.b))))
+	copy(b, be.b)
+	be.b = b
+}
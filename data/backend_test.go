@@ -0,0 +1,481 @@
+package data_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestMemPager(t *testing.T) {
+	pgr, err := data.NewMemPager(os.Getpagesize())
+	if err != nil {
+		t.Fatalf("Failed to create in-memory pager, with error %s", err)
+	}
+
+	t.Run("create and write", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+			pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+			if err := pgr.Write(pg); err != nil {
+				t.Fatalf(
+					"Failed to write page %+v, with error %s",
+					pg, err,
+				)
+			}
+		}
+
+		if _, err := pgr.Flush(); err != nil {
+			t.Fatalf("Failed to flush metainfo, with error %s", err)
+		}
+	})
+
+	t.Run("recovery and read", func(t *testing.T) {
+		expectedMeta := *pgr.Meta()
+
+		pgr.Freelist().Max = data.BeginFreeBlocks
+		if err := pgr.Recovery(); err != nil {
+			t.Fatalf("Failed to recover in-memory pager, with error %s", err)
+		}
+
+		if !expectedMeta.Equal(pgr.Meta()) {
+			t.Fatalf(
+				"Failed to compare metainfo: expected %+v, actual %+v",
+				&expectedMeta, pgr.Meta(),
+			)
+		}
+
+		for i, p := 0, data.BeginFreeBlocks; i < 10; i, p = i+1, p+1 {
+			pg, err := pgr.Read(data.PageNum(p))
+			if err != nil {
+				t.Fatalf(
+					"Failed to read page %d, with error %s",
+					p, err,
+				)
+			}
+
+			expectedPgData := fmt.Sprintf("data%d", i+1)
+			actualPgData := string(bytes.TrimRight(pg.Data, "\x00"))
+
+			if expectedPgData != actualPgData {
+				t.Fatalf(
+					"Failed to compare page data: expected %s(%d), actual %s(%d)",
+					expectedPgData, len(expectedPgData),
+					actualPgData, len(actualPgData),
+				)
+			}
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		if err := pgr.Close(); err != nil {
+			t.Fatalf("Failed to close in-memory pager, with error %s", err)
+		}
+
+		if _, err := pgr.Read(data.BeginFreeBlocks); !errors.Is(err, data.ErrClosed) {
+			t.Fatalf(
+				"Failed to read closed pager: expected error %s, actual %v",
+				data.ErrClosed, err,
+			)
+		}
+	})
+}
+
+func TestMemPager_Compact(t *testing.T) {
+	pgr, err := data.NewMemPager(os.Getpagesize())
+	if err != nil {
+		t.Fatalf("Failed to create in-memory pager, with error %s", err)
+	}
+	defer pgr.Close()
+
+	var nums []data.PageNum
+	for i := 0; i < 4; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		nums = append(nums, pg.Num)
+	}
+
+	pgr.Freelist().Release(nums[1])
+
+	if err := pgr.Compact(); err != nil {
+		t.Fatalf("Failed to compact in-memory pager, with error %s", err)
+	}
+
+	if expected := data.BeginFreeBlocks + 3; pgr.Freelist().Max != expected {
+		t.Fatalf(
+			"Failed to compare freelist max: expected %d, actual %d",
+			expected, pgr.Freelist().Max,
+		)
+	}
+}
+
+// sliceBackend implements only the methods Backend requires, so the pager
+// has to probe its size.
+type sliceBackend struct {
+	b []byte
+}
+
+func (be *sliceBackend) ReadAt(b []byte, off int64) (int, error) {
+	if off >= int64(len(be.b)) {
+		return 0, io.EOF
+	}
+
+	n := copy(b, be.b[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (be *sliceBackend) WriteAt(b []byte, off int64) (int, error) {
+	if end := off + int64(len(b)); end > int64(len(be.b)) {
+		be.b = append(be.b, make([]byte, end-int64(len(be.b)))...)
+	}
+	return copy(be.b[off:], b), nil
+}
+
+func (be *sliceBackend) Truncate(size int64) error {
+	if size < int64(len(be.b)) {
+		be.b = be.b[:size]
+	} else {
+		be.b = append(be.b, make([]byte, size-int64(len(be.b)))...)
+	}
+	return nil
+}
+
+func (be *sliceBackend) Sync() error  { return nil }
+func (be *sliceBackend) Close() error { return nil }
+
+func TestNewPagerFromBackend(t *testing.T) {
+	psize := os.Getpagesize()
+	be := new(sliceBackend)
+
+	pgr, err := data.NewPagerFromBackend(be, psize)
+	if err != nil {
+		t.Fatalf("Failed to create pager from backend, with error %s", err)
+	}
+
+	pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+	pg.Write([]byte("data"))
+
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	stats, err := pgr.Stats()
+	if err != nil {
+		t.Fatalf("Failed to get pager stats, with error %s", err)
+	}
+
+	if stats.FileSize != int64(len(be.b)) {
+		t.Fatalf(
+			"Failed to compare probed backend size: expected %d, actual %d",
+			len(be.b), stats.FileSize,
+		)
+	}
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	reopened, err := data.NewPagerFromBackend(be, psize)
+	if err != nil {
+		t.Fatalf("Failed to reopen pager from backend, with error %s", err)
+	}
+	defer reopened.Close()
+
+	actualPg, err := reopened.Read(pg.Num)
+	if err != nil {
+		t.Fatalf("Failed to read page %d, with error %s", pg.Num, err)
+	}
+
+	if !bytes.Equal(pg.Data, actualPg.Data) {
+		t.Fatalf("Failed to compare page %d data after reopen", pg.Num)
+	}
+}
+
+// shortBackend writes only half of every buffer once short is set, and
+// reports it without an error.
+type shortBackend struct {
+	sliceBackend
+	short bool
+}
+
+func (be *shortBackend) WriteAt(b []byte, off int64) (int, error) {
+	if be.short {
+		b = b[:len(b)/2]
+	}
+	return be.sliceBackend.WriteAt(b, off)
+}
+
+func TestPager_ShortWrite(t *testing.T) {
+	be := new(shortBackend)
+
+	pgr, err := data.NewPagerFromBackend(be, os.Getpagesize())
+	if err != nil {
+		t.Fatalf("Failed to create pager from backend, with error %s", err)
+	}
+	defer pgr.Close()
+
+	be.short = true
+
+	pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+	if err := pgr.Write(pg); !errors.Is(err, data.ErrShortWrite) {
+		t.Fatalf(
+			"Failed to write page %d: expected error %s, actual %v",
+			pg.Num, data.ErrShortWrite, err,
+		)
+	}
+
+	if err := pgr.WriteBatch([]*data.Page{pg}); !errors.Is(err, data.ErrShortWrite) {
+		t.Fatalf(
+			"Failed to write batch: expected error %s, actual %v",
+			data.ErrShortWrite, err,
+		)
+	}
+
+	if _, err := pgr.Flush(); !errors.Is(err, data.ErrShortWrite) {
+		t.Fatalf(
+			"Failed to flush pager: expected error %s, actual %v",
+			data.ErrShortWrite, err,
+		)
+	}
+}
+
+var errBackend = errors.New("backend failure")
+
+// failingBackend fails with errBackend the writes failWrite picks by
+// offset, and every Truncate while failTruncate is set.
+type failingBackend struct {
+	sliceBackend
+	failWrite    func(off int64) bool
+	failTruncate bool
+}
+
+func (be *failingBackend) WriteAt(b []byte, off int64) (int, error) {
+	if be.failWrite != nil && be.failWrite(off) {
+		return 0, errBackend
+	}
+	return be.sliceBackend.WriteAt(b, off)
+}
+
+func (be *failingBackend) Truncate(size int64) error {
+	if be.failTruncate {
+		return errBackend
+	}
+	return be.sliceBackend.Truncate(size)
+}
+
+func TestPager_FlushMetaFailure(t *testing.T) {
+	psize := os.Getpagesize()
+	be := new(failingBackend)
+
+	pgr, err := data.NewPagerFromBackend(be, psize)
+	if err != nil {
+		t.Fatalf("Failed to create pager from backend, with error %s", err)
+	}
+	defer pgr.Close()
+
+	write := func() {
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		pg.Write([]byte("data"))
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %d, with error %s", pg.Num, err)
+		}
+	}
+
+	write()
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+	good := pgr.Meta().TxID
+
+	isMeta := func(off int64) bool {
+		return off == int64(data.DefaultMetaPage)*int64(psize) || off == int64(data.ShadowMetaPage)*int64(psize)
+	}
+
+	write()
+	be.failWrite = isMeta
+	if _, err := pgr.Flush(); !errors.Is(err, errBackend) {
+		t.Fatalf(
+			"Failed to flush pager: expected error %s, actual %v",
+			errBackend, err,
+		)
+	}
+	if pgr.Meta().TxID != good {
+		t.Fatalf(
+			"Failed to compare txid after failed flush: expected %d, actual %d",
+			good, pgr.Meta().TxID,
+		)
+	}
+
+	// The next flush goes to the other slot, the last good meta survives.
+	be.failWrite = nil
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	txids := make(map[uint64]bool)
+	for _, num := range []data.PageNum{data.DefaultMetaPage, data.ShadowMetaPage} {
+		meta, err := pgr.ReadMetaAt(num)
+		if err != nil {
+			t.Fatalf("Failed to read meta page %d, with error %s", num, err)
+		}
+		txids[meta.TxID] = true
+	}
+	if !txids[good] || !txids[good+1] {
+		t.Fatalf(
+			"Failed to compare meta slots: expected txids %d and %d, actual %v",
+			good, good+1, txids,
+		)
+	}
+}
+
+// recordingBackend counts the writes and syncs that reach it and records
+// the offsets it is read at.
+type recordingBackend struct {
+	sliceBackend
+	writes, syncs int
+	reads         []int64
+}
+
+func (be *recordingBackend) ReadAt(b []byte, off int64) (int, error) {
+	be.reads = append(be.reads, off)
+	return be.sliceBackend.ReadAt(b, off)
+}
+
+func (be *recordingBackend) WriteAt(b []byte, off int64) (int, error) {
+	be.writes++
+	return be.sliceBackend.WriteAt(b, off)
+}
+
+func (be *recordingBackend) Sync() error {
+	be.syncs++
+	return nil
+}
+
+func TestPager_FlushUnchanged(t *testing.T) {
+	be := new(recordingBackend)
+
+	pgr, err := data.NewPagerFromBackend(be, os.Getpagesize())
+	if err != nil {
+		t.Fatalf("Failed to create pager from backend, with error %s", err)
+	}
+	defer pgr.Close()
+
+	pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+	pg.Write([]byte("data"))
+
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %d, with error %s", pg.Num, err)
+	}
+
+	changed, err := pgr.Flush()
+	if err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+	if !changed {
+		t.Fatalf("Failed to compare changed of first flush: expected %t, actual %t", true, changed)
+	}
+
+	writes, syncs := be.writes, be.syncs
+
+	changed, err = pgr.Flush()
+	if err != nil {
+		t.Fatalf("Failed to flush pager again, with error %s", err)
+	}
+	if changed {
+		t.Fatalf("Failed to compare changed of second flush: expected %t, actual %t", false, changed)
+	}
+
+	if be.writes != writes || be.syncs != syncs {
+		t.Fatalf(
+			"Failed to compare I/O of second flush: expected %d writes and %d syncs, actual %d and %d",
+			writes, syncs, be.writes, be.syncs,
+		)
+	}
+
+	pgr.Meta().Root = pg.Num
+
+	if changed, err := pgr.Flush(); err != nil || !changed {
+		t.Fatalf("Failed to flush changed metainfo: changed %t, with error %v", changed, err)
+	}
+
+	// Nothing changed since, so closing does not flush either.
+	writes, syncs = be.writes, be.syncs
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+	if be.writes != writes || be.syncs != syncs {
+		t.Fatalf(
+			"Failed to compare I/O of close: expected %d writes and %d syncs, actual %d and %d",
+			writes, syncs, be.writes, be.syncs,
+		)
+	}
+}
+
+func TestPager_ShortRead(t *testing.T) {
+	psize := os.Getpagesize()
+
+	// truncated writes a page whose payload ends in data and cuts the
+	// backend in the middle of it, as a crash during the write would.
+	truncated := func(t *testing.T, opts ...data.PagerOption) (*data.Pager, *data.Page) {
+		t.Helper()
+
+		be := new(sliceBackend)
+		pgr, err := data.NewPagerFromBackend(be, psize, opts...)
+		if err != nil {
+			t.Fatalf("Failed to create pager from backend, with error %s", err)
+		}
+		t.Cleanup(func() { _ = pgr.Close() })
+
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		if err := pg.WriteAt([]byte("tail"), len(pg.Data)-4); err != nil {
+			t.Fatalf("Failed to write page data, with error %s", err)
+		}
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+
+		be.b = be.b[:len(be.b)-psize/2]
+
+		return pgr, pg
+	}
+
+	t.Run("checksum", func(t *testing.T) {
+		pgr, pg := truncated(t)
+
+		if _, err := pgr.Read(pg.Num); !errors.Is(err, data.ErrChecksumMismatch) {
+			t.Fatalf(
+				"Failed to read partial page %d: expected error %s, actual %v",
+				pg.Num, data.ErrChecksumMismatch, err,
+			)
+		}
+	})
+
+	t.Run("no checksum", func(t *testing.T) {
+		pgr, pg := truncated(t, data.WithChecksum(nil))
+
+		actual, err := pgr.Read(pg.Num)
+		if err != nil {
+			t.Fatalf("Failed to read partial page %d, with error %s", pg.Num, err)
+		}
+
+		if !bytes.Equal(actual.Data, make([]byte, len(pg.Data))) {
+			t.Fatalf("Failed to compare partial page %d: expected lost tail to read as zeros", pg.Num)
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// BackupMagic opens every backup file written by Backup.
+var BackupMagic = [8]byte{'E', 'M', 'B', 'D', 'B', 'K', 'U', 'P'}
+
+// BackupVersion is the version of the backup header written by Backup.
+const BackupVersion uint16 = 1
+
+// backupHeaderSize covers the magic, the version, the flags and the page
+// size. The header is always little-endian.
+const backupHeaderSize = 8 + 2 + 1 + 4
+
+const backupCompressed byte = 1 << 0
+
+// BackupOptions configures Backup. The zero value writes the pages as is.
+type BackupOptions struct {
+	// Compress gzips the pages of the backup.
+	Compress bool
+}
+
+type BackupOption func(*BackupOptions)
+
+func WithBackupCompression(enabled bool) BackupOption {
+	return func(opts *BackupOptions) {
+		opts.Compress = enabled
+	}
+}
+
+// Backup writes a point-in-time copy of the store to a new file at path:
+// a header recording the page size, then the pages of CopyTo, gzipped
+// with WithBackupCompression. Restore turns it back into a store. A
+// backup that fails is removed.
+func (pgr *Pager) Backup(path string, opts ...BackupOption) error {
+	var options BackupOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, DefaultFilePerm)
+	if err != nil {
+		return fmt.Errorf("pager/backup: open/create file: %w", err)
+	}
+
+	if err := pgr.writeBackup(f, options); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return fmt.Errorf("pager/backup: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		_ = os.Remove(path)
+		return fmt.Errorf("pager/backup: close file: %w", err)
+	}
+
+	return nil
+}
+
+func (pgr *Pager) writeBackup(f *os.File, options BackupOptions) error {
+	head := make([]byte, backupHeaderSize)
+	copy(head[:8], BackupMagic[:])
+	binary.LittleEndian.PutUint16(head[8:10], BackupVersion)
+	if options.Compress {
+		head[10] |= backupCompressed
+	}
+	binary.LittleEndian.PutUint32(head[11:15], uint32(pgr.psize))
+
+	bw := bufio.NewWriter(f)
+	if _, err := bw.Write(head); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+
+	var w io.Writer = bw
+	var zw *gzip.Writer
+	if options.Compress {
+		zw = gzip.NewWriter(bw)
+		w = zw
+	}
+
+	if err := pgr.CopyTo(w); err != nil {
+		return err
+	}
+
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("compress: %w", err)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write pages: %w", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync file: %w", err)
+	}
+
+	return nil
+}
+
+// Restore turns the backup at src into a store at dst, which NewPager
+// opens with the page size the backup recorded. dst must not exist yet,
+// so that a restore never overwrites a live store. A restore that fails
+// is removed.
+func Restore(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("restore: open backup: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_EXCL, DefaultFilePerm)
+	if err != nil {
+		return fmt.Errorf("restore: create file: %w", err)
+	}
+
+	if err := restore(out, bufio.NewReader(in)); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	if err := out.Close(); err != nil {
+		_ = os.Remove(dst)
+		return fmt.Errorf("restore: close file: %w", err)
+	}
+
+	return nil
+}
+
+func restore(out *os.File, in io.Reader) error {
+	head := make([]byte, backupHeaderSize)
+	if _, err := io.ReadFull(in, head); err != nil {
+		return fmt.Errorf("read header: %w", shortRead(err))
+	}
+
+	if !bytes.Equal(head[:8], BackupMagic[:]) {
+		return fmt.Errorf("read header: %w", ErrBadMagic)
+	}
+
+	if version := binary.LittleEndian.Uint16(head[8:10]); version != BackupVersion {
+		return fmt.Errorf("read header: version %d: %w", version, ErrUnsupportedVersion)
+	}
+
+	psize := int(binary.LittleEndian.Uint32(head[11:15]))
+	if err := checkPageSize(psize); err != nil {
+		return fmt.Errorf("read header: %w", err)
+	}
+
+	r := in
+	if head[10]&backupCompressed != 0 {
+		zr, err := gzip.NewReader(in)
+		if err != nil {
+			return fmt.Errorf("decompress: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	n, err := io.Copy(out, r)
+	if err != nil {
+		return fmt.Errorf("copy pages: %w", err)
+	}
+
+	if n == 0 || n%int64(psize) != 0 {
+		return fmt.Errorf("%d bytes of pages, page size %d: %w", n, psize, ErrWrongBytes)
+	}
+
+	if err := out.Sync(); err != nil {
+		return fmt.Errorf("sync file: %w", err)
+	}
+
+	return nil
+}
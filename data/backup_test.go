@@ -0,0 +1,95 @@
+package data_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_Backup(t *testing.T) {
+	dir := t.TempDir()
+	psize := os.Getpagesize()
+
+	filename := filepath.Join(dir, "test_data")
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf("Failed to create pager, with error %s", err)
+	}
+	defer pgr.Close()
+
+	pages := make([]*data.Page, 20)
+	for i := range pages {
+		pages[i] = pgr.Alloc().WithNum(nextPage(t, pgr))
+		pages[i].Write([]byte(fmt.Sprintf("data%d", i+1)))
+	}
+	if err := pgr.WriteBatch(pages); err != nil {
+		t.Fatalf("Failed to write pages, with error %s", err)
+	}
+
+	for name, compress := range map[string]bool{"compressed": true, "plain": false} {
+		t.Run(name, func(t *testing.T) {
+			backup := filepath.Join(dir, name+".backup")
+			restored := filepath.Join(dir, name+"_restored")
+
+			if err := pgr.Backup(backup, data.WithBackupCompression(compress)); err != nil {
+				t.Fatalf("Failed to back up pager, with error %s", err)
+			}
+
+			info, err := os.Stat(backup)
+			if err != nil {
+				t.Fatalf("Failed to stat backup %s, with error %s", backup, err)
+			}
+			if full := int64(pgr.Freelist().Max) * int64(psize); compress && info.Size() >= full {
+				t.Fatalf("Failed to compress backup: %d bytes for %d bytes of pages", info.Size(), full)
+			}
+
+			if err := data.Restore(backup, restored); err != nil {
+				t.Fatalf("Failed to restore backup, with error %s", err)
+			}
+
+			rpgr, err := data.NewPager(restored, psize)
+			if err != nil {
+				t.Fatalf("Failed to open restored store, with error %s", err)
+			}
+			defer rpgr.Close()
+
+			if !rpgr.Meta().Equal(pgr.Meta()) || !rpgr.Freelist().Equal(pgr.Freelist()) {
+				t.Fatalf(
+					"Failed to compare restored state: expected %+v %+v, actual %+v %+v",
+					pgr.Meta(), pgr.Freelist(), rpgr.Meta(), rpgr.Freelist(),
+				)
+			}
+
+			for _, pg := range pages {
+				actual, err := rpgr.Read(pg.Num)
+				if err != nil {
+					t.Fatalf("Failed to read restored page %d, with error %s", pg.Num, err)
+				}
+				if !bytes.Equal(actual.Data, pg.Data) {
+					t.Fatalf("Failed to compare restored page %d data", pg.Num)
+				}
+			}
+
+			if err := data.Restore(backup, restored); !errors.Is(err, os.ErrExist) {
+				t.Fatalf("Failed to refuse restoring over a store: expected error %s, actual %v", os.ErrExist, err)
+			}
+		})
+	}
+
+	t.Run("not a backup", func(t *testing.T) {
+		restored := filepath.Join(dir, "foreign_restored")
+
+		if err := data.Restore(filename, restored); !errors.Is(err, data.ErrBadMagic) {
+			t.Fatalf("Failed to restore foreign file: expected error %s, actual %v", data.ErrBadMagic, err)
+		}
+		if _, err := os.Stat(restored); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("Failed to remove failed restore %s: %v", restored, err)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// WriteBatch writes pages in ascending page order and coalesces runs of
+// adjacent page numbers into a single write. Pages are validated and
+// sealed before anything is written. When a write fails, the error names
+// the first page of the run that did not make it to the file; pages
+// before it have been written.
+func (pgr *Pager) WriteBatch(pages []*Page) error {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	return pgr.writeBatch(context.Background(), pages)
+}
+
+func (pgr *Pager) writeBatch(ctx context.Context, pages []*Page) error {
+	if pgr.closed {
+		return fmt.Errorf("pager/writeBatch: %w", ErrClosed)
+	}
+
+	if pgr.opts.ReadOnly {
+		return fmt.Errorf("pager/writeBatch: %w", ErrReadOnly)
+	}
+
+	sorted := slices.Clone(pages)
+	slices.SortStableFunc(sorted, func(a, b *Page) int {
+		return cmp.Compare(a.Num, b.Num)
+	})
+
+	sealed := make([][]byte, len(sorted))
+	for i, pg := range sorted {
+		if pgr.opts.StrictWrites && len(pg.Data) != pgr.payloadSize() {
+			return fmt.Errorf(
+				"pager/writeBatch(num=%d,size=%d): %w",
+				pg.Num, len(pg.Data), ErrWrongPageSize,
+			)
+		}
+
+		b, err := pgr.sealPage(pg.Num, pg.typ, pg.Data)
+		if err != nil {
+			return fmt.Errorf("pager/writeBatch(num=%d,size=%d): %w", pg.Num, len(pg.Data), err)
+		}
+		sealed[i] = b
+	}
+
+	if pgr.opts.BufferedWrites {
+		for i, pg := range sorted {
+			pgr.cache.invalidate(pg.Num)
+			pgr.dirty[pg.Num] = sealed[i]
+		}
+		return nil
+	}
+
+	if err := pgr.writeRuns(ctx, sorted, sealed); err != nil {
+		return fmt.Errorf("pager/writeBatch: %w", err)
+	}
+
+	return nil
+}
+
+// writeRuns writes sealed pages sorted by page number, one run of
+// adjacent pages at a time, checking ctx before every run.
+func (pgr *Pager) writeRuns(ctx context.Context, pages []*Page, sealed [][]byte) error {
+	for start := 0; start < len(pages); {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("write run(num=%d): %w", pages[start].Num, err)
+		}
+
+		end := start + 1
+		for end < len(pages) && pages[end].Num == pages[end-1].Num+1 {
+			end++
+		}
+
+		if err := pgr.writeRun(pages[start:end], sealed[start:end]); err != nil {
+			return err
+		}
+
+		start = end
+	}
+
+	return nil
+}
+
+// writeRun writes sealed pages with consecutive page numbers in one call.
+func (pgr *Pager) writeRun(pages []*Page, sealed [][]byte) error {
+	pgr.changed = true
+
+	for i, pg := range pages {
+		pgr.cache.invalidate(pg.Num)
+		if err := pgr.updateStateSum(pg.Num, sealed[i]); err != nil {
+			return fmt.Errorf("write run(num=%d): %w", pg.Num, err)
+		}
+	}
+
+	run := slices.Concat(sealed...)
+
+	n, err := pgr.store.WriteAt(run, int64(pages[0].Num)*int64(pgr.psize))
+	for _, pg := range pages[:n/pgr.psize] {
+		delete(pgr.dirty, pg.Num)
+		pgr.notifyWrite(pg.Num)
+	}
+	if err != nil {
+		return fmt.Errorf("write run(num=%d): %w", pages[n/pgr.psize].Num, err)
+	}
+	if n != len(run) {
+		return pgr.logShortWrite(pages[n/pgr.psize].Num, fmt.Errorf(
+			"write run(num=%d): wrote %d of %d bytes: %w",
+			pages[n/pgr.psize].Num, n, len(run), ErrShortWrite,
+		))
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package data_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_WriteBatch(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	var pages []*data.Page
+	for i := 0; i < 8; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+		pages = append(pages, pg)
+	}
+
+	// Out of order with a hole in the middle, so the batch is written as
+	// two runs.
+	batch := []*data.Page{pages[7], pages[1], pages[0], pages[5], pages[2], pages[6]}
+	if err := pgr.WriteBatch(batch); err != nil {
+		t.Fatalf("Failed to write batch, with error %s", err)
+	}
+
+	if batch[0] != pages[7] {
+		t.Fatalf("Failed to check batch order: caller slice was reordered")
+	}
+
+	for _, expectedPg := range batch {
+		actualPg, err := pgr.Read(expectedPg.Num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", expectedPg.Num, err)
+		}
+
+		if !bytes.Equal(expectedPg.Data, actualPg.Data) {
+			t.Fatalf("Failed to compare page %d data after batch write", expectedPg.Num)
+		}
+	}
+}
+
+func BenchmarkPager_WriteBatch(b *testing.B) {
+	for name, batched := range map[string]bool{"batch": true, "loop": false} {
+		b.Run(name, func(b *testing.B) {
+			filename := filepath.Join(b.TempDir(), "bench_data")
+
+			pgr, err := data.NewPager(filename, os.Getpagesize())
+			if err != nil {
+				b.Fatalf(
+					"Failed to create pager by path %s, with error %s",
+					filename, err,
+				)
+			}
+			defer pgr.Close()
+
+			pages := make([]*data.Page, 1000)
+			for i := range pages {
+				pages[i] = pgr.Alloc().WithNum(nextFree(b, pgr.Freelist()))
+				pages[i].Write([]byte(fmt.Sprintf("data%d", i+1)))
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if batched {
+					if err := pgr.WriteBatch(pages); err != nil {
+						b.Fatalf("Failed to write batch, with error %s", err)
+					}
+					continue
+				}
+
+				for _, pg := range pages {
+					if err := pgr.Write(pg); err != nil {
+						b.Fatalf("Failed to write page %+v, with error %s", pg, err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,599 @@
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// A node is stored in the payload of a single page: a leaf flag, the
+// number of keys and, for branches, the leftmost child, followed by the
+// entries. Leaf entries hold a key and its value, branch entries a key
+// and the child holding the keys from it up to the next key.
+const (
+	nodeHeaderSize = 1 + 2
+
+	nodeFlagLeaf = 1 << 0
+)
+
+type node struct {
+	num  PageNum
+	leaf bool
+
+	keys     [][]byte
+	vals     [][]byte
+	children []PageNum
+}
+
+func (n *node) size() int {
+	size := nodeHeaderSize
+	if !n.leaf {
+		size += 8
+	}
+
+	for i, key := range n.keys {
+		size += n.entrySize(key, i)
+	}
+
+	return size
+}
+
+func (n *node) entrySize(key []byte, i int) int {
+	if n.leaf {
+		return 2 + 2 + len(key) + len(n.vals[i])
+	}
+	return 2 + len(key) + 8
+}
+
+func (n *node) serialize(b []byte) {
+	clear(b)
+
+	if n.leaf {
+		b[0] = nodeFlagLeaf
+	}
+	binary.LittleEndian.PutUint16(b[1:3], uint16(len(n.keys)))
+
+	off := nodeHeaderSize
+	if !n.leaf {
+		binary.LittleEndian.PutUint64(b[off:off+8], uint64(n.children[0]))
+		off += 8
+	}
+
+	for i, key := range n.keys {
+		binary.LittleEndian.PutUint16(b[off:off+2], uint16(len(key)))
+		off += 2
+
+		if n.leaf {
+			binary.LittleEndian.PutUint16(b[off:off+2], uint16(len(n.vals[i])))
+			off += 2
+			off += copy(b[off:], key)
+			off += copy(b[off:], n.vals[i])
+			continue
+		}
+
+		off += copy(b[off:], key)
+		binary.LittleEndian.PutUint64(b[off:off+8], uint64(n.children[i+1]))
+		off += 8
+	}
+}
+
+func (n *node) deserialize(b []byte) error {
+	if len(b) < nodeHeaderSize {
+		return fmt.Errorf("node/deserialize: decode head: %w", ErrWrongBytes)
+	}
+
+	n.leaf = b[0]&nodeFlagLeaf != 0
+	count := int(binary.LittleEndian.Uint16(b[1:3]))
+
+	n.keys = make([][]byte, 0, count)
+	n.vals, n.children = nil, nil
+
+	off := nodeHeaderSize
+	if !n.leaf {
+		if len(b) < off+8 {
+			return fmt.Errorf("node/deserialize: decode head: %w", ErrWrongBytes)
+		}
+		n.children = append(make([]PageNum, 0, count+1), PageNum(binary.LittleEndian.Uint64(b[off:off+8])))
+		off += 8
+	}
+
+	for i := 0; i < count; i++ {
+		head := 2
+		if n.leaf {
+			head += 2
+		}
+		if len(b) < off+head {
+			return fmt.Errorf("node/deserialize: entry %d: %w", i, ErrWrongBytes)
+		}
+
+		klen := int(binary.LittleEndian.Uint16(b[off : off+2]))
+		if !n.leaf {
+			if len(b) < off+2+klen+8 {
+				return fmt.Errorf("node/deserialize: entry %d: %w", i, ErrWrongBytes)
+			}
+
+			n.keys = append(n.keys, bytes.Clone(b[off+2:off+2+klen]))
+			n.children = append(n.children, PageNum(binary.LittleEndian.Uint64(b[off+2+klen:off+2+klen+8])))
+			off += 2 + klen + 8
+			continue
+		}
+
+		vlen := int(binary.LittleEndian.Uint16(b[off+2 : off+4]))
+		if len(b) < off+4+klen+vlen {
+			return fmt.Errorf("node/deserialize: entry %d: %w", i, ErrWrongBytes)
+		}
+
+		n.keys = append(n.keys, bytes.Clone(b[off+4:off+4+klen]))
+		n.vals = append(n.vals, bytes.Clone(b[off+4+klen:off+4+klen+vlen]))
+		off += 4 + klen + vlen
+	}
+
+	return nil
+}
+
+// search returns the position of key among the keys of the node and
+// whether it is there.
+func (n *node) search(key []byte) (int, bool) {
+	return slices.BinarySearchFunc(n.keys, key, bytes.Compare)
+}
+
+// childIndex returns the child of a branch that holds key.
+func (n *node) childIndex(key []byte) int {
+	i, found := n.search(key)
+	if found {
+		return i + 1
+	}
+	return i
+}
+
+// split divides an overfull node into two halves of about the same size
+// and returns the key separating them. The receiver keeps the left half.
+func (n *node) split() ([]byte, *node) {
+	total, half := n.size(), 0
+
+	m := 0
+	for ; m < len(n.keys) && half < total/2; m++ {
+		half += n.entrySize(n.keys[m], m)
+	}
+
+	if n.leaf {
+		m = min(max(m, 1), len(n.keys)-1)
+
+		right := &node{
+			leaf: true,
+			keys: slices.Clone(n.keys[m:]),
+			vals: slices.Clone(n.vals[m:]),
+		}
+		n.keys, n.vals = n.keys[:m], n.vals[:m]
+
+		return right.keys[0], right
+	}
+
+	m = min(max(m, 1), len(n.keys)-2)
+
+	sep := n.keys[m]
+	right := &node{
+		keys:     slices.Clone(n.keys[m+1:]),
+		children: slices.Clone(n.children[m+1:]),
+	}
+	n.keys, n.children = n.keys[:m], n.children[:m+1]
+
+	return sep, right
+}
+
+// btree is a B+ tree of byte keys and small byte values rooted at root,
+// with one node per page. Zero is the root of an empty tree. Its methods
+// must be called with the pager lock held for writing, or for reading
+// when they do not modify the tree.
+//
+// Modified in a store transaction, the tree is copy-on-write: a node from
+// before the transaction is written to a new page, so readers of the old
+// root keep seeing it, and its page is handed to the transaction to be
+// released once those readers are done. Outside of one nodes are
+// overwritten in place.
+type btree struct {
+	pgr  *Pager
+	root PageNum
+
+	pages *txPages
+}
+
+// txPages tracks the pages a store transaction allocates and frees. Pages
+// allocated in the transaction are seen by no one else and can be
+// overwritten or released right away, any other freed page is kept in
+// freed.
+type txPages struct {
+	fresh map[PageNum]struct{}
+	freed []PageNum
+}
+
+func newTxPages() *txPages {
+	return &txPages{fresh: make(map[PageNum]struct{})}
+}
+
+// capacity is the number of bytes a node can take up in a page.
+func (tree *btree) capacity() int {
+	return tree.pgr.MaxLogicalPayload()
+}
+
+// maxEntrySize bounds the size of a key with its value, so that any node
+// with more than a few entries can be split into two that fit a page.
+func (tree *btree) maxEntrySize() int {
+	return (tree.capacity() - nodeHeaderSize - 8) / 4
+}
+
+// alloc takes a page for a new node or value chain page.
+func (tree *btree) alloc() (PageNum, error) {
+	num, err := tree.pgr.flist.Next()
+	if err != nil {
+		return 0, err
+	}
+
+	if tree.pages != nil {
+		tree.pages.fresh[num] = struct{}{}
+	}
+	return num, nil
+}
+
+// release gives up a page of the tree or of one of its values.
+func (tree *btree) release(num PageNum) {
+	if tree.pages == nil {
+		tree.pgr.flist.Release(num)
+		return
+	}
+
+	if _, ok := tree.pages.fresh[num]; ok {
+		delete(tree.pages.fresh, num)
+		tree.pgr.flist.Release(num)
+		return
+	}
+
+	tree.pages.freed = append(tree.pages.freed, num)
+}
+
+func (tree *btree) readNode(num PageNum) (*node, error) {
+	pg, err := tree.pgr.read(num)
+	if err != nil {
+		return nil, fmt.Errorf("read node %d: %w", num, err)
+	}
+
+	n := &node{num: num}
+	if err := n.deserialize(pg.Data[:tree.capacity()]); err != nil {
+		return nil, fmt.Errorf("read node %d: %w", num, err)
+	}
+
+	return n, nil
+}
+
+// writeNode stores the node, moving it to a new page first when the tree
+// is copy-on-write and the page is older than the transaction.
+func (tree *btree) writeNode(n *node) error {
+	if tree.pages != nil && n.num != 0 {
+		if _, ok := tree.pages.fresh[n.num]; !ok {
+			tree.release(n.num)
+			n.num = 0
+		}
+	}
+
+	if n.num == 0 {
+		num, err := tree.alloc()
+		if err != nil {
+			return fmt.Errorf("write node: %w", err)
+		}
+		n.num = num
+	}
+
+	pg := tree.pgr.Alloc().WithNum(n.num)
+	n.serialize(pg.Data[:tree.capacity()])
+
+	if err := tree.pgr.write(pg, tree.pgr.opts.BufferedWrites); err != nil {
+		return fmt.Errorf("write node %d: %w", n.num, err)
+	}
+
+	return nil
+}
+
+// get returns the value stored under key and whether the key is there.
+func (tree *btree) get(key []byte) ([]byte, bool, error) {
+	for num := tree.root; num != 0; {
+		n, err := tree.readNode(num)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if !n.leaf {
+			num = n.children[n.childIndex(key)]
+			continue
+		}
+
+		i, found := n.search(key)
+		if !found {
+			return nil, false, nil
+		}
+		return n.vals[i], true, nil
+	}
+
+	return nil, false, nil
+}
+
+// put stores val under key, replacing the value it had.
+func (tree *btree) put(key, val []byte) error {
+	if 2+2+len(key)+len(val) > tree.maxEntrySize() {
+		return fmt.Errorf(
+			"btree put: entry of %d bytes, max %d: %w",
+			2+2+len(key)+len(val), tree.maxEntrySize(), ErrDataTooLarge,
+		)
+	}
+
+	if tree.root == 0 {
+		root := &node{leaf: true, keys: [][]byte{key}, vals: [][]byte{val}}
+		if err := tree.writeNode(root); err != nil {
+			return fmt.Errorf("btree put: %w", err)
+		}
+		tree.root = root.num
+
+		return nil
+	}
+
+	n, sep, right, err := tree.insert(tree.root, key, val)
+	if err != nil {
+		return fmt.Errorf("btree put: %w", err)
+	}
+	tree.root = n.num
+
+	if right != nil {
+		root := &node{keys: [][]byte{sep}, children: []PageNum{n.num, right.num}}
+		if err := tree.writeNode(root); err != nil {
+			return fmt.Errorf("btree put: %w", err)
+		}
+		tree.root = root.num
+	}
+
+	return nil
+}
+
+// insert puts key into the subtree at num and returns the node now at its
+// top. When the node had to be split it also returns the new right
+// sibling and the key separating it.
+func (tree *btree) insert(num PageNum, key, val []byte) (*node, []byte, *node, error) {
+	n, err := tree.readNode(num)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if n.leaf {
+		i, found := n.search(key)
+		if found {
+			n.vals[i] = val
+		} else {
+			n.keys = slices.Insert(n.keys, i, key)
+			n.vals = slices.Insert(n.vals, i, val)
+		}
+	} else {
+		ci := n.childIndex(key)
+
+		child, sep, right, err := tree.insert(n.children[ci], key, val)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if child.num == n.children[ci] && right == nil {
+			return n, nil, nil, nil
+		}
+
+		n.children[ci] = child.num
+		if right != nil {
+			n.keys = slices.Insert(n.keys, ci, sep)
+			n.children = slices.Insert(n.children, ci+1, right.num)
+		}
+	}
+
+	var (
+		sep   []byte
+		right *node
+	)
+	if n.size() > tree.capacity() {
+		sep, right = n.split()
+		if err := tree.writeNode(right); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	if err := tree.writeNode(n); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return n, sep, right, nil
+}
+
+// delete removes key from the tree and reports whether it was there.
+func (tree *btree) delete(key []byte) (bool, error) {
+	if tree.root == 0 {
+		return false, nil
+	}
+
+	root, found, _, err := tree.remove(tree.root, key)
+	if err != nil || !found {
+		return found, err
+	}
+	tree.root = root.num
+
+	switch {
+	case root.leaf && len(root.keys) == 0:
+		tree.release(root.num)
+		tree.root = 0
+	case !root.leaf && len(root.keys) == 0:
+		tree.release(root.num)
+		tree.root = root.children[0]
+	}
+
+	return true, nil
+}
+
+// remove deletes key from the subtree at num and returns the node now at
+// its top. It reports whether the key was found and whether the node is
+// now below a quarter of a page.
+func (tree *btree) remove(num PageNum, key []byte) (*node, bool, bool, error) {
+	n, err := tree.readNode(num)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	if n.leaf {
+		i, found := n.search(key)
+		if !found {
+			return n, false, false, nil
+		}
+
+		n.keys = slices.Delete(n.keys, i, i+1)
+		n.vals = slices.Delete(n.vals, i, i+1)
+	} else {
+		ci := n.childIndex(key)
+
+		child, found, underflow, err := tree.remove(n.children[ci], key)
+		if err != nil || !found {
+			return n, found, false, err
+		}
+		if child.num == n.children[ci] && !underflow {
+			return n, true, false, nil
+		}
+
+		n.children[ci] = child.num
+		if underflow {
+			if err := tree.rebalance(n, ci); err != nil {
+				return nil, true, false, err
+			}
+		}
+	}
+
+	if err := tree.writeNode(n); err != nil {
+		return nil, true, false, err
+	}
+
+	return n, true, n.size() < tree.capacity()/4, nil
+}
+
+// rebalance merges the child ci of parent with a sibling, or spreads
+// their entries evenly over both when they do not fit a single page.
+func (tree *btree) rebalance(parent *node, ci int) error {
+	li := max(ci-1, 0)
+
+	left, err := tree.readNode(parent.children[li])
+	if err != nil {
+		return err
+	}
+	right, err := tree.readNode(parent.children[li+1])
+	if err != nil {
+		return err
+	}
+
+	left.keys = slices.Concat(left.keys, right.keys)
+	if left.leaf {
+		left.vals = slices.Concat(left.vals, right.vals)
+	} else {
+		left.keys = slices.Insert(left.keys, len(left.keys)-len(right.keys), parent.keys[li])
+		left.children = slices.Concat(left.children, right.children)
+	}
+
+	if left.size() <= tree.capacity() {
+		tree.release(right.num)
+
+		if err := tree.writeNode(left); err != nil {
+			return err
+		}
+
+		parent.children[li] = left.num
+		parent.keys = slices.Delete(parent.keys, li, li+1)
+		parent.children = slices.Delete(parent.children, li+1, li+2)
+
+		return nil
+	}
+
+	sep, spread := left.split()
+	spread.num = right.num
+	parent.keys[li] = sep
+
+	if err := tree.writeNode(spread); err != nil {
+		return err
+	}
+	if err := tree.writeNode(left); err != nil {
+		return err
+	}
+
+	parent.children[li], parent.children[li+1] = left.num, spread.num
+
+	return nil
+}
+
+// walk calls fn with every key and value in key order until fn returns
+// false.
+func (tree *btree) walk(fn func(key, val []byte) bool) error {
+	if tree.root == 0 {
+		return nil
+	}
+
+	_, err := tree.walkNode(tree.root, fn)
+	return err
+}
+
+func (tree *btree) walkNode(num PageNum, fn func(key, val []byte) bool) (bool, error) {
+	n, err := tree.readNode(num)
+	if err != nil {
+		return false, err
+	}
+
+	if n.leaf {
+		for i, key := range n.keys {
+			if !fn(key, n.vals[i]) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	for _, child := range n.children {
+		if more, err := tree.walkNode(child, fn); err != nil || !more {
+			return more, err
+		}
+	}
+
+	return true, nil
+}
+
+// drop releases every node of the tree, calling fn with the values of a
+// leaf before releasing it.
+func (tree *btree) drop(fn func(val []byte) error) error {
+	if tree.root == 0 {
+		return nil
+	}
+
+	if err := tree.dropNode(tree.root, fn); err != nil {
+		return err
+	}
+	tree.root = 0
+
+	return nil
+}
+
+func (tree *btree) dropNode(num PageNum, fn func(val []byte) error) error {
+	n, err := tree.readNode(num)
+	if err != nil {
+		return err
+	}
+
+	for _, val := range n.vals {
+		if err := fn(val); err != nil {
+			return err
+		}
+	}
+
+	for _, child := range n.children {
+		if err := tree.dropNode(child, fn); err != nil {
+			return err
+		}
+	}
+
+	tree.release(num)
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package data
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Bucket is a named set of keys within a store, kept in a tree of its
+// own. Keys in different buckets do not interfere with each other or with
+// the keys at the top level of the store. The buckets tree, rooted at the
+// Buckets page of the metainfo, maps every bucket name to the root of its
+// tree.
+//
+// A bucket taken from a transaction works within it. One taken from the
+// store runs every operation in a transaction of its own.
+type Bucket struct {
+	s    *Store
+	tx   *StoreTx
+	name []byte
+}
+
+// in returns the bucket working within tx.
+func (b *Bucket) in(tx *StoreTx) *Bucket {
+	return &Bucket{s: b.s, tx: tx, name: b.name}
+}
+
+func (b *Bucket) loadRoot() (PageNum, error) {
+	buckets := b.s.pgr.meta.Buckets
+	if b.tx != nil {
+		buckets = b.tx.buckets
+	}
+
+	val, ok, err := b.s.tree(buckets, nil).get(b.name)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("bucket %q: %w", b.name, ErrBucketNotFound)
+	}
+
+	return PageNum(binary.LittleEndian.Uint64(val)), nil
+}
+
+func (b *Bucket) storeRoot(root PageNum) error {
+	buckets := b.s.tree(b.tx.buckets, b.tx.pages)
+
+	err := buckets.put(b.name, binary.LittleEndian.AppendUint64(nil, uint64(root)))
+	b.tx.buckets = buckets.root
+
+	return err
+}
+
+// CreateBucket creates an empty bucket, or returns ErrBucketExists.
+func (s *Store) CreateBucket(name []byte) (*Bucket, error) {
+	err := s.Update(func(tx *StoreTx) error {
+		_, err := tx.CreateBucket(name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bucket{s: s, name: name}, nil
+}
+
+// Bucket returns an existing bucket, or ErrBucketNotFound.
+func (s *Store) Bucket(name []byte) (*Bucket, error) {
+	err := s.View(func(tx *StoreTx) error {
+		_, err := tx.Bucket(name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bucket{s: s, name: name}, nil
+}
+
+// DeleteBucket removes a bucket and releases the pages of its tree and of
+// all of its values.
+func (s *Store) DeleteBucket(name []byte) error {
+	return s.Update(func(tx *StoreTx) error {
+		return tx.DeleteBucket(name)
+	})
+}
+
+// CreateBucket creates an empty bucket, or returns ErrBucketExists.
+func (tx *StoreTx) CreateBucket(name []byte) (*Bucket, error) {
+	tx.s.pgr.mu.Lock()
+	defer tx.s.pgr.mu.Unlock()
+
+	if err := tx.check(true); err != nil {
+		return nil, fmt.Errorf("storeTx/createBucket: %w", err)
+	}
+
+	b := &Bucket{s: tx.s, tx: tx, name: name}
+	switch _, err := b.loadRoot(); {
+	case err == nil:
+		return nil, fmt.Errorf("storeTx/createBucket: bucket %q: %w", name, ErrBucketExists)
+	case !errors.Is(err, ErrBucketNotFound):
+		return nil, fmt.Errorf("storeTx/createBucket: %w", err)
+	}
+
+	if err := b.storeRoot(0); err != nil {
+		return nil, fmt.Errorf("storeTx/createBucket: %w", err)
+	}
+
+	return b, nil
+}
+
+// Bucket returns an existing bucket, or ErrBucketNotFound.
+func (tx *StoreTx) Bucket(name []byte) (*Bucket, error) {
+	tx.s.pgr.mu.RLock()
+	defer tx.s.pgr.mu.RUnlock()
+
+	if err := tx.check(false); err != nil {
+		return nil, fmt.Errorf("storeTx/bucket: %w", err)
+	}
+
+	b := &Bucket{s: tx.s, tx: tx, name: name}
+	if _, err := b.loadRoot(); err != nil {
+		return nil, fmt.Errorf("storeTx/bucket: %w", err)
+	}
+
+	return b, nil
+}
+
+// DeleteBucket removes a bucket and releases the pages of its tree and of
+// all of its values.
+func (tx *StoreTx) DeleteBucket(name []byte) error {
+	tx.s.pgr.mu.Lock()
+	defer tx.s.pgr.mu.Unlock()
+
+	if err := tx.check(true); err != nil {
+		return fmt.Errorf("storeTx/deleteBucket: %w", err)
+	}
+
+	root, err := (&Bucket{s: tx.s, tx: tx, name: name}).loadRoot()
+	if err != nil {
+		return fmt.Errorf("storeTx/deleteBucket: %w", err)
+	}
+
+	tree := tx.s.tree(root, tx.pages)
+	err = tree.drop(func(val []byte) error {
+		return tx.s.freeValue(tree, val)
+	})
+	if err != nil {
+		return fmt.Errorf("storeTx/deleteBucket: bucket %q: %w", name, err)
+	}
+
+	buckets := tx.s.tree(tx.buckets, tx.pages)
+	_, err = buckets.delete(name)
+	tx.buckets = buckets.root
+	if err != nil {
+		return fmt.Errorf("storeTx/deleteBucket: %w", err)
+	}
+
+	return nil
+}
+
+// Name returns the name of the bucket.
+func (b *Bucket) Name() []byte {
+	return b.name
+}
+
+// Put stores value under key in the bucket, replacing and freeing any
+// previous value.
+func (b *Bucket) Put(key, value []byte) error {
+	if b.tx == nil {
+		return b.s.Update(func(tx *StoreTx) error {
+			return b.in(tx).Put(key, value)
+		})
+	}
+
+	b.s.pgr.mu.Lock()
+	defer b.s.pgr.mu.Unlock()
+
+	if err := b.tx.check(true); err != nil {
+		return fmt.Errorf("bucket/put: %w", err)
+	}
+
+	if err := b.s.put(b, b.tx.pages, key, value); err != nil {
+		return fmt.Errorf("bucket/put: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the value stored under key in the bucket, or
+// ErrKeyNotFound.
+func (b *Bucket) Get(key []byte) ([]byte, error) {
+	if b.tx == nil {
+		var value []byte
+		err := b.s.View(func(tx *StoreTx) error {
+			var err error
+			value, err = b.in(tx).Get(key)
+			return err
+		})
+
+		return value, err
+	}
+
+	b.s.pgr.mu.RLock()
+	defer b.s.pgr.mu.RUnlock()
+
+	if err := b.tx.check(false); err != nil {
+		return nil, fmt.Errorf("bucket/get: %w", err)
+	}
+
+	value, err := b.s.get(b, key)
+	if err != nil {
+		return nil, fmt.Errorf("bucket/get: %w", err)
+	}
+
+	return value, nil
+}
+
+// Delete removes key from the bucket and frees its value, or returns
+// ErrKeyNotFound.
+func (b *Bucket) Delete(key []byte) error {
+	if b.tx == nil {
+		return b.s.Update(func(tx *StoreTx) error {
+			return b.in(tx).Delete(key)
+		})
+	}
+
+	b.s.pgr.mu.Lock()
+	defer b.s.pgr.mu.Unlock()
+
+	if err := b.tx.check(true); err != nil {
+		return fmt.Errorf("bucket/delete: %w", err)
+	}
+
+	if err := b.s.delete(b, b.tx.pages, key); err != nil {
+		return fmt.Errorf("bucket/delete: %w", err)
+	}
+
+	return nil
+}
+
+// Cursor returns a cursor positioned before the first key of the bucket.
+// For a bucket taken from the store the same caveat applies as for the
+// cursor of the store.
+func (b *Bucket) Cursor() *Cursor {
+	return newCursor(b.s, b.loadRoot)
+}
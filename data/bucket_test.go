@@ -0,0 +1,142 @@
+package data_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestBucket(t *testing.T) {
+	openStore := func(t *testing.T) (*data.Store, *data.Pager) {
+		t.Helper()
+
+		pgr, err := data.NewMemPager(512)
+		if err != nil {
+			t.Fatalf("Failed to create in-memory pager, with error %s", err)
+		}
+
+		s, err := data.OpenStore(pgr)
+		if err != nil {
+			_ = pgr.Close()
+			t.Fatalf("Failed to open store, with error %s", err)
+		}
+		t.Cleanup(func() { _ = s.Close() })
+
+		return s, pgr
+	}
+
+	createBucket := func(t *testing.T, s *data.Store, name string) *data.Bucket {
+		t.Helper()
+
+		b, err := s.CreateBucket([]byte(name))
+		if err != nil {
+			t.Fatalf("Failed to create bucket %s, with error %s", name, err)
+		}
+
+		return b
+	}
+
+	t.Run("same key", func(t *testing.T) {
+		s, _ := openStore(t)
+		first, second := createBucket(t, s, "first"), createBucket(t, s, "second")
+
+		for _, b := range []*data.Bucket{first, second} {
+			if err := b.Put([]byte("key"), b.Name()); err != nil {
+				t.Fatalf("Failed to put key into bucket %s, with error %s", b.Name(), err)
+			}
+		}
+
+		if _, err := s.Get([]byte("key")); !errors.Is(err, data.ErrKeyNotFound) {
+			t.Fatalf("Failed to get key outside of buckets: expected error %s, actual %v", data.ErrKeyNotFound, err)
+		}
+
+		for _, name := range []string{"first", "second"} {
+			b, err := s.Bucket([]byte(name))
+			if err != nil {
+				t.Fatalf("Failed to open bucket %s, with error %s", name, err)
+			}
+
+			value, err := b.Get([]byte("key"))
+			if err != nil || string(value) != name {
+				t.Fatalf("Failed to compare key in bucket %s: actual %s, error %v", name, value, err)
+			}
+		}
+
+		if _, err := s.CreateBucket([]byte("first")); !errors.Is(err, data.ErrBucketExists) {
+			t.Fatalf("Failed to create existing bucket: expected error %s, actual %v", data.ErrBucketExists, err)
+		}
+
+		if _, err := s.Bucket([]byte("missing")); !errors.Is(err, data.ErrBucketNotFound) {
+			t.Fatalf("Failed to open missing bucket: expected error %s, actual %v", data.ErrBucketNotFound, err)
+		}
+	})
+
+	t.Run("iterate", func(t *testing.T) {
+		s, _ := openStore(t)
+		b, other := createBucket(t, s, "bucket"), createBucket(t, s, "other")
+
+		var expected []string
+		for i := 0; i < 200; i++ {
+			key := fmt.Sprintf("key%04d", i)
+			expected = append(expected, key)
+
+			if err := b.Put([]byte(key), []byte("value")); err != nil {
+				t.Fatalf("Failed to put key %s, with error %s", key, err)
+			}
+			if err := other.Put([]byte("other"+key), []byte("value")); err != nil {
+				t.Fatalf("Failed to put key %s, with error %s", key, err)
+			}
+		}
+
+		c := b.Cursor()
+
+		var actual []string
+		for key, _, ok := c.Next(); ok; key, _, ok = c.Next() {
+			actual = append(actual, string(key))
+		}
+
+		if c.Err() != nil || !slices.Equal(expected, actual) {
+			t.Fatalf("Failed to iterate bucket: expected %d keys, actual %v (error %v)", len(expected), actual, c.Err())
+		}
+	})
+
+	t.Run("delete frees pages", func(t *testing.T) {
+		s, pgr := openStore(t)
+		kept := createBucket(t, s, "kept")
+		if err := kept.Put([]byte("key"), []byte("value")); err != nil {
+			t.Fatalf("Failed to put key, with error %s", err)
+		}
+
+		// The pages of the freelist itself grow with the pages it holds.
+		used := func() int {
+			return int(pgr.Freelist().Max) - len(pgr.Freelist().Released) - pgr.Meta().FreelistPages
+		}
+		before := used()
+
+		b := createBucket(t, s, "deleted")
+		for i := 0; i < 200; i++ {
+			if err := b.Put([]byte(fmt.Sprintf("key%04d", i)), make([]byte, 1000)); err != nil {
+				t.Fatalf("Failed to put key, with error %s", err)
+			}
+		}
+
+		if err := s.DeleteBucket([]byte("deleted")); err != nil {
+			t.Fatalf("Failed to delete bucket, with error %s", err)
+		}
+
+		if after := used(); after != before {
+			t.Fatalf("Failed to compare used pages: expected %d, actual %d", before, after)
+		}
+
+		if _, err := b.Get([]byte("key0000")); !errors.Is(err, data.ErrBucketNotFound) {
+			t.Fatalf("Failed to get key of deleted bucket: expected error %s, actual %v", data.ErrBucketNotFound, err)
+		}
+
+		if value, err := kept.Get([]byte("key")); err != nil || string(value) != "value" {
+			t.Fatalf("Failed to get key of kept bucket: actual %s, error %v", value, err)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package data
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// pageCache keeps the most recently used pages of a Pager in memory, see
+// WithCache. Pages are copied on the way in and out, so callers never
+// share bytes with the cache. The pager drops a page from the cache
+// whenever it writes the page or cuts the file before it, so the cache
+// can't serve stale bytes whichever method changed them.
+//
+// With WithReadahead, a miss on the page following the one read last
+// reads a window of pages at once and caches all of them.
+//
+// A nil cache caches nothing.
+type pageCache struct {
+	mu       sync.Mutex
+	capacity int
+	lru      *list.List
+	entries  map[PageNum]*list.Element
+
+	// readahead is the size of the window and next the page a sequential
+	// read asks for next.
+	readahead int
+	next      PageNum
+
+	hits   uint64
+	misses uint64
+}
+
+func newPageCache(capacity, readahead int) *pageCache {
+	return &pageCache{
+		capacity: max(1, capacity),
+		lru:      list.New(),
+		entries:  make(map[PageNum]*list.Element),
+
+		readahead: min(max(1, capacity), readahead),
+		next:      -1,
+	}
+}
+
+// readCached is read through the cache. It is called with the pager lock
+// held, for reading at least, after num was checked against the freelist.
+func (pgr *Pager) readCached(num PageNum) (*Page, error) {
+	c := pgr.cache
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sequential := num == c.next
+	c.next = num + 1
+
+	if elem, ok := c.entries[num]; ok {
+		c.hits += 1
+		pgr.observer.OnCacheHit(num)
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*Page).Clone(), nil
+	}
+	c.misses += 1
+	pgr.observer.OnCacheMiss(num)
+
+	if sequential && c.readahead > 1 {
+		pages, err := pgr.readAhead(num, c.readahead)
+		if err != nil {
+			return nil, fmt.Errorf("cache: readRange(start=%d,n=%d): %w", num, c.readahead, err)
+		}
+
+		// The window is cached back to front, so the pages read soonest
+		// are the most recently used.
+		for i := len(pages) - 1; i > 0; i-- {
+			c.put(pages[i])
+		}
+		c.put(pages[0].Clone())
+
+		return pages[0], nil
+	}
+
+	pg, err := pgr.readPage(num)
+	if err != nil {
+		return nil, fmt.Errorf("cache: %w", err)
+	}
+	c.put(pg.Clone())
+
+	return pg, nil
+}
+
+// invalidate drops page num from the cache.
+func (c *pageCache) invalidate(num PageNum) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remove(num)
+}
+
+// truncate drops the pages at or past end from the cache.
+func (c *pageCache) truncate(end PageNum) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for num := range c.entries {
+		if num >= end {
+			c.remove(num)
+		}
+	}
+}
+
+// reset drops every page from the cache.
+func (c *pageCache) reset() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.Init()
+	clear(c.entries)
+	c.next = -1
+}
+
+// counters returns the number of reads served from the cache and the
+// number of reads that went to the store.
+func (c *pageCache) counters() (hits, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits, c.misses
+}
+
+func (c *pageCache) put(pg *Page) {
+	if elem, ok := c.entries[pg.Num]; ok {
+		elem.Value = pg
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[pg.Num] = c.lru.PushFront(pg)
+
+	if c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*Page).Num)
+	}
+}
+
+func (c *pageCache) remove(num PageNum) {
+	if elem, ok := c.entries[num]; ok {
+		c.lru.Remove(elem)
+		delete(c.entries, num)
+	}
+}
@@ -0,0 +1,152 @@
+package data_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+type cacheObserver struct {
+	data.NopObserver
+
+	mu           sync.Mutex
+	hits, misses int
+}
+
+func (o *cacheObserver) OnCacheHit(data.PageNum) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.hits += 1
+}
+
+func (o *cacheObserver) OnCacheMiss(data.PageNum) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.misses += 1
+}
+
+func TestPager_Cache(t *testing.T) {
+	o := &cacheObserver{}
+
+	pgr, err := data.NewMemPager(512, data.WithCache(4), data.WithObserver(o))
+	if err != nil {
+		t.Fatalf("Failed to create in-memory pager, with error %s", err)
+	}
+	defer pgr.Close()
+
+	num, err := pgr.Append([]byte("first"))
+	if err != nil {
+		t.Fatalf("Failed to append page, with error %s", err)
+	}
+
+	expectData := func(expected string) {
+		t.Helper()
+
+		actual, err := pgr.PageData(num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", num, err)
+		}
+		if actual = bytes.TrimRight(actual, "\x00"); !bytes.Equal(actual, []byte(expected)) {
+			t.Fatalf("Failed to compare page %d data: expected %q, actual %q", num, expected, actual)
+		}
+	}
+
+	expectData("first")
+	expectData("first")
+	if o.hits != 1 || o.misses != 1 {
+		t.Fatalf(
+			"Failed to compare observed cache lookups: expected 1 hit and 1 miss, actual %d and %d",
+			o.hits, o.misses,
+		)
+	}
+
+	pg := pgr.Alloc().WithNum(num)
+	pg.Write([]byte("batch"))
+	if err := pgr.WriteBatch([]*data.Page{pg}); err != nil {
+		t.Fatalf("Failed to write batch, with error %s", err)
+	}
+	expectData("batch")
+
+	// A page released, trimmed off the tail and handed out again reads
+	// as the file holds it, not as it was cached.
+	if err := pgr.ReleasePage(num); err != nil {
+		t.Fatalf("Failed to release page %d, with error %s", num, err)
+	}
+	if err := pgr.Truncate(); err != nil {
+		t.Fatalf("Failed to truncate pager, with error %s", err)
+	}
+	grown, err := pgr.Grow(1)
+	if err != nil {
+		t.Fatalf("Failed to grow pager, with error %s", err)
+	}
+	if grown != num {
+		t.Fatalf("Failed to compare grown page: expected %d, actual %d", num, grown)
+	}
+	if actual, err := pgr.PageData(num); err == nil && bytes.Contains(actual, []byte("batch")) {
+		t.Fatalf("Failed to drop truncated page %d from the cache: %q", num, bytes.TrimRight(actual, "\x00"))
+	}
+}
+
+func TestPager_CacheBufferedWrites(t *testing.T) {
+	pgr, err := data.NewMemPager(512, data.WithCache(4), data.WithBufferedWrites(true))
+	if err != nil {
+		t.Fatalf("Failed to create in-memory pager, with error %s", err)
+	}
+	defer pgr.Close()
+
+	pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+	for _, payload := range []string{"first", "second"} {
+		pg.Write([]byte(payload))
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+
+		actual, err := pgr.PageData(pg.Num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", pg.Num, err)
+		}
+		if actual = bytes.TrimRight(actual, "\x00"); !bytes.Equal(actual, []byte(payload)) {
+			t.Fatalf("Failed to compare page %d data: expected %q, actual %q", pg.Num, payload, actual)
+		}
+	}
+}
+
+func TestPager_CacheReadahead(t *testing.T) {
+	o := &cacheObserver{}
+
+	pgr, err := data.NewMemPager(512, data.WithCache(8), data.WithReadahead(3), data.WithObserver(o))
+	if err != nil {
+		t.Fatalf("Failed to create in-memory pager, with error %s", err)
+	}
+	defer pgr.Close()
+
+	var nums []data.PageNum
+	for i := 0; i < 5; i++ {
+		num, err := pgr.Append([]byte{byte(i + 1)})
+		if err != nil {
+			t.Fatalf("Failed to append page, with error %s", err)
+		}
+		nums = append(nums, num)
+	}
+
+	// Five pages read in order take one read for the first, one window
+	// for the next three and one more for the last.
+	for i, num := range nums {
+		pg, err := pgr.Read(num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", num, err)
+		}
+		if pg.Data[0] != byte(i+1) {
+			t.Fatalf("Failed to compare page %d data: expected %d, actual %d", num, i+1, pg.Data[0])
+		}
+	}
+
+	if o.hits != 2 || o.misses != 3 {
+		t.Fatalf(
+			"Failed to compare observed cache lookups: expected 2 hits and 3 misses, actual %d and %d",
+			o.hits, o.misses,
+		)
+	}
+}
@@ -0,0 +1,103 @@
+package data
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const chainHeaderSize = 8 + 4
+
+// writeChain spreads b across a linked list of pages. Pages in nums are
+// reused in order, missing ones are taken from the freelist and surplus
+// ones are released back to it. Every page is tagged with typ. It returns
+// the pages now holding the chain.
+func (pgr *Pager) writeChain(nums []PageNum, typ PageType, b []byte) ([]PageNum, error) {
+	count := pgr.chainLen(len(b))
+
+	for len(nums) < count {
+		num, err := pgr.flist.Next()
+		if err != nil {
+			return nil, fmt.Errorf("write chain: %w", err)
+		}
+		nums = append(nums, num)
+	}
+	for _, num := range nums[count:] {
+		if err := pgr.flist.Release(num); err != nil {
+			return nil, fmt.Errorf("write chain: %w", err)
+		}
+	}
+	nums = nums[:count]
+
+	for _, pg := range pgr.chainPages(nums, typ, b) {
+		if err := pgr.write(pg, false); err != nil {
+			return nil, fmt.Errorf("write chain: %w", err)
+		}
+	}
+
+	return nums, nil
+}
+
+// chainLen returns the number of chain pages needed to hold size bytes.
+func (pgr *Pager) chainLen(size int) int {
+	capacity := pgr.MaxLogicalPayload() - chainHeaderSize
+	return max(1, (size+capacity-1)/capacity)
+}
+
+// chainPages lays b out over exactly the pages in nums, linking each page
+// to the next one. Pages past the end of b are left empty.
+func (pgr *Pager) chainPages(nums []PageNum, typ PageType, b []byte) []*Page {
+	capacity := pgr.MaxLogicalPayload() - chainHeaderSize
+
+	pages := make([]*Page, len(nums))
+	for i, num := range nums {
+		next := PageNum(0)
+		if i+1 < len(nums) {
+			next = nums[i+1]
+		}
+
+		chunk := b[min(len(b), i*capacity):min(len(b), (i+1)*capacity)]
+
+		pg := pgr.Alloc().WithNum(num).WithType(typ)
+		binary.LittleEndian.PutUint64(pg.Data[:8], uint64(next))
+		binary.LittleEndian.PutUint32(pg.Data[8:12], uint32(len(chunk)))
+		copy(pg.Data[chainHeaderSize:], chunk)
+
+		pages[i] = pg
+	}
+
+	return pages
+}
+
+// readChain collects the payload of the chain starting at head together
+// with the pages it occupies.
+func (pgr *Pager) readChain(head PageNum) ([]byte, []PageNum, error) {
+	var (
+		b    []byte
+		nums []PageNum
+	)
+
+	seen := make(map[PageNum]struct{})
+	for num := head; num != 0; {
+		if _, ok := seen[num]; ok {
+			return nil, nil, fmt.Errorf("read chain(head=%d): cycle at page %d: %w", head, num, ErrCorruptChain)
+		}
+		seen[num] = struct{}{}
+
+		pg, err := pgr.readPage(num)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read chain(head=%d): page %d: %w", head, num, err)
+		}
+
+		size := int(binary.LittleEndian.Uint32(pg.Data[8:12]))
+		if size > len(pg.Data)-chainHeaderSize {
+			return nil, nil, fmt.Errorf("read chain(head=%d): page %d: %w", head, num, ErrWrongBytes)
+		}
+
+		b = append(b, pg.Data[chainHeaderSize:chainHeaderSize+size]...)
+		nums = append(nums, num)
+		num = PageNum(binary.LittleEndian.Uint64(pg.Data[:8]))
+		pgr.putPage(pg)
+	}
+
+	return b, nums, nil
+}
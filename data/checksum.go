@@ -0,0 +1,152 @@
+package data
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// pageHeaderSize is the header every page starts with on disk: the
+// checksum of the rest of the page followed by the page type. With
+// ChecksumFooter the two end the page instead, the type first.
+const (
+	checksumSize   = 4
+	pageHeaderSize = checksumSize + 1
+)
+
+// ChecksumPlacement is where pages store their checksum and type.
+type ChecksumPlacement uint8
+
+const (
+	// ChecksumHeader stores the checksum and the type ahead of the
+	// payload.
+	ChecksumHeader ChecksumPlacement = iota
+	// ChecksumFooter stores them behind the payload, which then starts
+	// at the beginning of the page.
+	ChecksumFooter
+)
+
+// body returns the part of the stored page b that holds the payload.
+func (place ChecksumPlacement) body(b []byte) []byte {
+	if place == ChecksumFooter {
+		return b[:len(b)-pageHeaderSize]
+	}
+	return b[pageHeaderSize:]
+}
+
+// typeAt returns the offset of the page type in a stored page of size
+// bytes.
+func (place ChecksumPlacement) typeAt(size int) int {
+	if place == ChecksumFooter {
+		return size - pageHeaderSize
+	}
+	return checksumSize
+}
+
+// split returns the checksum of the stored page b and the bytes it covers.
+func (place ChecksumPlacement) split(b []byte) (sum, covered []byte) {
+	if place == ChecksumFooter {
+		return b[len(b)-checksumSize:], b[:len(b)-checksumSize]
+	}
+	return b[:checksumSize], b[checksumSize:]
+}
+
+// placement returns where page num stores its checksum. The meta pages
+// always use a header, so that the metainfo recording the placement of
+// the other pages can be read before it is known.
+func (pgr *Pager) placement(num PageNum) ChecksumPlacement {
+	if num == DefaultMetaPage || num == ShadowMetaPage {
+		return ChecksumHeader
+	}
+	return pgr.meta.ChecksumPlacement
+}
+
+// ChecksumFunc computes the checksum stored in the header of every page.
+type ChecksumFunc func(b []byte) uint32
+
+// CRC32C is the default page checksum.
+func CRC32C(b []byte) uint32 {
+	return crc32.Checksum(b, castagnoli)
+}
+
+func (pgr *Pager) payloadSize() int {
+	return pgr.psize - pageHeaderSize - pgr.sealOverhead()
+}
+
+// sealPage turns the contents of page num into the bytes stored on disk:
+// the encoded and, with encryption, encrypted payload behind a header
+// holding the checksum and the page type.
+func (pgr *Pager) sealPage(num PageNum, typ PageType, data []byte) ([]byte, error) {
+	body, err := pgr.encodePage(num, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) > pgr.payloadSize() && !isZero(body[pgr.payloadSize():]) {
+		return nil, fmt.Errorf("seal page: %w", ErrDataTooLarge)
+	}
+
+	place := pgr.placement(num)
+
+	b := make([]byte, pgr.psize)
+	b[place.typeAt(len(b))] = byte(typ)
+	copy(place.body(b)[:pgr.payloadSize()], body)
+
+	if pgr.aead != nil {
+		if err := pgr.encryptPage(num, typ, place.body(b)); err != nil {
+			return nil, fmt.Errorf("seal page: %w", err)
+		}
+	}
+
+	if pgr.checksum != nil {
+		sum, covered := place.split(b)
+		binary.LittleEndian.PutUint32(sum, pgr.checksum(covered))
+	}
+
+	return b, nil
+}
+
+// openPage verifies the bytes stored for page num, decrypts them with
+// encryption and decodes the payload into dst.
+func (pgr *Pager) openPage(num PageNum, b, dst []byte) error {
+	if err := pgr.verifyPage(num, b); err != nil {
+		return err
+	}
+
+	stored := pgr.placement(num).body(b)
+	body := stored[:pgr.payloadSize()]
+	if pgr.aead != nil && !isZero(b) {
+		plain, err := pgr.decryptPage(num, pgr.storedType(num, b), stored)
+		if err != nil {
+			return err
+		}
+		body = plain
+	}
+
+	return pgr.decodePage(num, body, dst)
+}
+
+// verifyPage checks the checksum header of the bytes stored for page num.
+// A page that is entirely zero was never written and is valid.
+func (pgr *Pager) verifyPage(num PageNum, b []byte) error {
+	if pgr.checksum == nil || isZero(b) {
+		return nil
+	}
+
+	sum, covered := pgr.placement(num).split(b)
+	stored := binary.LittleEndian.Uint32(sum)
+	if actual := pgr.checksum(covered); stored != actual {
+		pgr.log.Warn("page checksum mismatch", "page", num, "stored", stored, "computed", actual)
+		return fmt.Errorf(
+			"open page %d: stored %08x, computed %08x: %w",
+			num, stored, actual, ErrChecksumMismatch,
+		)
+	}
+
+	return nil
+}
+
+// storedType returns the page type in the bytes stored for page num.
+func (pgr *Pager) storedType(num PageNum, b []byte) PageType {
+	return PageType(b[pgr.placement(num).typeAt(len(b))])
+}
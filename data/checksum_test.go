@@ -0,0 +1,154 @@
+package data_test
+
+import (
+	"bytes"
+	"errors"
+	"hash/adler32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_Checksum(t *testing.T) {
+	for name, opts := range map[string][]data.PagerOption{
+		"crc32c":  nil,
+		"adler32": {data.WithChecksum(adler32.Checksum)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "test_data")
+			psize := os.Getpagesize()
+
+			pgr, err := data.NewPagerWithOptions(filename, psize, opts...)
+			if err != nil {
+				t.Fatalf(
+					"Failed to create pager by path %s, with error %s",
+					filename, err,
+				)
+			}
+			defer pgr.Close()
+
+			if len(pgr.Alloc().Data) >= psize {
+				t.Fatalf(
+					"Failed to check page payload: %d bytes leave no room for the header",
+					len(pgr.Alloc().Data),
+				)
+			}
+
+			pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+			pg.Write([]byte("data"))
+
+			if err := pgr.Write(pg); err != nil {
+				t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+			}
+
+			if _, err := pgr.Read(pg.Num); err != nil {
+				t.Fatalf("Failed to read page %d, with error %s", pg.Num, err)
+			}
+
+			f, err := os.OpenFile(filename, os.O_RDWR, data.DefaultFilePerm)
+			if err != nil {
+				t.Fatalf("Failed to open file %s, with error %s", filename, err)
+			}
+			defer f.Close()
+
+			off := int64(pg.Num)*int64(psize) + int64(psize/2)
+			if _, err := f.WriteAt([]byte{0xff}, off); err != nil {
+				t.Fatalf("Failed to flip byte at %d, with error %s", off, err)
+			}
+
+			if _, err := pgr.Read(pg.Num); !errors.Is(err, data.ErrChecksumMismatch) {
+				t.Fatalf(
+					"Failed to read damaged page: expected error %s, actual %v",
+					data.ErrChecksumMismatch, err,
+				)
+			}
+		})
+	}
+}
+
+func TestPager_ChecksumPlacement(t *testing.T) {
+	for name, place := range map[string]data.ChecksumPlacement{
+		"header": data.ChecksumHeader,
+		"footer": data.ChecksumFooter,
+	} {
+		for variant, opts := range map[string][]data.PagerOption{
+			"plain":     nil,
+			"encrypted": {data.WithEncryption(make([]byte, 32))},
+		} {
+			t.Run(name+"/"+variant, func(t *testing.T) {
+				filename := filepath.Join(t.TempDir(), "test_data")
+				psize := data.MinPageSize
+
+				opts := append([]data.PagerOption{data.WithChecksumPlacement(place)}, opts...)
+				pgr, err := data.NewPagerWithOptions(filename, psize, opts...)
+				if err != nil {
+					t.Fatalf(
+						"Failed to create pager by path %s, with error %s",
+						filename, err,
+					)
+				}
+
+				pg := pgr.Alloc().WithNum(nextPage(t, pgr)).WithType(data.PageTypeOverflow)
+				pg.Write([]byte("data"))
+				if err := pgr.Write(pg); err != nil {
+					t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+				}
+
+				actual, err := pgr.Read(pg.Num)
+				if err != nil {
+					t.Fatalf("Failed to read page %d, with error %s", pg.Num, err)
+				}
+				if !bytes.Equal(pg.Data, actual.Data) || actual.Type() != data.PageTypeOverflow {
+					t.Fatalf(
+						"Failed to compare page %d: expected %v %q, actual %v %q",
+						pg.Num, data.PageTypeOverflow, bytes.TrimRight(pg.Data, "\x00"),
+						actual.Type(), bytes.TrimRight(actual.Data, "\x00"),
+					)
+				}
+
+				if err := pgr.Close(); err != nil {
+					t.Fatalf("Failed to close pager, with error %s", err)
+				}
+
+				// Without encryption the payload of a footer page starts the
+				// page on disk.
+				b, err := os.ReadFile(filename)
+				if err != nil {
+					t.Fatalf("Failed to read file %s, with error %s", filename, err)
+				}
+				stored := b[int(pg.Num)*psize : int(pg.Num+1)*psize]
+				if startsWith := bytes.HasPrefix(stored, []byte("data")); variant == "plain" &&
+					startsWith != (place == data.ChecksumFooter) {
+					t.Fatalf("Failed to place payload of page %d: starts with data %t on disk", pg.Num, startsWith)
+				}
+
+				// The placement is read back from the metainfo, whatever the
+				// options of the reopen say.
+				reopts := append([]data.PagerOption{data.WithChecksumPlacement(1 - place)}, opts[1:]...)
+				reopened, err := data.NewPagerWithOptions(filename, psize, reopts...)
+				if err != nil {
+					t.Fatalf("Failed to reopen pager by path %s, with error %s", filename, err)
+				}
+				defer reopened.Close()
+
+				if actual := reopened.Meta().ChecksumPlacement; actual != place {
+					t.Fatalf("Failed to compare stored placement: expected %d, actual %d", place, actual)
+				}
+
+				actual, err = reopened.Read(pg.Num)
+				if err != nil {
+					t.Fatalf("Failed to read page %d after reopen, with error %s", pg.Num, err)
+				}
+				if !bytes.Equal(pg.Data, actual.Data) || actual.Type() != data.PageTypeOverflow {
+					t.Fatalf("Failed to compare page %d after reopen", pg.Num)
+				}
+
+				if err := reopened.Verify(); err != nil {
+					t.Fatalf("Failed to verify reopened pager, with error %s", err)
+				}
+			})
+		}
+	}
+}
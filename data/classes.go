@@ -0,0 +1,251 @@
+package data
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// DefaultClass is served by the primary freelist returned by Pager.Freelist.
+const DefaultClass ClassID = 0
+
+type ClassID uint8
+
+// FreelistSet keeps an independent pool of released pages per page class.
+// Pools only hold released pages: a class with an empty pool grows the
+// file through the primary freelist, so all classes share one page space.
+type FreelistSet struct {
+	Pools map[ClassID][]PageNum
+}
+
+func NewFreelistSet() *FreelistSet {
+	return &FreelistSet{
+		Pools: make(map[ClassID][]PageNum),
+	}
+}
+
+func (set *FreelistSet) pop(class ClassID) (PageNum, bool) {
+	pool := set.Pools[class]
+	if len(pool) == 0 {
+		return 0, false
+	}
+
+	num := pool[len(pool)-1]
+	set.Pools[class] = pool[:len(pool)-1]
+
+	return num, true
+}
+
+func (set *FreelistSet) push(class ClassID, num PageNum) {
+	set.Pools[class] = append(set.Pools[class], num)
+}
+
+func (set *FreelistSet) contains(num PageNum) bool {
+	for _, pool := range set.Pools {
+		if slices.Contains(pool, num) {
+			return true
+		}
+	}
+	return false
+}
+
+func (set *FreelistSet) empty() bool {
+	for _, pool := range set.Pools {
+		if len(pool) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (set *FreelistSet) Serialize() []byte {
+	classes := make([]ClassID, 0, len(set.Pools))
+	size := 4
+	for class, pool := range set.Pools {
+		if len(pool) == 0 {
+			continue
+		}
+		classes = append(classes, class)
+		size += 1 + 4 + (8 * len(pool))
+	}
+	slices.Sort(classes)
+
+	b := make([]byte, size)
+	binary.LittleEndian.PutUint32(b[:4], uint32(len(classes)))
+
+	off := 4
+	for _, class := range classes {
+		pool := set.Pools[class]
+
+		b[off] = byte(class)
+		binary.LittleEndian.PutUint32(b[off+1:off+5], uint32(len(pool)))
+		off += 1 + 4
+
+		for _, num := range pool {
+			binary.LittleEndian.PutUint64(b[off:off+8], uint64(num))
+			off += 8
+		}
+	}
+
+	return b
+}
+
+func (set *FreelistSet) Deserialize(b []byte) error {
+	if len(b) < 4 {
+		return fmt.Errorf("freelistSet/deserialize: decode head: %w", ErrWrongBytes)
+	}
+
+	count := int(binary.LittleEndian.Uint32(b[:4]))
+	set.Pools = make(map[ClassID][]PageNum, count)
+
+	off := 4
+	for i := 0; i < count; i++ {
+		if len(b) < off+1+4 {
+			return fmt.Errorf("freelistSet/deserialize: decode class head: %w", ErrWrongBytes)
+		}
+
+		class := ClassID(b[off])
+		pool := make([]PageNum, binary.LittleEndian.Uint32(b[off+1:off+5]))
+		off += 1 + 4
+
+		if len(b) < off+(8*len(pool)) {
+			return fmt.Errorf("freelistSet/deserialize: decode class body: %w", ErrWrongBytes)
+		}
+
+		for j := range pool {
+			pool[j] = PageNum(binary.LittleEndian.Uint64(b[off : off+8]))
+			off += 8
+		}
+
+		set.Pools[class] = pool
+	}
+
+	return nil
+}
+
+func (set *FreelistSet) Equal(other *FreelistSet) bool {
+	for class, pool := range set.Pools {
+		if !slices.Equal(pool, other.Pools[class]) {
+			return false
+		}
+	}
+
+	for class, pool := range other.Pools {
+		if !slices.Equal(pool, set.Pools[class]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AllocClass returns a page for class, reusing a page released to the
+// same class when possible. DefaultClass is served by the primary
+// freelist, as NextPage is.
+func (pgr *Pager) AllocClass(class ClassID) (PageNum, error) {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.opts.ReadOnly {
+		return 0, fmt.Errorf("pager/allocClass(class=%d): %w", class, ErrReadOnly)
+	}
+
+	if class != DefaultClass {
+		if num, ok := pgr.classes.pop(class); ok {
+			pgr.changed = true
+			pgr.onAllocEvent(OpAlloc, num)
+			return num, nil
+		}
+	}
+
+	num, err := pgr.flist.Next()
+	if err != nil {
+		return 0, fmt.Errorf("pager/allocClass(class=%d): %w", class, err)
+	}
+
+	return num, nil
+}
+
+// Release returns num to the pool of class, it is only handed out again
+// by AllocClass for the same class. Reserved pages and pages at or past
+// Max were never handed out and return ErrPageNotAllocated, pages already
+// in a pool or on the freelist return ErrPageFree.
+func (pgr *Pager) Release(class ClassID, num PageNum) error {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.opts.ReadOnly {
+		return fmt.Errorf("pager/release(class=%d, num=%d): %w", class, num, ErrReadOnly)
+	}
+
+	if num < pgr.flist.begin {
+		return fmt.Errorf("pager/release(class=%d, num=%d): reserved page: %w", class, num, ErrPageNotAllocated)
+	}
+
+	if num >= pgr.flist.Max {
+		return fmt.Errorf(
+			"pager/release(class=%d, num=%d): max %d: %w",
+			class, num, pgr.flist.Max, ErrPageNotAllocated,
+		)
+	}
+
+	if pgr.flist.isFree(num) || pgr.classes.contains(num) {
+		return fmt.Errorf("pager/release(class=%d, num=%d): %w", class, num, ErrPageFree)
+	}
+
+	if class == DefaultClass {
+		if err := pgr.flist.Release(num); err != nil {
+			return fmt.Errorf("pager/release(class=%d): %w", class, err)
+		}
+		return nil
+	}
+
+	pgr.classes.push(class, num)
+	pgr.changed = true
+	pgr.onAllocEvent(OpRelease, num)
+
+	return nil
+}
+
+func (pgr *Pager) Classes() *FreelistSet {
+	return pgr.classes
+}
+
+func (pgr *Pager) flushClasses() error {
+	if len(pgr.classChain.cur) == 0 && pgr.classes.empty() {
+		pgr.meta.Classes = 0
+		return nil
+	}
+
+	nums, err := pgr.writeChain(pgr.classChain.target(), PageTypeOverflow, pgr.classes.Serialize())
+	if err != nil {
+		return fmt.Errorf("flush classes: %w", err)
+	}
+
+	pgr.classChain.wrote(nums)
+	pgr.meta.Classes = nums[0]
+
+	return nil
+}
+
+func (pgr *Pager) recoverClasses() error {
+	pgr.classes = NewFreelistSet()
+	pgr.classChain = shadowChain{}
+
+	if pgr.meta.Classes == 0 {
+		return nil
+	}
+
+	b, nums, err := pgr.readChain(pgr.meta.Classes)
+	if err != nil {
+		return fmt.Errorf("recover classes: %w", err)
+	}
+
+	if err := pgr.classes.Deserialize(b); err != nil {
+		return fmt.Errorf("recover classes: %w", err)
+	}
+
+	pgr.classChain = shadowChain{cur: nums}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package data_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_Classes(t *testing.T) {
+	const (
+		indexClass data.ClassID = 1
+		blobClass  data.ClassID = 2
+	)
+
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	var indexPages, blobPages []data.PageNum
+
+	t.Run("alloc and release", func(t *testing.T) {
+		pgr, err := data.NewPager(filename, os.Getpagesize())
+		if err != nil {
+			t.Fatalf(
+				"Failed to create pager by path %s, with error %s",
+				filename, err,
+			)
+		}
+		defer pgr.Close()
+
+		alloc := func(class data.ClassID) data.PageNum {
+			num, err := pgr.AllocClass(class)
+			if err != nil {
+				t.Fatalf("Failed to allocate page of class %d, with error %s", class, err)
+			}
+			return num
+		}
+
+		// The default class is the primary freelist.
+		if num, expected := alloc(data.DefaultClass), pgr.Freelist().Max-1; num != expected {
+			t.Fatalf("Failed to compare default class allocation: expected %d, actual %d", expected, num)
+		}
+		for i := 0; i < 3; i++ {
+			indexPages = append(indexPages, alloc(indexClass))
+			blobPages = append(blobPages, alloc(blobClass))
+		}
+
+		for _, num := range indexPages {
+			if err := pgr.Release(indexClass, num); err != nil {
+				t.Fatalf("Failed to release page %d, with error %s", num, err)
+			}
+		}
+		for _, num := range blobPages {
+			if err := pgr.Release(blobClass, num); err != nil {
+				t.Fatalf("Failed to release page %d, with error %s", num, err)
+			}
+		}
+
+		if len(pgr.Freelist().Released) != 0 {
+			t.Fatalf(
+				"Failed to check primary freelist: expected no released pages, actual %v",
+				pgr.Freelist().Released,
+			)
+		}
+
+		// Released pages are free in every class.
+		for _, class := range []data.ClassID{indexClass, blobClass, data.DefaultClass} {
+			if err := pgr.Release(class, indexPages[0]); !errors.Is(err, data.ErrPageFree) {
+				t.Fatalf(
+					"Failed to release page %d twice to class %d: expected error %s, actual %v",
+					indexPages[0], class, data.ErrPageFree, err,
+				)
+			}
+		}
+
+		num := alloc(indexClass)
+		if num != indexPages[len(indexPages)-1] {
+			t.Fatalf(
+				"Failed to compare class allocation: expected %d, actual %d",
+				indexPages[len(indexPages)-1], num,
+			)
+		}
+		if err := pgr.Release(indexClass, num); err != nil {
+			t.Fatalf("Failed to release page %d, with error %s", num, err)
+		}
+
+		for _, num := range []data.PageNum{data.BeginFreeBlocks - 1, pgr.Freelist().Max} {
+			if err := pgr.Release(indexClass, num); !errors.Is(err, data.ErrPageNotAllocated) {
+				t.Fatalf(
+					"Failed to release page %d: expected error %s, actual %v",
+					num, data.ErrPageNotAllocated, err,
+				)
+			}
+		}
+
+		if _, err := pgr.Flush(); err != nil {
+			t.Fatalf("Failed to flush pager, with error %s", err)
+		}
+	})
+
+	t.Run("read-only", func(t *testing.T) {
+		pgr, err := data.NewReadOnlyPager(filename, os.Getpagesize())
+		if err != nil {
+			t.Fatalf(
+				"Failed to open pager by path %s, with error %s",
+				filename, err,
+			)
+		}
+		defer pgr.Close()
+
+		if _, err := pgr.AllocClass(indexClass); !errors.Is(err, data.ErrReadOnly) {
+			t.Fatalf("Failed to allocate on read-only pager: expected error %s, actual %v", data.ErrReadOnly, err)
+		}
+		if err := pgr.Release(indexClass, indexPages[0]); !errors.Is(err, data.ErrReadOnly) {
+			t.Fatalf("Failed to release on read-only pager: expected error %s, actual %v", data.ErrReadOnly, err)
+		}
+	})
+
+	t.Run("recovery", func(t *testing.T) {
+		pgr, err := data.NewPager(filename, os.Getpagesize())
+		if err != nil {
+			t.Fatalf(
+				"Failed to open pager by path %s, with error %s",
+				filename, err,
+			)
+		}
+		defer pgr.Close()
+
+		for class, expectedPool := range map[data.ClassID][]data.PageNum{
+			indexClass: indexPages,
+			blobClass:  blobPages,
+		} {
+			actualPool := pgr.Classes().Pools[class]
+			if len(expectedPool) != len(actualPool) {
+				t.Fatalf(
+					"Failed to compare class %d pool: expected %v, actual %v",
+					class, expectedPool, actualPool,
+				)
+			}
+			for i := range expectedPool {
+				if expectedPool[i] != actualPool[i] {
+					t.Fatalf(
+						"Failed to compare class %d pool: expected %v, actual %v",
+						class, expectedPool, actualPool,
+					)
+				}
+			}
+		}
+	})
+}
+
+func TestFreelistSet_Serialization(t *testing.T) {
+	expectedSet := data.NewFreelistSet()
+	expectedSet.Pools[1] = []data.PageNum{3, 5, 7}
+	expectedSet.Pools[4] = []data.PageNum{10}
+	expectedSetb := expectedSet.Serialize()
+
+	actualSet := new(data.FreelistSet)
+	if err := actualSet.Deserialize(expectedSetb); err != nil {
+		t.Fatalf(
+			"Failed to deserialize freelist set %+v, with error %s",
+			expectedSet, err,
+		)
+	}
+
+	if !expectedSet.Equal(actualSet) {
+		t.Fatalf(
+			"Failed to compare freelist sets: expected %+v, actual %+v",
+			expectedSet, actualSet,
+		)
+	}
+}
@@ -0,0 +1,90 @@
+package data
+
+import (
+	"fmt"
+	"time"
+)
+
+// Commit makes everything written so far durable by flushing the pager.
+// Commits are serialized; with WithGroupCommit, commits issued within the
+// same window share a single flush and all of them return its result.
+func (pgr *Pager) Commit() error {
+	if pgr.commits == nil {
+		if err := pgr.commitFlush(); err != nil {
+			return fmt.Errorf("pager/commit: %w", err)
+		}
+		return nil
+	}
+
+	req := make(chan error, 1)
+	select {
+	case pgr.commits <- req:
+	case <-pgr.done:
+		return fmt.Errorf("pager/commit: %w", ErrClosed)
+	}
+
+	if err := <-req; err != nil {
+		return fmt.Errorf("pager/commit: %w", err)
+	}
+
+	return nil
+}
+
+func (pgr *Pager) commitFlush() error {
+	pgr.commitMu.Lock()
+	defer pgr.commitMu.Unlock()
+
+	_, err := pgr.Flush()
+	return err
+}
+
+func (pgr *Pager) startGroupCommit(window time.Duration) {
+	pgr.commits = make(chan chan error)
+	pgr.done = make(chan struct{})
+
+	pgr.committer.Add(1)
+	go pgr.runGroupCommit(window)
+}
+
+func (pgr *Pager) runGroupCommit(window time.Duration) {
+	defer pgr.committer.Done()
+
+	for {
+		var batch []chan error
+
+		select {
+		case req := <-pgr.commits:
+			batch = append(batch, req)
+		case <-pgr.done:
+			return
+		}
+
+		timer := time.NewTimer(window)
+	gather:
+		for {
+			select {
+			case req := <-pgr.commits:
+				batch = append(batch, req)
+			case <-timer.C:
+				break gather
+			case <-pgr.done:
+				timer.Stop()
+				break gather
+			}
+		}
+
+		err := pgr.commitFlush()
+		for _, req := range batch {
+			req <- err
+		}
+	}
+}
+
+func (pgr *Pager) stopGroupCommit() {
+	if pgr.commits == nil {
+		return
+	}
+
+	close(pgr.done)
+	pgr.committer.Wait()
+}
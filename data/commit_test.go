@@ -0,0 +1,147 @@
+package data_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_GroupCommit(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	committers := 16
+
+	pgr, err := data.NewPagerWithOptions(
+		filename, os.Getpagesize(),
+		data.WithGroupCommit(50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	pages := make([]*data.Page, committers)
+	for i := range pages {
+		pages[i] = pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		pages[i].Write([]byte(fmt.Sprintf("data%d", i+1)))
+	}
+
+	txID := pgr.Meta().TxID
+
+	// The committers are released together, so that every write and
+	// commit falls well within one window.
+	var ready, wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, committers)
+	for _, pg := range pages {
+		ready.Add(1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ready.Done()
+			<-start
+
+			if err := pgr.Write(pg); err != nil {
+				errs <- err
+				return
+			}
+			if err := pgr.Commit(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	ready.Wait()
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("Failed to commit page, with error %s", err)
+	}
+
+	if flushes := pgr.Meta().TxID - txID; flushes == 0 || flushes >= uint64(committers) {
+		t.Fatalf(
+			"Failed to check group commit: %d commits issued %d flushes",
+			committers, flushes,
+		)
+	}
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	pgr, err = data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to open pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	for _, expectedPg := range pages {
+		actualPg, err := pgr.Read(expectedPg.Num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", expectedPg.Num, err)
+		}
+
+		if !bytes.Equal(expectedPg.Data, actualPg.Data) {
+			t.Fatalf("Failed to compare page %d data after commit", expectedPg.Num)
+		}
+	}
+}
+
+func BenchmarkPager_Commit(b *testing.B) {
+	for name, opts := range map[string][]data.PagerOption{
+		"flush per commit": nil,
+		"group commit":     {data.WithGroupCommit(time.Millisecond)},
+	} {
+		b.Run(name, func(b *testing.B) {
+			filename := filepath.Join(b.TempDir(), "bench_data")
+
+			pgr, err := data.NewPagerWithOptions(filename, os.Getpagesize(), opts...)
+			if err != nil {
+				b.Fatalf(
+					"Failed to create pager by path %s, with error %s",
+					filename, err,
+				)
+			}
+			defer pgr.Close()
+
+			pages := make([]*data.Page, 64)
+			for i := range pages {
+				pages[i] = pgr.Alloc().WithNum(nextFree(b, pgr.Freelist()))
+			}
+			if _, err := pgr.Flush(); err != nil {
+				b.Fatalf("Failed to flush pager, with error %s", err)
+			}
+
+			var next atomic.Int64
+
+			b.SetParallelism(16)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				pg := pages[next.Add(1)%int64(len(pages))]
+				for pb.Next() {
+					if err := pgr.Write(pg); err != nil {
+						b.Errorf("Failed to write page %d, with error %s", pg.Num, err)
+						return
+					}
+					if err := pgr.Commit(); err != nil {
+						b.Errorf("Failed to commit, with error %s", err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
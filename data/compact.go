@@ -0,0 +1,276 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Compact moves live pages into the lowest free page numbers, so that all
+// free space ends up at the tail, and truncates the file. Stable ids and
+// the Root and Buckets pages of the metainfo are rewritten to the new page
+// numbers in the same flush that commits the relocation, so Resolve keeps
+// returning the right page. Page numbers kept anywhere else, such as in
+// the pages themselves, are left as they were: layers keeping those use
+// Defrag and fix them. The relocation is committed by one flush and the
+// freed pages by a second one, so the store reopens as it was before if
+// Compact fails or crashes before the first completes. Compact refuses to
+// run while a transaction is in progress or a Store owns the pager.
+func (pgr *Pager) Compact() error {
+	pgr.commitMu.Lock()
+	defer pgr.commitMu.Unlock()
+
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if _, err := pgr.defrag(); err != nil {
+		return fmt.Errorf("pager/compact: %w", err)
+	}
+
+	return nil
+}
+
+// Defrag is Compact for layers that keep page numbers of their own: it
+// returns where every relocated page went, keyed by its old number, so
+// they can fix their pointers. Pages that stay put are not in the mapping.
+// Stable ids and the Root and Buckets pages of the metainfo are remapped
+// by the pager, every other pointer is the caller's to fix. Like Compact,
+// Defrag leaves the store as it was when it fails before the relocation
+// is flushed, and pages keep their old numbers then. Defrag runs offline:
+// pages pending for readers are treated as free, and it fails with
+// ErrTxInProgress while a transaction is in progress and with
+// ErrStoreOpen once a Store owns the pager.
+func (pgr *Pager) Defrag() (map[PageNum]PageNum, error) {
+	pgr.commitMu.Lock()
+	defer pgr.commitMu.Unlock()
+
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	moved, err := pgr.defrag()
+	if err != nil {
+		return nil, fmt.Errorf("pager/defrag: %w", err)
+	}
+
+	return moved, nil
+}
+
+func (pgr *Pager) defrag() (map[PageNum]PageNum, error) {
+	if pgr.closed {
+		return nil, ErrClosed
+	}
+
+	if pgr.opts.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	if pgr.tx != nil {
+		return nil, ErrTxInProgress
+	}
+
+	if pgr.owned {
+		return nil, ErrStoreOpen
+	}
+
+	moved, quarantined, err := pgr.compact()
+	if err != nil {
+		return nil, err
+	}
+
+	for id, num := range pgr.ids.Entries {
+		if to, ok := moved[num]; ok {
+			pgr.ids.Entries[id] = to
+		}
+	}
+
+	for _, root := range []*PageNum{&pgr.meta.Root, &pgr.meta.Buckets} {
+		if to, ok := moved[*root]; ok {
+			*root = to
+		}
+	}
+
+	// The first flush commits the relocation. Only once it is durable are
+	// the pages the previous metainfo used free, the second flush
+	// releases them and trims the tail.
+	if err := pgr.flush(context.Background()); err != nil {
+		return nil, err
+	}
+
+	if err := pgr.releaseCompacted(quarantined); err != nil {
+		return nil, err
+	}
+
+	fileSize, err := pgr.storeSize()
+	if err != nil {
+		return nil, err
+	}
+
+	filePages := PageNum((fileSize + int64(pgr.psize) - 1) / int64(pgr.psize))
+	if err := pgr.forgetPages(pgr.flist.Max, filePages); err != nil {
+		return nil, err
+	}
+
+	if err := pgr.flush(context.Background()); err != nil {
+		return nil, err
+	}
+
+	size := pgr.tailSize()
+	if err := pgr.truncateStore(size); err != nil {
+		return nil, fmt.Errorf("truncate file: %w", err)
+	}
+	pgr.fileSize = size
+
+	return moved, nil
+}
+
+// compact relocates live pages to the front of the file and resets the
+// freelists for the flush committing the relocation. That flush may only
+// write to pages the latest metainfo does not use: the chains it
+// references stay where they are, and the pages relocated from and the
+// holes past the compacted ones are quarantined, returned for
+// releaseCompacted once the flush is durable. The freelist holds only the
+// holes set aside at the end of the compacted pages for the chains the
+// flush writes. Other chain pages are treated as free.
+func (pgr *Pager) compact() (map[PageNum]PageNum, []PageNum, error) {
+	pinned := make(map[PageNum]struct{})
+	for _, num := range slices.Concat(pgr.flistChain.cur, pgr.classChain.cur, pgr.idChain.cur) {
+		if num >= pgr.flist.begin {
+			pinned[num] = struct{}{}
+		}
+	}
+
+	free := pgr.freePages()
+
+	var live, holes, movable []PageNum
+	for num := pgr.flist.begin; num < pgr.flist.Max; num++ {
+		if _, ok := pinned[num]; ok {
+			continue
+		}
+
+		if _, ok := free[num]; ok {
+			holes = append(holes, num)
+		} else {
+			live = append(live, num)
+		}
+		movable = append(movable, num)
+	}
+
+	// The compacted pages take the lowest page numbers that are not
+	// pinned, enough for the live pages and the chains.
+	regionEnd := pgr.flist.begin
+	if n := min(len(movable), len(live)+pgr.compactChainPages()); n > 0 {
+		regionEnd = movable[n-1] + 1
+	}
+
+	var outside, inside []PageNum
+	for _, num := range live {
+		if num >= regionEnd {
+			outside = append(outside, num)
+		}
+	}
+
+	var quarantined []PageNum
+	for _, num := range holes {
+		if num < regionEnd {
+			inside = append(inside, num)
+		} else {
+			quarantined = append(quarantined, num)
+		}
+	}
+
+	moved := make(map[PageNum]PageNum)
+	for i, num := range outside {
+		if i >= len(inside) {
+			return nil, nil, fmt.Errorf("relocate page %d: no hole left", num)
+		}
+
+		pg, err := pgr.read(num)
+		if err != nil {
+			return nil, nil, fmt.Errorf("relocate page %d: %w", num, err)
+		}
+
+		if err := pgr.write(pg.WithNum(inside[i]), false); err != nil {
+			return nil, nil, fmt.Errorf("relocate page %d: %w", num, err)
+		}
+
+		moved[num] = inside[i]
+		quarantined = append(quarantined, num)
+	}
+
+	pgr.flist.Released = slices.Clone(inside[len(outside):])
+	pgr.flist.Pending = nil
+	pgr.flist.Defragment()
+	pgr.flist.set(flistDirty, true)
+
+	flistCur := pgr.flistChain.cur
+	pgr.resetFlistChain()
+	if len(flistCur) > 0 && flistCur[0] == pgr.meta.Freelist {
+		pgr.flistChain.cur = flistCur
+	}
+
+	pgr.classes = NewFreelistSet()
+	pgr.classChain = shadowChain{cur: pgr.classChain.cur}
+	pgr.idChain = shadowChain{cur: pgr.idChain.cur}
+
+	return moved, quarantined, nil
+}
+
+// compactChainPages returns the number of pages the flush committing a
+// relocation writes the pager structures to, past the reserved region:
+// the stable ids, the emptied class pools and the overflow of a freelist
+// holding about as many pages.
+func (pgr *Pager) compactChainPages() int {
+	n := 0
+	if len(pgr.idChain.cur) != 0 || len(pgr.ids.Entries) != 0 || pgr.ids.Last != 0 {
+		n += pgr.chainLen(len(pgr.ids.Serialize()))
+	}
+	if len(pgr.classChain.cur) != 0 {
+		n += pgr.chainLen(len(NewFreelistSet().Serialize()))
+	}
+	return n + pgr.chainLen(freelistHeaderSize+8*n) - 1
+}
+
+// releaseCompacted releases the pages quarantined by compact, and the
+// spare chains, once the flush committing the relocation is durable: the
+// next flush overwrites the metainfo in the other slot, the only one they
+// belonged to. Reserved freelist heads stay spares. The free pages at the
+// tail are then trimmed.
+func (pgr *Pager) releaseCompacted(quarantined []PageNum) error {
+	nums := slices.Concat(
+		quarantined,
+		pgr.flistChain.dropSpare(pgr.flist.begin),
+		pgr.classChain.dropSpare(pgr.flist.begin),
+		pgr.idChain.dropSpare(pgr.flist.begin),
+	)
+	if err := pgr.flist.ReleaseAll(nums); err != nil {
+		return fmt.Errorf("release compacted: %w", err)
+	}
+
+	pgr.flist.Defragment()
+	pgr.flist.trimTail()
+
+	return nil
+}
+
+// freePages returns the pages below Max that hold no data: released,
+// pending and pooled pages, and the chain pages holding pager structures.
+func (pgr *Pager) freePages() map[PageNum]struct{} {
+	free := make(map[PageNum]struct{})
+	for _, num := range pgr.flist.Released {
+		free[num] = struct{}{}
+	}
+	for _, nums := range pgr.flist.Pending {
+		for _, num := range nums {
+			free[num] = struct{}{}
+		}
+	}
+	for _, pool := range pgr.classes.Pools {
+		for _, num := range pool {
+			free[num] = struct{}{}
+		}
+	}
+	for _, num := range slices.Concat(pgr.flistChain.pages(), pgr.classChain.pages(), pgr.idChain.pages()) {
+		free[num] = struct{}{}
+	}
+	return free
+}
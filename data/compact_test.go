@@ -0,0 +1,400 @@
+package data_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_CompactStableIDs(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	ids := make(map[data.StableID]string)
+	var nums []data.PageNum
+	for i := 0; i < 6; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+
+		nums = append(nums, pg.Num)
+		ids[pgr.AssignID(pg.Num)] = fmt.Sprintf("data%d", i+1)
+	}
+
+	lastID := pgr.AssignID(nums[len(nums)-1])
+	ids[lastID] = "data6"
+
+	for id, num := range map[data.StableID]data.PageNum{2: nums[1], 4: nums[3]} {
+		pgr.ForgetID(id)
+		delete(ids, id)
+		pgr.Freelist().Release(num)
+	}
+
+	if err := pgr.Compact(); err != nil {
+		t.Fatalf("Failed to compact pager, with error %s", err)
+	}
+
+	if num, _ := pgr.Resolve(lastID); num >= nums[len(nums)-1] {
+		t.Fatalf(
+			"Failed to check relocation: page %d was not moved, resolved to %d",
+			nums[len(nums)-1], num,
+		)
+	}
+
+	assertIDs := func(t *testing.T, pgr *data.Pager) {
+		t.Helper()
+
+		for id, expectedPgData := range ids {
+			num, err := pgr.Resolve(id)
+			if err != nil {
+				t.Fatalf("Failed to resolve id %d, with error %s", id, err)
+			}
+
+			pg, err := pgr.Read(num)
+			if err != nil {
+				t.Fatalf("Failed to read page %d, with error %s", num, err)
+			}
+
+			if actualPgData := string(bytes.TrimRight(pg.Data, "\x00")); expectedPgData != actualPgData {
+				t.Fatalf(
+					"Failed to compare page data of id %d: expected %s, actual %s",
+					id, expectedPgData, actualPgData,
+				)
+			}
+		}
+
+		if _, err := pgr.Resolve(2); !errors.Is(err, data.ErrUnknownID) {
+			t.Fatalf(
+				"Failed to resolve forgotten id: expected error %s, actual %v",
+				data.ErrUnknownID, err,
+			)
+		}
+	}
+
+	assertIDs(t, pgr)
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	pgr, err = data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to open pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	assertIDs(t, pgr)
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("Failed to stat file %s, with error %s", filename, err)
+	}
+
+	if expected := int64(pgr.Freelist().Max) * int64(psize); info.Size() != expected {
+		t.Fatalf(
+			"Failed to compare file size: expected %d, actual %d",
+			expected, info.Size(),
+		)
+	}
+}
+
+func TestPager_CompactRoots(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	var nums []data.PageNum
+	for i := 0; i < 6; i++ {
+		num, err := pgr.Append([]byte(fmt.Sprintf("data%d", i+1)))
+		if err != nil {
+			t.Fatalf("Failed to append page, with error %s", err)
+		}
+		nums = append(nums, num)
+	}
+	for _, num := range nums[:2] {
+		if err := pgr.ReleasePage(num); err != nil {
+			t.Fatalf("Failed to release page %d, with error %s", num, err)
+		}
+	}
+	pgr.Meta().Root, pgr.Meta().Buckets = nums[5], nums[4]
+
+	if err := pgr.Compact(); err != nil {
+		t.Fatalf("Failed to compact pager, with error %s", err)
+	}
+
+	for name, root := range map[string]data.PageNum{"root": pgr.Meta().Root, "buckets": pgr.Meta().Buckets} {
+		if root >= nums[4] {
+			t.Fatalf("Failed to remap %s page: still at page %d", name, root)
+		}
+	}
+
+	pg, err := pgr.Read(pgr.Meta().Root)
+	if err != nil {
+		t.Fatalf("Failed to read root page %d, with error %s", pgr.Meta().Root, err)
+	}
+	if actual := string(bytes.TrimRight(pg.Data, "\x00")); actual != "data6" {
+		t.Fatalf("Failed to compare root page data: expected %q, actual %q", "data6", actual)
+	}
+}
+
+func TestPager_CompactInUse(t *testing.T) {
+	psize := os.Getpagesize()
+
+	t.Run("transaction", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "test_data")
+
+		pgr, err := data.NewPager(filename, psize)
+		if err != nil {
+			t.Fatalf("Failed to create pager by path %s, with error %s", filename, err)
+		}
+		defer pgr.Close()
+
+		tx, err := pgr.Begin()
+		if err != nil {
+			t.Fatalf("Failed to begin transaction, with error %s", err)
+		}
+
+		if err := pgr.Compact(); !errors.Is(err, data.ErrTxInProgress) {
+			t.Fatalf("Failed to compact during transaction: expected error %s, actual %v", data.ErrTxInProgress, err)
+		}
+		if err := tx.Rollback(); err != nil {
+			t.Fatalf("Failed to roll back transaction, with error %s", err)
+		}
+
+		if err := pgr.Compact(); err != nil {
+			t.Fatalf("Failed to compact pager, with error %s", err)
+		}
+	})
+
+	t.Run("store", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "test_data")
+
+		pgr, err := data.NewPager(filename, psize)
+		if err != nil {
+			t.Fatalf("Failed to create pager by path %s, with error %s", filename, err)
+		}
+
+		s, err := data.OpenStore(pgr)
+		if err != nil {
+			t.Fatalf("Failed to open store, with error %s", err)
+		}
+		defer s.Close()
+
+		if err := pgr.Compact(); !errors.Is(err, data.ErrStoreOpen) {
+			t.Fatalf("Failed to compact pager of open store: expected error %s, actual %v", data.ErrStoreOpen, err)
+		}
+	})
+}
+
+func TestPager_Defrag(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	contents := make(map[data.PageNum]string)
+	for i := 0; i < 20; i++ {
+		payload := fmt.Sprintf("data%d", i+1)
+
+		num, err := pgr.Append([]byte(payload))
+		if err != nil {
+			t.Fatalf("Failed to append page, with error %s", err)
+		}
+		contents[num] = payload
+	}
+
+	// Free the low pages and every other high one, leaving the live data
+	// scattered among high page numbers.
+	for num := range contents {
+		if num < data.BeginFreeBlocks+10 || num%2 == 0 {
+			if err := pgr.ReleasePage(num); err != nil {
+				t.Fatalf("Failed to release page %d, with error %s", num, err)
+			}
+			delete(contents, num)
+		}
+	}
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	before, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("Failed to stat file %s, with error %s", filename, err)
+	}
+
+	var root data.PageNum
+	for num := range contents {
+		root = max(root, num)
+	}
+	pgr.Meta().Root = root
+
+	tx, err := pgr.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction, with error %s", err)
+	}
+	if _, err := pgr.Defrag(); !errors.Is(err, data.ErrTxInProgress) {
+		t.Fatalf("Failed to defrag during transaction: expected error %s, actual %v", data.ErrTxInProgress, err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Failed to roll back transaction, with error %s", err)
+	}
+
+	moved, err := pgr.Defrag()
+	if err != nil {
+		t.Fatalf("Failed to defrag pager, with error %s", err)
+	}
+	if len(moved) == 0 {
+		t.Fatalf("Failed to relocate scattered pages: empty mapping")
+	}
+	if to, ok := moved[root]; !ok || pgr.Meta().Root != to {
+		t.Fatalf("Failed to remap root page %d: expected %d, actual %d", root, to, pgr.Meta().Root)
+	}
+
+	for num, payload := range contents {
+		if to, ok := moved[num]; ok {
+			num = to
+		}
+		if num >= data.BeginFreeBlocks+data.PageNum(len(contents)) {
+			t.Fatalf("Failed to move page holding %q to the front: at page %d", payload, num)
+		}
+
+		pg, err := pgr.Read(num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", num, err)
+		}
+		if actual := string(bytes.TrimRight(pg.Data, "\x00")); actual != payload {
+			t.Fatalf("Failed to compare relocated page %d: expected %q, actual %q", num, payload, actual)
+		}
+	}
+
+	after, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("Failed to stat file %s, with error %s", filename, err)
+	}
+	if after.Size() >= before.Size() {
+		t.Fatalf("Failed to truncate file: %d bytes before defrag, %d after", before.Size(), after.Size())
+	}
+
+	if err := pgr.Verify(); err != nil {
+		t.Fatalf("Failed to verify defragmented pager, with error %s", err)
+	}
+}
+
+func TestPager_CompactFailedFlush(t *testing.T) {
+	for name, relocate := range map[string]func(pgr *data.Pager) error{
+		"compact": func(pgr *data.Pager) error { return pgr.Compact() },
+		"defrag": func(pgr *data.Pager) error {
+			_, err := pgr.Defrag()
+			return err
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			psize := os.Getpagesize()
+			be := new(failingBackend)
+
+			pgr, err := data.NewPagerFromBackend(be, psize)
+			if err != nil {
+				t.Fatalf("Failed to create pager from backend, with error %s", err)
+			}
+
+			ids := make(map[data.StableID]string)
+			var nums []data.PageNum
+			write := func(n int) {
+				for i := 0; i < n; i++ {
+					payload := fmt.Sprintf("data%d", len(nums)+1)
+
+					num, err := pgr.Append([]byte(payload))
+					if err != nil {
+						t.Fatalf("Failed to append page, with error %s", err)
+					}
+					nums = append(nums, num)
+					ids[pgr.AssignID(num)] = payload
+				}
+				if _, err := pgr.Flush(); err != nil {
+					t.Fatalf("Failed to flush pager, with error %s", err)
+				}
+			}
+
+			// The id chain of the first flush lies below the pages of the
+			// second, so relocation has a hole past it to fill.
+			write(6)
+			write(6)
+
+			for id, num := range map[data.StableID]data.PageNum{1: nums[0], 2: nums[1]} {
+				pgr.ForgetID(id)
+				delete(ids, id)
+				if err := pgr.ReleasePage(num); err != nil {
+					t.Fatalf("Failed to release page %d, with error %s", num, err)
+				}
+			}
+			if _, err := pgr.Flush(); err != nil {
+				t.Fatalf("Failed to flush pager, with error %s", err)
+			}
+
+			be.failWrite = func(off int64) bool {
+				return off == int64(data.DefaultMetaPage)*int64(psize) || off == int64(data.ShadowMetaPage)*int64(psize)
+			}
+			if err := relocate(pgr); !errors.Is(err, errBackend) {
+				t.Fatalf(
+					"Failed to relocate pages: expected error %s, actual %v",
+					errBackend, err,
+				)
+			}
+
+			reopened, err := data.NewPagerFromBackend(&sliceBackend{b: be.b}, psize)
+			if err != nil {
+				t.Fatalf("Failed to reopen pager from backend, with error %s", err)
+			}
+			defer reopened.Close()
+
+			for id, payload := range ids {
+				num, err := reopened.Resolve(id)
+				if err != nil {
+					t.Fatalf("Failed to resolve id %d, with error %s", id, err)
+				}
+
+				pg, err := reopened.Read(num)
+				if err != nil {
+					t.Fatalf("Failed to read page %d, with error %s", num, err)
+				}
+				if actual := string(bytes.TrimRight(pg.Data, "\x00")); actual != payload {
+					t.Fatalf("Failed to compare page %d of id %d: expected %q, actual %q", num, id, payload, actual)
+				}
+			}
+		})
+	}
+}
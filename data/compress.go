@@ -0,0 +1,169 @@
+package data
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Codec compresses the payload of data pages with page compression. A page
+// whose compressed form is not smaller than its data is stored raw, so
+// Compress may return anything for data it cannot shrink. The codec is
+// part of the on-disk format: a file must always be opened with the same
+// one.
+//
+// A compressed page still takes a whole page on disk, zero-padded past
+// its compressed body, so compression does not make the file smaller.
+type Codec interface {
+	Compress(b []byte) []byte
+	Decompress(b []byte) ([]byte, error)
+}
+
+// NopCodec leaves data as is, so every page is stored raw behind the
+// compression header.
+type NopCodec struct{}
+
+func (NopCodec) Compress(b []byte) []byte { return b }
+
+func (NopCodec) Decompress(b []byte) ([]byte, error) { return b, nil }
+
+// FlateCodec compresses with DEFLATE at BestSpeed. It is the codec used
+// when page compression is enabled without one.
+type FlateCodec struct{}
+
+func (FlateCodec) Compress(b []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	_, _ = w.Write(b)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func (FlateCodec) Decompress(b []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(b))
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// GzipCodec compresses into the gzip format at BestSpeed.
+type GzipCodec struct{}
+
+func (GzipCodec) Compress(b []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	_, _ = w.Write(b)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func (GzipCodec) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// compressHeaderSize is the header of a data page stored with page
+// compression: a storage flag followed by the stored body length.
+const compressHeaderSize = 1 + 4
+
+const (
+	pageStoredRaw byte = iota
+	pageStoredCompressed
+)
+
+// MaxLogicalPayload is the number of bytes of a data page that are
+// guaranteed to be stored. With page compression a full page of data is
+// accepted only when it compresses below the page size; otherwise the
+// page is stored raw and only this many leading bytes may be non-zero.
+func (pgr *Pager) MaxLogicalPayload() int {
+	if !pgr.opts.PageCompression {
+		return pgr.payloadSize()
+	}
+	return pgr.payloadSize() - compressHeaderSize
+}
+
+// encodePage turns the logical contents of page num into the payload
+// stored on disk.
+func (pgr *Pager) encodePage(num PageNum, data []byte) ([]byte, error) {
+	if !pgr.encodes(num) {
+		return data, nil
+	}
+
+	compressed := pgr.opts.codec().Compress(data)
+
+	b := make([]byte, pgr.payloadSize())
+
+	if len(compressed) < len(data) && compressHeaderSize+len(compressed) <= len(b) {
+		b[0] = pageStoredCompressed
+		binary.LittleEndian.PutUint32(b[1:5], uint32(len(compressed)))
+		copy(b[compressHeaderSize:], compressed)
+		return b, nil
+	}
+
+	payload := pgr.MaxLogicalPayload()
+	if len(data) > payload && !isZero(data[payload:]) {
+		return nil, fmt.Errorf("encode page: %w", ErrDataTooLarge)
+	}
+
+	b[0] = pageStoredRaw
+	binary.LittleEndian.PutUint32(b[1:5], uint32(min(len(data), payload)))
+	copy(b[compressHeaderSize:], data[:min(len(data), payload)])
+
+	return b, nil
+}
+
+// encodes reports whether page num is stored differently from its data:
+// with page compression, every page past the reserved region.
+func (pgr *Pager) encodes(num PageNum) bool {
+	return pgr.opts.PageCompression && num >= pgr.flist.begin
+}
+
+// decodePage restores the logical contents of page num from the payload
+// stored on disk into dst.
+func (pgr *Pager) decodePage(num PageNum, b, dst []byte) error {
+	if !pgr.encodes(num) {
+		copy(dst, b)
+		return nil
+	}
+
+	size := int(binary.LittleEndian.Uint32(b[1:5]))
+	if size > len(b)-compressHeaderSize {
+		return fmt.Errorf("decode page: %w", ErrWrongBytes)
+	}
+	body := b[compressHeaderSize : compressHeaderSize+size]
+
+	switch b[0] {
+	case pageStoredRaw:
+		clear(dst[copy(dst, body):])
+	case pageStoredCompressed:
+		data, err := pgr.opts.codec().Decompress(body)
+		if err != nil {
+			return fmt.Errorf("decode page: %w", err)
+		}
+		if len(data) > len(dst) {
+			return fmt.Errorf("decode page: %d bytes decompressed: %w", len(data), ErrWrongBytes)
+		}
+		clear(dst[copy(dst, data):])
+	default:
+		return fmt.Errorf("decode page: unknown storage flag %d: %w", b[0], ErrWrongBytes)
+	}
+
+	return nil
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
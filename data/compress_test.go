@@ -0,0 +1,129 @@
+package data_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_PageCompression(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPagerWithOptions(filename, psize, data.WithPageCompression(true))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	compressible := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+	copy(compressible.Data, bytes.Repeat([]byte("embedstore"), psize/10))
+
+	incompressible := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+	_, _ = rand.Read(incompressible.Data[:pgr.MaxLogicalPayload()])
+
+	tooLarge := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+	_, _ = rand.Read(tooLarge.Data)
+
+	for _, pg := range []*data.Page{compressible, incompressible} {
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %d, with error %s", pg.Num, err)
+		}
+
+		actualPg, err := pgr.Read(pg.Num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", pg.Num, err)
+		}
+
+		if !bytes.Equal(pg.Data, actualPg.Data) {
+			t.Fatalf("Failed to compare page %d data after compression round-trip", pg.Num)
+		}
+	}
+
+	if err := pgr.Write(tooLarge); !errors.Is(err, data.ErrDataTooLarge) {
+		t.Fatalf(
+			"Failed to write incompressible full page: expected error %s, actual %v",
+			data.ErrDataTooLarge, err,
+		)
+	}
+}
+
+func TestPager_Codec(t *testing.T) {
+	psize := os.Getpagesize()
+
+	tests := []struct {
+		name       string
+		codec      data.Codec
+		compresses bool
+	}{
+		{name: "nop", codec: data.NopCodec{}, compresses: false},
+		{name: "flate", codec: data.FlateCodec{}, compresses: true},
+		{name: "gzip", codec: data.GzipCodec{}, compresses: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "test_data")
+
+			pgr, err := data.NewPagerWithOptions(filename, psize, data.WithCodec(tt.codec))
+			if err != nil {
+				t.Fatalf(
+					"Failed to create pager by path %s, with error %s",
+					filename, err,
+				)
+			}
+			defer pgr.Close()
+
+			pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+			copy(pg.Data, bytes.Repeat([]byte("embedstore"), pgr.MaxLogicalPayload()/10))
+
+			if err := pgr.Write(pg); err != nil {
+				t.Fatalf("Failed to write page %d, with error %s", pg.Num, err)
+			}
+
+			actualPg, err := pgr.Read(pg.Num)
+			if err != nil {
+				t.Fatalf("Failed to read page %d, with error %s", pg.Num, err)
+			}
+
+			if !bytes.Equal(pg.Data, actualPg.Data) {
+				t.Fatalf("Failed to compare page %d data after codec round-trip", pg.Num)
+			}
+
+			b, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("Failed to read file %s, with error %s", filename, err)
+			}
+
+			// The payload follows the page header and starts with the
+			// storage flag and the length of the stored body.
+			stored := b[int(pg.Num)*psize : int(pg.Num+1)*psize]
+			body := stored[psize-len(pg.Data):]
+			compressed, size := body[0] == 1, int(binary.LittleEndian.Uint32(body[1:5]))
+
+			if compressed != tt.compresses {
+				t.Fatalf("Failed to check page %d is stored compressed: expected %t, actual %t", pg.Num, tt.compresses, compressed)
+			}
+
+			expected := pgr.MaxLogicalPayload()
+			if tt.compresses {
+				expected = len(tt.codec.Compress(pg.Data))
+			}
+			if size != expected {
+				t.Fatalf("Failed to compare page %d stored length: expected %d, actual %d", pg.Num, expected, size)
+			}
+			if tt.compresses && size >= psize/2 {
+				t.Fatalf("Failed to shrink page %d: %d of %d bytes stored", pg.Num, size, len(pg.Data))
+			}
+		})
+	}
+}
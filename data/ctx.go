@@ -0,0 +1,42 @@
+package data
+
+import (
+	"context"
+	"fmt"
+)
+
+// ReadCtx is Read returning early when ctx is already done.
+func (pgr *Pager) ReadCtx(ctx context.Context, num PageNum) (*Page, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("pager/read(num=%d): %w", num, err)
+	}
+
+	return pgr.Read(num)
+}
+
+// WriteCtx is Write returning early when ctx is already done.
+func (pgr *Pager) WriteCtx(ctx context.Context, pg *Page) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("pager/write(num=%d): %w", pg.Num, err)
+	}
+
+	return pgr.Write(pg)
+}
+
+// WriteBatchCtx is WriteBatch checking ctx before every run of pages. On
+// cancellation the runs written so far stay written.
+func (pgr *Pager) WriteBatchCtx(ctx context.Context, pages []*Page) error {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	return pgr.writeBatch(ctx, pages)
+}
+
+// FlushCtx is Flush checking ctx between its steps, up to the write of
+// the meta page. Once that is written the flush runs to completion.
+func (pgr *Pager) FlushCtx(ctx context.Context) (changed bool, err error) {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	return pgr.flushChanged(ctx)
+}
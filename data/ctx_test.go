@@ -0,0 +1,69 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_Ctx(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	// Every other page, so that each page is a run of its own.
+	pages := make([]*data.Page, 1000)
+	for i := range pages {
+		pages[i] = pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		pages[i].Write([]byte("data"))
+		nextFree(t, pgr.Freelist())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := pgr.WriteBatchCtx(ctx, pages); !errors.Is(err, context.Canceled) {
+		t.Fatalf(
+			"Failed to write batch with cancelled context: expected error %s, actual %v",
+			context.Canceled, err,
+		)
+	}
+
+	stats, err := pgr.Stats()
+	if err != nil {
+		t.Fatalf("Failed to get pager stats, with error %s", err)
+	}
+
+	if last := int64(pages[len(pages)-1].Num+1) * int64(os.Getpagesize()); stats.FileSize >= last {
+		t.Fatalf("Failed to check cancelled batch: all %d pages were written", len(pages))
+	}
+
+	if _, err := pgr.ReadCtx(ctx, pages[0].Num); !errors.Is(err, context.Canceled) {
+		t.Fatalf(
+			"Failed to read with cancelled context: expected error %s, actual %v",
+			context.Canceled, err,
+		)
+	}
+
+	if _, err := pgr.FlushCtx(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf(
+			"Failed to flush with cancelled context: expected error %s, actual %v",
+			context.Canceled, err,
+		)
+	}
+
+	if _, err := pgr.FlushCtx(context.Background()); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+}
@@ -0,0 +1,251 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+)
+
+// cursor is a position between two entries of a btree, kept as the path
+// of nodes from the root to a leaf. In every branch frame i is the child
+// on the path, in the leaf frame it is the entry after the position.
+type cursor struct {
+	tree  *btree
+	stack []cursorFrame
+}
+
+type cursorFrame struct {
+	n *node
+	i int
+}
+
+func (c *cursor) leaf() *cursorFrame {
+	return &c.stack[len(c.stack)-1]
+}
+
+// descend extends the path from num down to a leaf, keeping to the left
+// or to the right edge of every subtree.
+func (c *cursor) descend(num PageNum, right bool) error {
+	for {
+		n, err := c.tree.readNode(num)
+		if err != nil {
+			return err
+		}
+
+		i := 0
+		switch {
+		case n.leaf && right:
+			i = len(n.keys)
+		case right:
+			i = len(n.children) - 1
+		}
+		c.stack = append(c.stack, cursorFrame{n: n, i: i})
+
+		if n.leaf {
+			return nil
+		}
+		num = n.children[i]
+	}
+}
+
+// seek positions the cursor before the first key not less than key.
+func (c *cursor) seek(key []byte) error {
+	c.stack = c.stack[:0]
+
+	for num := c.tree.root; num != 0; {
+		n, err := c.tree.readNode(num)
+		if err != nil {
+			return err
+		}
+
+		if n.leaf {
+			i, _ := n.search(key)
+			c.stack = append(c.stack, cursorFrame{n: n, i: i})
+			return nil
+		}
+
+		i := n.childIndex(key)
+		c.stack = append(c.stack, cursorFrame{n: n, i: i})
+		num = n.children[i]
+	}
+
+	return nil
+}
+
+// last positions the cursor after the last key.
+func (c *cursor) last() error {
+	c.stack = c.stack[:0]
+	if c.tree.root == 0 {
+		return nil
+	}
+
+	return c.descend(c.tree.root, true)
+}
+
+// next moves the cursor over the entry after it and returns the entry.
+// At the end of the tree the cursor stays where it is.
+func (c *cursor) next() ([]byte, []byte, bool, error) {
+	if len(c.stack) == 0 {
+		return nil, nil, false, nil
+	}
+
+	for leaf := c.leaf(); leaf.i >= len(leaf.n.keys); leaf = c.leaf() {
+		depth := len(c.stack) - 1
+		for depth > 0 && c.stack[depth-1].i+1 >= len(c.stack[depth-1].n.children) {
+			depth--
+		}
+		if depth == 0 {
+			return nil, nil, false, nil
+		}
+
+		parent := &c.stack[depth-1]
+		parent.i++
+		c.stack = c.stack[:depth]
+		if err := c.descend(parent.n.children[parent.i], false); err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	leaf := c.leaf()
+	leaf.i++
+
+	return leaf.n.keys[leaf.i-1], leaf.n.vals[leaf.i-1], true, nil
+}
+
+// prev moves the cursor back over the entry before it and returns the
+// entry. At the start of the tree the cursor stays where it is.
+func (c *cursor) prev() ([]byte, []byte, bool, error) {
+	if len(c.stack) == 0 {
+		return nil, nil, false, nil
+	}
+
+	for leaf := c.leaf(); leaf.i == 0; leaf = c.leaf() {
+		depth := len(c.stack) - 1
+		for depth > 0 && c.stack[depth-1].i == 0 {
+			depth--
+		}
+		if depth == 0 {
+			return nil, nil, false, nil
+		}
+
+		parent := &c.stack[depth-1]
+		parent.i--
+		c.stack = c.stack[:depth]
+		if err := c.descend(parent.n.children[parent.i], true); err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	leaf := c.leaf()
+	leaf.i--
+
+	return leaf.n.keys[leaf.i], leaf.n.vals[leaf.i], true, nil
+}
+
+// Cursor walks the keys of a store or a bucket in order. A new cursor is
+// positioned before the first key. It is not safe for concurrent use.
+type Cursor struct {
+	s    *Store
+	root func() (PageNum, error)
+
+	c   cursor
+	err error
+}
+
+func newCursor(s *Store, root func() (PageNum, error)) *Cursor {
+	c := &Cursor{s: s, root: root}
+	c.Seek(nil)
+	return c
+}
+
+// reset points the cursor at the current tree of its keys.
+func (c *Cursor) reset() error {
+	c.err = nil
+
+	root, err := c.root()
+	if err != nil {
+		return err
+	}
+	c.c.tree = c.s.tree(root, nil)
+
+	return nil
+}
+
+// Seek positions the cursor before the first key not less than key, so
+// that Next returns that key and Prev the one before it. It clears the
+// error of the cursor.
+func (c *Cursor) Seek(key []byte) {
+	c.s.pgr.mu.RLock()
+	defer c.s.pgr.mu.RUnlock()
+
+	if err := c.reset(); err != nil {
+		c.err = fmt.Errorf("cursor/seek: %w", err)
+		return
+	}
+	if err := c.c.seek(key); err != nil {
+		c.err = fmt.Errorf("cursor/seek: %w", err)
+	}
+}
+
+// Last positions the cursor after the last key, so that Prev returns it.
+// It clears the error of the cursor.
+func (c *Cursor) Last() {
+	c.s.pgr.mu.RLock()
+	defer c.s.pgr.mu.RUnlock()
+
+	if err := c.reset(); err != nil {
+		c.err = fmt.Errorf("cursor/last: %w", err)
+		return
+	}
+	if err := c.c.last(); err != nil {
+		c.err = fmt.Errorf("cursor/last: %w", err)
+	}
+}
+
+// Next returns the key after the cursor with its value and moves past it.
+// It returns false at the end of the store or once an error occurred.
+func (c *Cursor) Next() ([]byte, []byte, bool) {
+	return c.step("cursor/next", c.c.next)
+}
+
+// Prev returns the key before the cursor with its value and moves back
+// over it. It returns false at the start of the store or once an error
+// occurred.
+func (c *Cursor) Prev() ([]byte, []byte, bool) {
+	return c.step("cursor/prev", c.c.prev)
+}
+
+func (c *Cursor) step(op string, move func() ([]byte, []byte, bool, error)) ([]byte, []byte, bool) {
+	if c.err != nil {
+		return nil, nil, false
+	}
+
+	c.s.pgr.mu.RLock()
+	defer c.s.pgr.mu.RUnlock()
+
+	if c.s.pgr.closed {
+		c.err = fmt.Errorf("%s: %w", op, ErrClosed)
+		return nil, nil, false
+	}
+
+	key, head, ok, err := move()
+	if err != nil {
+		c.err = fmt.Errorf("%s: %w", op, err)
+		return nil, nil, false
+	}
+	if !ok {
+		return nil, nil, false
+	}
+
+	value, _, err := c.s.pgr.readChain(valueHead(head))
+	if err != nil {
+		c.err = fmt.Errorf("%s: key %q: %w", op, key, err)
+		return nil, nil, false
+	}
+
+	return slices.Clone(key), value, true
+}
+
+// Err returns the first error the cursor ran into.
+func (c *Cursor) Err() error {
+	return c.err
+}
@@ -0,0 +1,133 @@
+package data_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestCursor(t *testing.T) {
+	openStore := func(t *testing.T) *data.Store {
+		t.Helper()
+
+		// Small pages give the tree several levels.
+		pgr, err := data.NewMemPager(512)
+		if err != nil {
+			t.Fatalf("Failed to create in-memory pager, with error %s", err)
+		}
+
+		s, err := data.OpenStore(pgr)
+		if err != nil {
+			_ = pgr.Close()
+			t.Fatalf("Failed to open store, with error %s", err)
+		}
+		t.Cleanup(func() { _ = s.Close() })
+
+		return s
+	}
+
+	// Keys are even numbers, so odd ones fall into gaps.
+	keys := make([]string, 500)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%04d", 2*i)
+	}
+
+	s := openStore(t)
+	for _, key := range keys {
+		if err := s.Put([]byte(key), []byte("value "+key)); err != nil {
+			t.Fatalf("Failed to put key %s, with error %s", key, err)
+		}
+	}
+
+	next := func(t *testing.T, c *data.Cursor, expected string) {
+		t.Helper()
+
+		key, value, ok := c.Next()
+		if !ok || string(key) != expected || string(value) != "value "+expected {
+			t.Fatalf(
+				"Failed to compare next entry: expected %s, actual %s=%s (ok %t, error %v)",
+				expected, key, value, ok, c.Err(),
+			)
+		}
+	}
+
+	t.Run("seek exact", func(t *testing.T) {
+		c := s.Cursor()
+		c.Seek([]byte("key0400"))
+
+		next(t, c, "key0400")
+		next(t, c, "key0402")
+	})
+
+	t.Run("seek gap", func(t *testing.T) {
+		c := s.Cursor()
+		c.Seek([]byte("key0401"))
+
+		if key, _, ok := c.Prev(); !ok || string(key) != "key0400" {
+			t.Fatalf("Failed to compare previous key: expected key0400, actual %s (ok %t)", key, ok)
+		}
+		next(t, c, "key0400")
+		next(t, c, "key0402")
+	})
+
+	t.Run("seek past last", func(t *testing.T) {
+		c := s.Cursor()
+		c.Seek([]byte("key9999"))
+
+		if key, _, ok := c.Next(); ok {
+			t.Fatalf("Failed to seek past last key: got key %s", key)
+		}
+
+		if key, _, ok := c.Prev(); !ok || string(key) != keys[len(keys)-1] {
+			t.Fatalf("Failed to compare last key: expected %s, actual %s (ok %t)", keys[len(keys)-1], key, ok)
+		}
+	})
+
+	t.Run("forward", func(t *testing.T) {
+		c := s.Cursor()
+
+		var actual []string
+		for key, _, ok := c.Next(); ok; key, _, ok = c.Next() {
+			actual = append(actual, string(key))
+		}
+
+		if c.Err() != nil || !slices.Equal(keys, actual) {
+			t.Fatalf("Failed to iterate forward: expected %d keys, actual %v (error %v)", len(keys), actual, c.Err())
+		}
+	})
+
+	t.Run("backward", func(t *testing.T) {
+		c := s.Cursor()
+		c.Last()
+
+		var actual []string
+		for key, _, ok := c.Prev(); ok; key, _, ok = c.Prev() {
+			actual = append(actual, string(key))
+		}
+		slices.Reverse(actual)
+
+		if c.Err() != nil || !slices.Equal(keys, actual) {
+			t.Fatalf("Failed to iterate backward: expected %d keys, actual %v (error %v)", len(keys), actual, c.Err())
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		c := openStore(t).Cursor()
+
+		if _, _, ok := c.Next(); ok {
+			t.Fatal("Failed to iterate empty store: Next returned an entry")
+		}
+
+		c.Last()
+		if _, _, ok := c.Prev(); ok {
+			t.Fatal("Failed to iterate empty store: Prev returned an entry")
+		}
+
+		c.Seek([]byte("key"))
+		if _, _, ok := c.Next(); ok || c.Err() != nil {
+			t.Fatalf("Failed to seek in empty store: ok %t, error %v", ok, c.Err())
+		}
+	})
+}
@@ -1,10 +1,24 @@
 package data
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/cipher"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+	"log/slog"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -12,17 +26,107 @@ const (
 
 	DefaultMetaPage  PageNum = 0
 	DefaultFlistPage PageNum = DefaultMetaPage + 1
-
-	BeginFreeBlocks PageNum = DefaultFlistPage + 1
+	ShadowMetaPage   PageNum = DefaultFlistPage + 1
+	ShadowFlistPage  PageNum = ShadowMetaPage + 1
+
+	// BeginFreeBlocks is the first page a store hands out with the
+	// default reserved region, which holds the meta, freelist, shadow
+	// meta and shadow freelist pages. Stores created with
+	// WithReservedPages start later, see Freelist.Begin.
+	BeginFreeBlocks PageNum = ShadowFlistPage + 1
+
+	// minReservedPages is the reserved region of stores created before
+	// the shadow freelist page, which end it at the shadow meta page.
+	minReservedPages = ShadowMetaPage + 1
 )
 
-var ErrWrongBytes = errors.New("wrong number of bytes")
+// metaSlots are the two pages Flush alternates the metainfo between, so
+// that a torn meta write never destroys the last committed one.
+var metaSlots = [...]PageNum{DefaultMetaPage, ShadowMetaPage}
+
+// metaSlot returns the slot the metainfo of transaction txID is written to.
+func metaSlot(txID uint64) PageNum {
+	return metaSlots[1-txID%2]
+}
+
+// Errors returned by the pager wrap one of these, so callers can tell
+// failures apart with errors.Is.
+var (
+	ErrWrongBytes       = errors.New("wrong number of bytes")
+	ErrWrongPageSize    = errors.New("wrong page size")
+	ErrInvalidPageSize  = errors.New("invalid page size")
+	ErrClosed           = errors.New("pager closed")
+	ErrUnknownID        = errors.New("unknown stable id")
+	ErrDataTooLarge     = errors.New("data too large")
+	ErrPageSizeMismatch = errors.New("page size mismatch")
+	ErrLocked           = errors.New("store locked by another pager")
+
+	ErrBadMagic           = errors.New("bad magic number")
+	ErrUnsupportedVersion = errors.New("unsupported format version")
+	ErrChecksumMismatch   = errors.New("checksum mismatch")
+	ErrPageOutOfRange     = errors.New("page out of range")
+	ErrReadOnly           = errors.New("pager is read-only")
+	ErrShortWrite         = errors.New("short write")
+	ErrDecryptFailed      = errors.New("page decryption failed")
+	ErrPageTypeMismatch   = errors.New("page type mismatch")
+
+	ErrFreelistCorrupt  = errors.New("freelist corrupt")
+	ErrPageUnavailable  = errors.New("page unavailable")
+	ErrPageNotAllocated = errors.New("page not allocated")
+	ErrPageFree         = errors.New("page already free")
+	ErrCorruptChain     = errors.New("page chain corrupt")
+
+	ErrInvalidReservedPages = errors.New("invalid reserved page count")
+
+	ErrTxInProgress = errors.New("transaction in progress")
+	ErrStoreOpen    = errors.New("store open on pager")
+	ErrTxDone       = errors.New("transaction already committed or rolled back")
+
+	ErrKeyNotFound    = errors.New("key not found")
+	ErrBucketNotFound = errors.New("bucket not found")
+	ErrBucketExists   = errors.New("bucket already exists")
+)
 
 type PageNum int64
 
+// PageType tags every page on disk with what it holds. Pages that were
+// never written read as PageTypeData.
+type PageType uint8
+
+const (
+	PageTypeData PageType = iota
+	PageTypeMeta
+	PageTypeFreelist
+	PageTypeOverflow
+)
+
+func (typ PageType) String() string {
+	switch typ {
+	case PageTypeData:
+		return "data"
+	case PageTypeMeta:
+		return "meta"
+	case PageTypeFreelist:
+		return "freelist"
+	case PageTypeOverflow:
+		return "overflow"
+	default:
+		return fmt.Sprintf("PageType(%d)", uint8(typ))
+	}
+}
+
 type Page struct {
 	Num  PageNum
 	Data []byte
+
+	typ PageType
+
+	// sum is the checksum of Data as read from the pager, when summed.
+	sum    uint32
+	summed bool
+
+	// pool is where Release returns Data to, for pages from a pager.
+	pool *bufferPool
 }
 
 func NewPage(num PageNum, size int) *Page {
@@ -32,65 +136,466 @@ func NewPage(num PageNum, size int) *Page {
 	}
 }
 
+// WithNum returns a page with the given number that shares Data with the
+// receiver. The receiver itself is left untouched, use Clone to get a page
+// with its own copy of the data.
 func (pg *Page) WithNum(num PageNum) *Page {
-	pg.Num = num
 	return &Page{
-		Num:  num,
-		Data: pg.Data,
+		Num:    num,
+		Data:   pg.Data,
+		typ:    pg.typ,
+		sum:    pg.sum,
+		summed: pg.summed,
+		pool:   pg.pool,
+	}
+}
+
+// WithType returns a page tagged with typ that shares Data with the
+// receiver. Write stores the tag in the page header.
+func (pg *Page) WithType(typ PageType) *Page {
+	return &Page{
+		Num:    pg.Num,
+		Data:   pg.Data,
+		typ:    typ,
+		sum:    pg.sum,
+		summed: pg.summed,
+		pool:   pg.pool,
+	}
+}
+
+// Type returns the page type, as stored in the header for pages read from
+// the pager.
+func (pg *Page) Type() PageType {
+	return pg.typ
+}
+
+// Clone returns a deep copy of the page.
+func (pg *Page) Clone() *Page {
+	return &Page{
+		Num:    pg.Num,
+		Data:   bytes.Clone(pg.Data),
+		typ:    pg.typ,
+		sum:    pg.sum,
+		summed: pg.summed,
 	}
 }
 
+// Checksum returns the CRC32C of the page data, the payload without the
+// header the pager stores in front of it.
+func (pg *Page) Checksum() uint32 {
+	return crc32.Checksum(pg.Data, castagnoli)
+}
+
+// ValidateChecksum reports whether the page data still has the checksum
+// it had when the page was read from the pager, so that tools and tests
+// can tell a page was modified in memory. Pages that were not read from a
+// pager, such as those of Alloc or NewPage, have nothing to be checked
+// against and report false.
+func (pg *Page) ValidateChecksum() bool {
+	return pg.summed && pg.Checksum() == pg.sum
+}
+
+// stamp records the checksum of the data just read into the page.
+func (pg *Page) stamp() {
+	pg.sum, pg.summed = pg.Checksum(), true
+}
+
+// Reset zeroes the page data and clears its number and type, so that a
+// reused page starts out like one from Alloc. Read and ReadInto overwrite
+// the whole buffer anyway, but a Write shorter than the previous contents
+// of a reused page keeps their tail unless the page is Reset first.
+func (pg *Page) Reset() {
+	clear(pg.Data)
+	pg.Num, pg.typ = 0, PageTypeData
+	pg.sum, pg.summed = 0, false
+}
+
 func (pg *Page) Write(b []byte) {
 	copy(pg.Data, b)
 }
 
-type Pager struct {
-	path string
-	f    *os.File
+// WriteAt copies b into the page data starting at offset, leaving the rest
+// of the page as it is. Unlike Write it refuses to truncate: it returns
+// ErrDataTooLarge when b does not fit in the page from offset on.
+func (pg *Page) WriteAt(b []byte, offset int) error {
+	if offset < 0 {
+		return fmt.Errorf("page/writeAt(num=%d,offset=%d): %w", pg.Num, offset, ErrWrongBytes)
+	}
+
+	if len(b) > len(pg.Data)-offset {
+		return fmt.Errorf(
+			"page/writeAt(num=%d,offset=%d,size=%d): page of %d bytes: %w",
+			pg.Num, offset, len(b), len(pg.Data), ErrDataTooLarge,
+		)
+	}
+
+	copy(pg.Data[offset:], b)
 
-	psize int
+	return nil
+}
 
-	meta  *Metainfo
-	flist *Freelist
+// Pager is safe for concurrent use. Reads share a reader lock, while
+// writes, flushes and freelist changes made through the pager take the
+// writer lock. The Freelist and Meta accessors hand out the live
+// structures without any locking.
+type Pager struct {
+	// mu guards the store and the in-memory metadata. It is taken after
+	// commitMu when both are needed.
+	mu sync.RWMutex
+
+	// path is empty for in-memory pagers.
+	path  string
+	store Backend
+
+	psize    int
+	opts     Options
+	checksum ChecksumFunc
+	aead     cipher.AEAD
+	observer Observer
+	log      *slog.Logger
+
+	// fileSize is the size of the file as the pager last extended or cut
+	// it, which runs ahead of Max by the pages preallocated with
+	// WithGrowChunk.
+	fileSize int64
+
+	// created is set when NewPager created the file, until the first
+	// flush has made its directory entry durable.
+	created bool
+
+	// hinted is set when the last recovery read the freelist from the
+	// hint file.
+	hinted bool
+
+	meta *Metainfo
+
+	// flushed is the metainfo as of the last flush or recovery, and
+	// changed is set by writes and by changes to the classes and the
+	// stable ids. Flush skips the flush while neither tells of a change.
+	flushed *Metainfo
+	changed bool
+
+	// flistChain holds the pages of the freelist chain, which starts at
+	// the meta Freelist page, and classChain and idChain those of the
+	// classes and the stable ids.
+	flist      *Freelist
+	flistChain shadowChain
+
+	classes    *FreelistSet
+	classChain shadowChain
+
+	ids     *IDTable
+	idChain shadowChain
+
+	// pages pools the payload buffers of Alloc and raw the buffers pages
+	// are read into from the store.
+	pages *bufferPool
+	raw   *bufferPool
+
+	sumMu    sync.Mutex
+	stateSum uint64
+	pageSums map[PageNum]uint64
+
+	events  chan AllocEvent
+	dropped atomic.Uint64
+
+	// scrub holds the released pages waiting to be zeroed by the next
+	// flush, with ZeroOnRelease.
+	scrub map[PageNum]struct{}
+
+	// dirty holds the sealed pages written with WithBufferedWrites that
+	// the next flush has to write.
+	dirty map[PageNum][]byte
+
+	// cache holds the pages read most recently, with WithCache.
+	cache *pageCache
+
+	subsMu sync.Mutex
+	subs   map[PageNum]map[*subscription]struct{}
+
+	// tx is the transaction in progress and wal the log it is committed
+	// through, opened by the first commit or replay.
+	tx  *Tx
+	wal *wal
+
+	// owned is set under mu by OpenStore. The Store keeps page numbers in
+	// its nodes, so the pager refuses to relocate pages from then on.
+	owned bool
+
+	commitMu  sync.Mutex
+	commits   chan chan error
+	done      chan struct{}
+	committer sync.WaitGroup
+
+	// closed is set under mu by Close, after which every operation on the
+	// store returns ErrClosed.
+	closed    bool
+	closeOnce sync.Once
 }
 
+// MinPageSize is the smallest page size a pager accepts, and every page
+// size is a multiple of it.
+const MinPageSize = 512
+
+// NewPager opens the store at path, creating it when it does not exist.
+// It locks the file for the lifetime of the pager and returns ErrLocked
+// when another pager has it open, see WithFileLock.
+// The page size must be a multiple of MinPageSize, or NewPager returns
+// ErrInvalidPageSize. os.Getpagesize() is the recommended choice, it lines
+// pages up with the pages of the operating system.
 func NewPager(path string, psize int) (*Pager, error) {
-	var err error
+	return NewPagerWithOptions(path, psize)
+}
+
+func NewPagerWithOptions(path string, psize int, opts ...PagerOption) (*Pager, error) {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if err := checkOptions(psize, options); err != nil {
+		return nil, fmt.Errorf("pager/new: %w", err)
+	}
 
 	exists, err := isFsEntryExists(path)
 	if err != nil {
 		return nil, fmt.Errorf("pager/new: %w", err)
 	}
 
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, DefaultFilePerm)
+	flag := os.O_RDWR | os.O_CREATE
+	if options.ReadOnly {
+		flag = os.O_RDONLY
+	}
+
+	f, err := os.OpenFile(path, flag, options.filePerm())
 	if err != nil {
 		return nil, fmt.Errorf("pager/new: open/create file: %w", err)
 	}
 
+	if !options.NoLock {
+		if err := lockFile(f, options.ReadOnly); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("pager/new: %w", err)
+		}
+	}
+
+	var store Backend = f
+	if options.Mmap {
+		m, err := newMmapBackend(f)
+		switch {
+		case err == nil:
+			store = m
+		case !errors.Is(err, errMmapUnsupported):
+			_ = f.Close()
+			return nil, fmt.Errorf("pager/new: %w", err)
+		}
+	}
+
+	pgr, err := newPager(path, store, exists, psize, options)
+	if err != nil {
+		return nil, fmt.Errorf("pager/new: %w", err)
+	}
+
+	return pgr, nil
+}
+
+// NewMemPager creates a pager that keeps the whole store in memory. It is
+// empty on creation and discarded by Close.
+func NewMemPager(psize int, opts ...PagerOption) (*Pager, error) {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if err := checkOptions(psize, options); err != nil {
+		return nil, fmt.Errorf("pager/newMem: %w", err)
+	}
+
+	pgr, err := newPager("", newMemBackend(), false, psize, options)
+	if err != nil {
+		return nil, fmt.Errorf("pager/newMem: %w", err)
+	}
+
+	return pgr, nil
+}
+
+// NewPagerFromBackend opens a pager on top of b. An empty backend is
+// initialized as a new store, otherwise the store in it is recovered.
+// Hint files and the write-ahead log need a path and are not used.
+func NewPagerFromBackend(b Backend, psize int, opts ...PagerOption) (*Pager, error) {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if err := checkOptions(psize, options); err != nil {
+		return nil, fmt.Errorf("pager/newFromBackend: %w", err)
+	}
+
+	size, err := backendSize(b)
+	if err != nil {
+		return nil, fmt.Errorf("pager/newFromBackend: %w", err)
+	}
+
+	pgr, err := newPager("", b, size > 0, psize, options)
+	if err != nil {
+		return nil, fmt.Errorf("pager/newFromBackend: %w", err)
+	}
+
+	return pgr, nil
+}
+
+func newPager(path string, store Backend, exists bool, psize int, options Options) (*Pager, error) {
+	var err error
+
 	pgr := &Pager{
-		path: path,
-		f:    f,
+		path:  path,
+		store: store,
+
+		psize:    psize,
+		opts:     options,
+		checksum: options.checksumFunc(),
+		observer: options.observer(),
+		log:      options.logger(),
 
-		psize: psize,
+		created: !exists && path != "",
 
 		meta:  NewMetainfo(),
 		flist: NewFreelist(),
+
+		classes: NewFreelistSet(),
+		ids:     NewIDTable(),
+
+		pageSums: make(map[PageNum]uint64),
+
+		events: make(chan AllocEvent, DefaultAllocEventsBuffer),
+
+		scrub: make(map[PageNum]struct{}),
+		dirty: make(map[PageNum][]byte),
+	}
+	if options.CacheSize > 0 {
+		pgr.cache = newPageCache(options.CacheSize, options.Readahead)
+	}
+	pgr.meta.PageSize = psize
+	pgr.meta.Order = options.ByteOrder
+	pgr.meta.ChecksumPlacement = options.ChecksumPlacement
+	pgr.flist.Order = options.ByteOrder
+	pgr.flist.onEvent = pgr
+
+	if !exists {
+		pgr.meta.ReservedPages = max(options.ReservedPages, int(BeginFreeBlocks))
+		pgr.flist.begin = PageNum(pgr.meta.ReservedPages)
+		pgr.flist.Max = pgr.flist.begin
+		pgr.flistChain = shadowChain{
+			cur:   []PageNum{ShadowFlistPage},
+			spare: []PageNum{DefaultFlistPage},
+		}
+	}
+
+	if options.EncryptionKey != nil {
+		if pgr.aead, err = newAEAD(options.EncryptionKey); err != nil {
+			_ = store.Close()
+			return nil, err
+		}
 	}
 
+	pgr.pages = newBufferPool(pgr.payloadSize())
+	pgr.raw = newBufferPool(psize)
+
 	if exists {
-		err = pgr.Recovery()
+		err = pgr.recovery()
+		if err == nil {
+			pgr.flushed = pgr.meta.Clone()
+			err = pgr.replayWAL()
+		}
 	} else {
-		err = pgr.Flush()
+		err = pgr.create()
+	}
+	if err == nil {
+		pgr.fileSize, err = pgr.storeSize()
 	}
 
 	if err != nil {
+		// A pager that failed to open is closed as is.
+		pgr.opts.NoFlushOnClose = true
 		_ = pgr.Close()
-		return nil, fmt.Errorf("pager/new: %w", err)
+		return nil, err
+	}
+	pgr.flist.set(flistReadOnly, pgr.opts.ReadOnly)
+	pgr.flist.setStrategy(options.AllocStrategy)
+
+	if options.GroupCommitWindow > 0 {
+		pgr.startGroupCommit(options.GroupCommitWindow)
 	}
 
 	return pgr, nil
 }
 
+// checkPageSize returns ErrInvalidPageSize unless psize is a positive
+// multiple of MinPageSize.
+func checkPageSize(psize int) error {
+	if psize <= 0 || psize%MinPageSize != 0 {
+		return fmt.Errorf("page size %d, multiple of %d: %w", psize, MinPageSize, ErrInvalidPageSize)
+	}
+
+	return nil
+}
+
+// checkOptions validates psize and options before the constructors open
+// anything, so that a rejected pager leaves no file behind. The meta page
+// has to fit in a single page, whatever the encryption takes off its
+// payload.
+func checkOptions(psize int, options Options) error {
+	if err := checkPageSize(psize); err != nil {
+		return err
+	}
+
+	if options.ReservedPages != 0 && options.ReservedPages < int(BeginFreeBlocks) {
+		return fmt.Errorf(
+			"%d reserved pages, min %d: %w",
+			options.ReservedPages, BeginFreeBlocks, ErrInvalidReservedPages,
+		)
+	}
+
+	probe := &Pager{psize: psize, meta: NewMetainfo()}
+	probe.meta.PageSize = psize
+	probe.meta.Order = options.ByteOrder
+	probe.meta.ChecksumPlacement = options.ChecksumPlacement
+	if options.EncryptionKey != nil {
+		aead, err := newAEAD(options.EncryptionKey)
+		if err != nil {
+			return err
+		}
+		probe.aead = aead
+	}
+
+	if need := len(probe.meta.Serialize()) + MetaUserCapacity; probe.payloadSize() < need {
+		return fmt.Errorf(
+			"page size %d leaves %d bytes of payload, need %d: %w",
+			psize, probe.payloadSize(), need, ErrInvalidPageSize,
+		)
+	}
+
+	return nil
+}
+
+func (pgr *Pager) create() error {
+	if pgr.opts.InitialPages > 0 {
+		if err := pgr.truncateStore(int64(pgr.opts.InitialPages) * int64(pgr.psize)); err != nil {
+			return fmt.Errorf("pager: preallocate file: %w", err)
+		}
+	}
+
+	return pgr.flush(context.Background())
+}
+
+// NewReadOnlyPager opens an existing file for inspection. The pager
+// recovers as usual and serves reads, while every write, flush and
+// freelist change is refused.
+func NewReadOnlyPager(path string, psize int, opts ...PagerOption) (*Pager, error) {
+	return NewPagerWithOptions(path, psize, append(opts, WithReadOnly(true))...)
+}
+
 func isFsEntryExists(path string) (bool, error) {
 	if _, err := os.Stat(path); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -101,191 +606,1506 @@ func isFsEntryExists(path string) (bool, error) {
 	return true, nil
 }
 
+// Alloc returns an empty page sized to the usable payload of a page, which
+// is the page size minus the page header. Its buffer comes from a pool the
+// page can be handed back to with Release.
 func (pgr *Pager) Alloc() *Page {
-	return NewPage(0, pgr.psize)
+	return pgr.getPage()
 }
 
+// Write stores the page. With WithBufferedWrites the sealed page is kept
+// in memory until the next Flush, otherwise it is written right away.
 func (pgr *Pager) Write(pg *Page) error {
-	off := int64(pg.Num) * int64(pgr.psize)
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	start := pgr.observeStart()
+	if err := pgr.write(pg, pgr.opts.BufferedWrites); err != nil {
+		return err
+	}
+	pgr.observer.OnWrite(pg.Num, time.Since(start))
+
+	return nil
+}
+
+// write stores the page, keeping it as a dirty page when buffered. The
+// pager writes its own structures through unbuffered.
+func (pgr *Pager) write(pg *Page, buffered bool) error {
+	if pgr.closed {
+		return fmt.Errorf("pager/write(num=%d): %w", pg.Num, ErrClosed)
+	}
+
+	if pgr.opts.ReadOnly {
+		return fmt.Errorf("pager/write(num=%d): %w", pg.Num, ErrReadOnly)
+	}
 
-	if _, err := pgr.f.WriteAt(pg.Data, off); err != nil {
+	if pgr.opts.StrictWrites && len(pg.Data) != pgr.payloadSize() {
 		return fmt.Errorf(
 			"pager/write(num=%d,size=%d): %w",
-			pg.Num, len(pg.Data), err,
+			pg.Num, len(pg.Data), ErrWrongPageSize,
 		)
 	}
 
+	b, err := pgr.sealPage(pg.Num, pg.typ, pg.Data)
+	if err != nil {
+		return fmt.Errorf("pager/write(num=%d,size=%d): %w", pg.Num, len(pg.Data), err)
+	}
+
+	if buffered {
+		pgr.cache.invalidate(pg.Num)
+		pgr.dirty[pg.Num] = b
+		return nil
+	}
+
+	if err := pgr.writeSealed(pg.Num, b); err != nil {
+		return fmt.Errorf("pager/write(num=%d,size=%d): %w", pg.Num, len(pg.Data), err)
+	}
+
 	return nil
 }
 
-func (pgr *Pager) Read(num PageNum) (*Page, error) {
-	pg := pgr.Alloc().WithNum(num)
-	off := int64(num) * int64(pgr.psize)
+// writeSealed writes the sealed page through, replacing any dirty copy.
+func (pgr *Pager) writeSealed(num PageNum, b []byte) error {
+	pgr.changed = true
+	pgr.cache.invalidate(num)
 
-	if _, err := pgr.f.ReadAt(pg.Data, off); err != nil {
-		return nil, fmt.Errorf("pager/read(num=%d): %w", pg.Num, err)
+	if err := pgr.updateStateSum(num, b); err != nil {
+		return err
 	}
 
-	return pg, nil
+	n, err := pgr.store.WriteAt(b, int64(num)*int64(pgr.psize))
+	if err != nil {
+		return err
+	}
+	if n != len(b) {
+		return pgr.logShortWrite(num, fmt.Errorf("page %d: wrote %d of %d bytes: %w", num, n, len(b), ErrShortWrite))
+	}
+	delete(pgr.dirty, num)
+
+	pgr.notifyWrite(num)
+
+	return nil
 }
 
-func (pgr *Pager) Flush() error {
-	metapg := pgr.Alloc().WithNum(DefaultMetaPage)
-	metab := pgr.meta.Serialize()
+// Read is the zero-copy read path: the returned page buffer is handed out
+// as is and may be shared with layers built on top of the pager. Callers
+// that want to retain or mutate the payload should use PageData. Read
+// verifies the page checksum and returns ErrChecksumMismatch for pages
+// that were damaged on disk, and ErrPageOutOfRange for page numbers the
+// freelist never handed out or that lie past the end of the file.
+func (pgr *Pager) Read(num PageNum) (*Page, error) {
+	pgr.mu.RLock()
+	defer pgr.mu.RUnlock()
 
-	copy(metapg.Data, metab)
+	start := pgr.observeStart()
+	pg, err := pgr.read(num)
+	if err != nil {
+		return nil, err
+	}
+	pgr.observer.OnRead(num, time.Since(start))
 
-	if err := pgr.Write(metapg); err != nil {
-		return fmt.Errorf("pager: flush metainfo: %w", err)
+	return pg, nil
+}
+
+func (pgr *Pager) read(num PageNum) (*Page, error) {
+	if pgr.closed {
+		return nil, fmt.Errorf("pager/read(num=%d): %w", num, ErrClosed)
 	}
 
-	flistpg := pgr.Alloc().WithNum(pgr.meta.Freelist)
-	flistb := pgr.flist.Serialize()
+	if num < 0 || num >= pgr.flist.Max {
+		return nil, fmt.Errorf(
+			"pager/read(num=%d): max %d: %w",
+			num, pgr.flist.Max, ErrPageOutOfRange,
+		)
+	}
 
-	copy(flistpg.Data, flistb)
+	read := pgr.readPage
+	if pgr.cache != nil {
+		read = pgr.readCached
+	}
 
-	if err := pgr.Write(flistpg); err != nil {
-		return fmt.Errorf("pager: flush freelist: %w", err)
+	pg, err := read(num)
+	if err != nil {
+		return nil, fmt.Errorf("pager/read(num=%d): %w", num, err)
 	}
 
-	return nil
+	return pg, nil
 }
 
-func (pgr *Pager) Recovery() error {
-	metapg, err := pgr.Read(DefaultMetaPage)
-	if err != nil {
-		return fmt.Errorf("pager: recover metainfo: %w", err)
+// ReadInto is Read into a page of the caller, whose Data must be as long
+// as the payload of a page of Alloc, so that hot loops can reuse pages
+// instead of allocating one per read. It returns ErrWrongPageSize for any
+// other length. Unlike Read it always copies: pg.Data stays owned by the
+// caller and is never shared with the pager.
+func (pgr *Pager) ReadInto(num PageNum, pg *Page) error {
+	pgr.mu.RLock()
+	defer pgr.mu.RUnlock()
+
+	if pgr.closed {
+		return fmt.Errorf("pager/readInto(num=%d): %w", num, ErrClosed)
 	}
 
-	if err := pgr.meta.Deserialize(metapg.Data); err != nil {
-		return fmt.Errorf("pager: recover metainfo: %w", err)
+	if len(pg.Data) != pgr.payloadSize() {
+		return fmt.Errorf(
+			"pager/readInto(num=%d,size=%d): expected %d: %w",
+			num, len(pg.Data), pgr.payloadSize(), ErrWrongPageSize,
+		)
 	}
 
-	flistpg, err := pgr.Read(pgr.meta.Freelist)
-	if err != nil {
-		return fmt.Errorf("pager: recover freelist: %w", err)
+	if num < 0 || num >= pgr.flist.Max {
+		return fmt.Errorf(
+			"pager/readInto(num=%d): max %d: %w",
+			num, pgr.flist.Max, ErrPageOutOfRange,
+		)
 	}
 
-	if err := pgr.flist.Deserialize(flistpg.Data); err != nil {
-		return fmt.Errorf("pager: recover freelist: %w", err)
+	start := pgr.observeStart()
+	if err := pgr.readPageInto(num, pg); err != nil {
+		return fmt.Errorf("pager/readInto(num=%d): %w", num, err)
 	}
+	pgr.observer.OnRead(num, time.Since(start))
 
 	return nil
 }
 
-func (pgr *Pager) Close() error {
-	if err := pgr.f.Close(); err != nil {
-		return fmt.Errorf("pager/close: %w", err)
+// readPage reads and verifies a page without checking it against the
+// freelist, which Recovery has to do before the freelist is known.
+func (pgr *Pager) readPage(num PageNum) (*Page, error) {
+	// Mapped pages are copied out too: the mapping is cut by a shrink and
+	// unmapped by Close, while the caller may still hold the page.
+	pg := pgr.Alloc()
+	if err := pgr.readPageInto(num, pg); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return pg, nil
 }
 
-func (pgr *Pager) Meta() *Metainfo {
-	return pgr.meta
-}
+// mappedPage returns the mapped bytes of page num when it is stored as is
+// and has no dirty copy.
+func (pgr *Pager) mappedPage(num PageNum) ([]byte, bool) {
+	if _, ok := pgr.dirty[num]; ok {
+		return nil, false
+	}
 
-func (pgr *Pager) Freelist() *Freelist {
-	return pgr.flist
-}
+	m, ok := pgr.store.(*mmapBackend)
+	if !ok || pgr.encodes(num) || pgr.aead != nil {
+		return nil, false
+	}
 
-type Metainfo struct {
-	Freelist PageNum
+	return m.mapped(int64(num)*int64(pgr.psize), pgr.psize)
 }
 
-func NewMetainfo() *Metainfo {
-	return &Metainfo{
-		Freelist: DefaultFlistPage,
+// readPageInto reads and verifies a page into the payload of pg.
+func (pgr *Pager) readPageInto(num PageNum, pg *Page) error {
+	b, ok := pgr.dirty[num]
+	if !ok {
+		b, ok = pgr.mappedPage(num)
 	}
-}
 
-func (meta *Metainfo) Serialize() []byte {
-	b := make([]byte, 8)
+	if !ok {
+		b = pgr.raw.get()
+		defer pgr.raw.put(b)
+
+		n, err := pgr.store.ReadAt(b, int64(num)*int64(pgr.psize))
+		switch {
+		case n == 0 && errors.Is(err, io.EOF):
+			return fmt.Errorf("page %d past end of file: %w", num, ErrPageOutOfRange)
+		case n < len(b) && (err == nil || errors.Is(err, io.EOF)):
+			// A crash in the middle of a write can leave the final page
+			// short. The rest reads as zeros, and the checksum tells
+			// whether what is left is the whole page.
+			pgr.log.Warn("partial final page", "page", num, "read", n, "size", len(b))
+			clear(b[n:])
+		case err != nil:
+			return err
+		}
+	}
 
-	binary.LittleEndian.PutUint64(b[:8], uint64(meta.Freelist))
+	if err := pgr.openPage(num, b, pg.Data); err != nil {
+		return err
+	}
+	pg.Num, pg.typ = num, pgr.storedType(num, b)
+	pg.stamp()
 
-	return b
+	return nil
 }
 
-func (meta *Metainfo) Deserialize(b []byte) error {
-	if len(b) < 8 {
-		return fmt.Errorf("meta/deserialize: %w", ErrWrongBytes)
+// PageData returns an independent copy of the page payload that is safe
+// to retain and mutate.
+func (pgr *Pager) PageData(num PageNum) ([]byte, error) {
+	pgr.mu.RLock()
+	defer pgr.mu.RUnlock()
+
+	pg, err := pgr.read(num)
+	if err != nil {
+		return nil, fmt.Errorf("pager/pageData: %w", err)
 	}
 
-	meta.Freelist = PageNum(binary.LittleEndian.Uint64(b[:8]))
+	return bytes.Clone(pg.Data), nil
+}
 
-	return nil
+// Flush persists buffered pages, the metainfo and the freelist. Steps
+// always run in the same order: data pages are written and synced before
+// the meta page is written, and the
+// meta page is synced before Flush returns. The parent directory is synced
+// once after the file has been created. WithSync(false) skips every sync.
+// Each flush writes the meta page to the slot the previous one did not use.
+//
+// Flush skips the writes and syncs entirely when nothing changed since the
+// last flush: no page was written, no page allocated or released and no
+// metainfo field changed. changed reports whether it flushed.
+func (pgr *Pager) Flush() (changed bool, err error) {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	return pgr.flushChanged(context.Background())
 }
 
-func (meta *Metainfo) Equal(other *Metainfo) bool {
-	return meta.Freelist == other.Freelist
+// flushChanged flushes unless there is nothing to flush. A closed or
+// read-only pager still fails as flush does.
+func (pgr *Pager) flushChanged(ctx context.Context) (bool, error) {
+	if !pgr.closed && !pgr.opts.ReadOnly && !pgr.needsFlush() {
+		return false, nil
+	}
+
+	if err := pgr.flush(ctx); err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
 
-type Freelist struct {
-	Max      PageNum
-	Released []PageNum
+func (pgr *Pager) needsFlush() bool {
+	return pgr.changed || pgr.flist.has(flistDirty) ||
+		len(pgr.dirty) > 0 || len(pgr.scrub) > 0 ||
+		pgr.flushed == nil || !pgr.meta.Equal(pgr.flushed)
 }
 
-func NewFreelist() *Freelist {
-	return &Freelist{
-		Max:      BeginFreeBlocks,
-		Released: make([]PageNum, 0),
+// flush is Flush giving up when ctx is done before the meta page is
+// written. An abandoned flush leaves the freelist dirty, so the next one
+// writes it again.
+func (pgr *Pager) flush(ctx context.Context) error {
+	start := pgr.observeStart()
+	if err := pgr.flushAll(ctx); err != nil {
+		return err
 	}
+	pgr.observer.OnFlush(time.Since(start))
+
+	return nil
 }
 
-func (flist *Freelist) Next() PageNum {
-	if len(flist.Released) == 0 {
-		curr := flist.Max
-		flist.Max += 1
-		return curr
+func (pgr *Pager) flushAll(ctx context.Context) error {
+	if pgr.closed {
+		return fmt.Errorf("pager: flush: %w", ErrClosed)
 	}
 
-	num := flist.Released[len(flist.Released)-1]
-	flist.Released = flist.Released[:len(flist.Released)-1]
+	if pgr.opts.ReadOnly {
+		return fmt.Errorf("pager: flush: %w", ErrReadOnly)
+	}
 
-	return num
-}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("pager: flush: %w", err)
+	}
 
-func (flist *Freelist) Release(num PageNum) {
-	if num <= BeginFreeBlocks {
-		return
+	if err := pgr.flushClasses(); err != nil {
+		return fmt.Errorf("pager: %w", err)
 	}
 
-	flist.Released = append(flist.Released, num)
-}
+	if err := pgr.flushIDs(); err != nil {
+		return fmt.Errorf("pager: %w", err)
+	}
 
-func (flist *Freelist) Serialize() []byte {
-	b := make([]byte, 8+4+(8*len(flist.Released)))
+	if err := pgr.flushDirty(ctx); err != nil {
+		return fmt.Errorf("pager: %w", err)
+	}
 
-	binary.LittleEndian.PutUint64(b[:8], uint64(flist.Max))
-	binary.LittleEndian.PutUint32(b[8:12], uint32(len(flist.Released)))
+	if err := pgr.scrubReleased(); err != nil {
+		return fmt.Errorf("pager: %w", err)
+	}
 
-	for i, num := range flist.Released {
-		binary.LittleEndian.PutUint64(b[12+(8*i):(12+(8*i))+8], uint64(num))
+	if !pgr.opts.NoSync {
+		if err := pgr.Sync(); err != nil {
+			return fmt.Errorf("pager: flush data: %w", err)
+		}
+		traceFlushStep(flushStepSyncData)
 	}
 
-	return b
-}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("pager: flush: %w", err)
+	}
 
-func (flist *Freelist) Deserialize(b []byte) error {
-	if len(b) < 8+4 {
-		return fmt.Errorf("freelist/deserialize: decode head: %w", ErrWrongBytes)
+	shrunk := 0
+	if pgr.opts.AutoShrink && pgr.flist.has(flistDirty) {
+		n, err := pgr.autoShrink()
+		if err != nil {
+			return fmt.Errorf("pager: %w", err)
+		}
+		shrunk = n
+	}
+
+	var flistpg *Page
+	if pgr.flist.has(flistDirty) {
+		pg, err := pgr.flushFreelist()
+		if err != nil {
+			return fmt.Errorf("pager: %w", err)
+		}
+		flistpg = pg
 	}
 
-	flist.Max = PageNum(binary.LittleEndian.Uint64(b[:8]))
-	flist.Released = make([]PageNum, binary.LittleEndian.Uint32(b[8:12]))
+	// TxID picks the slot, it only moves on once the meta page is written
+	// so a failed write does not aim the next flush at the last good slot.
+	txid, stateSum := pgr.meta.TxID, pgr.meta.StateSum
+	pgr.meta.TxID += 1
+	pgr.meta.StateSum = pgr.StateChecksum()
+
+	metapg := pgr.Alloc().WithNum(metaSlot(pgr.meta.TxID))
+	metab := pgr.meta.Serialize()
+
+	copy(metapg.Data, metab)
 
-	if len(b) < (8+4)+(8*len(flist.Released)) {
-		return fmt.Errorf("freelist/deserialize: decode body: %w", ErrWrongBytes)
+	if err := pgr.writeMetaPages(metapg, flistpg); err != nil {
+		pgr.meta.TxID, pgr.meta.StateSum = txid, stateSum
+		return fmt.Errorf("pager: %w", err)
 	}
+	pgr.commitChains()
+	pgr.flist.set(flistDirty, false)
+	pgr.flushed, pgr.changed = pgr.meta.Clone(), false
 
-	for i := range flist.Released {
-		flist.Released[i] = PageNum(binary.LittleEndian.Uint64(b[12+(8*i) : (12+(8*i))+8]))
+	if pgr.opts.NoSync {
+		if shrunk > 0 {
+			pgr.truncateTail()
+		}
+		return nil
+	}
+
+	if err := pgr.Sync(); err != nil {
+		// The meta page is written but not durable, the next Flush has
+		// to sync it again.
+		pgr.changed = true
+		return fmt.Errorf("pager: flush metainfo: %w", err)
+	}
+	traceFlushStep(flushStepSyncMeta)
+
+	if shrunk > 0 {
+		pgr.truncateTail()
+	}
+
+	if pgr.created {
+		if err := syncDir(pgr.path); err != nil {
+			return fmt.Errorf("pager: flush: %w", err)
+		}
+		traceFlushStep(flushStepSyncDir)
+
+		pgr.created = false
+	}
+
+	return nil
+}
+
+// Sync commits the file contents to stable storage. Pages passed to Write
+// are only guaranteed to survive a crash once the file has been synced,
+// which Flush does unless syncing is disabled.
+func (pgr *Pager) Sync() error {
+	if err := pgr.store.Sync(); err != nil {
+		return fmt.Errorf("pager/sync: %w", err)
+	}
+
+	return nil
+}
+
+func syncDir(path string) error {
+	d, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("sync dir: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync dir: %w", err)
+	}
+
+	return nil
+}
+
+func (pgr *Pager) Recovery() error {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	return pgr.recovery()
+}
+
+func (pgr *Pager) recovery() error {
+	if err := pgr.recoverMeta(); err != nil {
+		return fmt.Errorf("pager: recover metainfo: %w", err)
+	}
+	pgr.flist.Order = pgr.meta.Order
+	pgr.flist.begin = PageNum(pgr.meta.ReservedPages)
+
+	hinted, err := pgr.recoverHint()
+	if err != nil {
+		return fmt.Errorf("pager: %w", err)
+	}
+
+	if !hinted {
+		if err := pgr.recoverFreelist(); err != nil {
+			if err := pgr.corruptFreelist(err); err != nil {
+				return fmt.Errorf("pager: %w", err)
+			}
+		}
+	}
+	pgr.hinted = hinted
+
+	pgr.log.Debug(
+		"recovered freelist",
+		"max", pgr.flist.Max, "free", len(pgr.flist.Released),
+		"pages", pgr.meta.FreelistPages, "hinted", hinted,
+	)
+
+	if err := pgr.recoverClasses(); err != nil {
+		return fmt.Errorf("pager: %w", err)
+	}
+
+	if err := pgr.recoverIDs(); err != nil {
+		return fmt.Errorf("pager: %w", err)
+	}
+
+	pgr.recoverSpares()
+
+	if err := pgr.recoverStateSum(); err != nil {
+		return fmt.Errorf("pager: %w", err)
+	}
+
+	return nil
+}
+
+// flushFreelist writes the overflow pages of the freelist chain and
+// returns its head page, left for writeMetaPages. Overflow pages are taken
+// from the freelist itself, which shrinks the list being stored, so they
+// are reserved until the serialized freelist fits the chain exactly.
+func (pgr *Pager) flushFreelist() (*Page, error) {
+	nums := pgr.flistChain.target()
+	size := func() int { return freelistHeaderSize + 8*pgr.flist.freeCount() }
+
+	for len(nums) > 1 && pgr.chainLen(size()+8) < len(nums) {
+		if err := pgr.flist.Release(nums[len(nums)-1]); err != nil {
+			return nil, fmt.Errorf("flush freelist: %w", err)
+		}
+		nums = nums[:len(nums)-1]
+	}
+	for pgr.chainLen(size()) > len(nums) {
+		num, err := pgr.flist.Next()
+		if err != nil {
+			return nil, fmt.Errorf("flush freelist: %w", err)
+		}
+		nums = append(nums, num)
+	}
+
+	pages := pgr.chainPages(nums, PageTypeFreelist, pgr.flist.Serialize())
+	for _, pg := range pages[1:] {
+		if err := pgr.write(pg, false); err != nil {
+			return nil, fmt.Errorf("flush freelist: %w", err)
+		}
+	}
+
+	pgr.flistChain.wrote(nums)
+	pgr.meta.Freelist = nums[0]
+	pgr.meta.FreelistPages = len(nums)
+
+	return pages[0], nil
+}
+
+func (pgr *Pager) recoverFreelist() error {
+	b, nums, err := pgr.readChain(pgr.meta.Freelist)
+	if err != nil {
+		return fmt.Errorf("recover freelist: %w", err)
+	}
+
+	if len(nums) != pgr.meta.FreelistPages {
+		return fmt.Errorf(
+			"recover freelist: chain of %d pages, expected %d: %w",
+			len(nums), pgr.meta.FreelistPages, ErrWrongBytes,
+		)
+	}
+
+	if err := pgr.flist.Deserialize(b); err != nil {
+		return fmt.Errorf("recover freelist: %w", err)
+	}
+	pgr.flistChain = shadowChain{cur: nums}
+
+	return nil
+}
+
+// recoverMeta loads the metainfo from the meta slot with the highest
+// TxID that passes verification.
+func (pgr *Pager) recoverMeta() error {
+	recovered, err := pgr.latestMeta()
+	if err != nil {
+		return err
+	}
+
+	*pgr.meta = *recovered
+	return nil
+}
+
+// latestMeta reads the newest valid metainfo of the two slots, leaving
+// the pager as it is.
+func (pgr *Pager) latestMeta() (*Metainfo, error) {
+	var (
+		recovered *Metainfo
+		from      PageNum
+	)
+	for _, slot := range metaSlots {
+		meta, err := pgr.readMetaSlot(slot)
+		if err != nil {
+			pgr.log.Debug("skipped meta slot", "slot", slot, "err", err)
+			continue
+		}
+
+		if recovered == nil || meta.TxID > recovered.TxID {
+			recovered, from = meta, slot
+		}
+	}
+
+	if recovered != nil {
+		pgr.log.Debug("recovered metainfo", "slot", from, "txid", recovered.TxID)
+		return recovered, nil
+	}
+
+	// Neither slot is valid. The first meta page is decoded once without
+	// verification, so that foreign files and a wrong page size are
+	// reported as such rather than as a checksum mismatch of a misaligned
+	// page. Encrypted meta pages cannot be decoded that way.
+	if pgr.aead == nil {
+		if err := pgr.peekMeta(); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err := pgr.readMetaSlot(DefaultMetaPage)
+	return nil, err
+}
+
+func (pgr *Pager) readMetaSlot(slot PageNum) (*Metainfo, error) {
+	pg, err := pgr.readPage(slot)
+	if err != nil {
+		return nil, fmt.Errorf("read meta slot %d: %w", slot, err)
+	}
+
+	meta := new(Metainfo)
+	if err := meta.Deserialize(pg.Data); err != nil {
+		return nil, fmt.Errorf("read meta slot %d: %w", slot, err)
+	}
+
+	if meta.PageSize != pgr.psize {
+		return nil, fmt.Errorf(
+			"read meta slot %d: stored %d, requested %d: %w",
+			slot, meta.PageSize, pgr.psize, ErrPageSizeMismatch,
+		)
+	}
+
+	return meta, nil
+}
+
+func (pgr *Pager) peekMeta() error {
+	b := make([]byte, pageHeaderSize+len(pgr.meta.Serialize())+MetaUserCapacity)
+
+	if _, err := pgr.store.ReadAt(b, int64(DefaultMetaPage)*int64(pgr.psize)); err != nil {
+		return fmt.Errorf("peek meta: %w", err)
+	}
+
+	meta := new(Metainfo)
+	if err := meta.Deserialize(b[pageHeaderSize:]); err != nil {
+		return err
+	}
+
+	if meta.PageSize != pgr.psize {
+		return fmt.Errorf(
+			"stored %d, requested %d: %w",
+			meta.PageSize, pgr.psize, ErrPageSizeMismatch,
+		)
 	}
 
 	return nil
 }
 
+// Close stops group commit, flushes the pager when anything changed since
+// the last flush unless it is read-only or WithFlushOnClose(false) is set,
+// writes the allocation hint and the clean shutdown marker and closes the
+// store, which releases the file lock. Closing a closed pager returns nil.
+func (pgr *Pager) Close() error {
+	var err error
+	pgr.closeOnce.Do(func() {
+		err = pgr.close()
+	})
+	return err
+}
+
+func (pgr *Pager) close() error {
+	pgr.stopGroupCommit()
+	pgr.closeSubscriptions()
+
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	var flushErr error
+	if !pgr.opts.ReadOnly && !pgr.opts.NoFlushOnClose {
+		_, flushErr = pgr.flushChanged(context.Background())
+	}
+
+	pgr.closed = true
+	pgr.closeAllocEvents()
+
+	if flushErr == nil {
+		pgr.markClean()
+	}
+	hintErr := pgr.writeHint()
+
+	var walErr error
+	if pgr.wal != nil {
+		walErr = pgr.wal.close()
+	}
+
+	if err := pgr.store.Close(); err != nil {
+		return fmt.Errorf("pager/close: %w", err)
+	}
+
+	if flushErr != nil {
+		return fmt.Errorf("pager/close: %w", flushErr)
+	}
+
+	if hintErr != nil {
+		return fmt.Errorf("pager/close: %w", hintErr)
+	}
+
+	if walErr != nil {
+		return fmt.Errorf("pager/close: close wal: %w", walErr)
+	}
+
+	return nil
+}
+
+// Meta returns the live metainfo. Access through it is not synchronized.
+func (pgr *Pager) Meta() *Metainfo {
+	return pgr.meta
+}
+
+// Freelist returns the live freelist. Access through it is not
+// synchronized, concurrent callers allocate and release pages with
+// NextPage and ReleasePage instead.
+func (pgr *Pager) Freelist() *Freelist {
+	return pgr.flist
+}
+
+// NextPage is Freelist().Next under the writer lock.
+func (pgr *Pager) NextPage() (PageNum, error) {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	num, err := pgr.flist.Next()
+	if err != nil {
+		return 0, fmt.Errorf("pager/nextPage: %w", err)
+	}
+
+	return num, nil
+}
+
+// ReservePage is Freelist().Reserve under the writer lock.
+func (pgr *Pager) ReservePage(num PageNum) error {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if err := pgr.flist.Reserve(num); err != nil {
+		return fmt.Errorf("pager/reservePage: %w", err)
+	}
+
+	return nil
+}
+
+// ReleasePage is Freelist().Release under the writer lock.
+func (pgr *Pager) ReleasePage(num PageNum) error {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if err := pgr.flist.Release(num); err != nil {
+		return fmt.Errorf("pager/releasePage: %w", err)
+	}
+
+	return nil
+}
+
+// Append writes data to a newly allocated page and returns its number.
+// Data longer than MaxLogicalPayload is rejected with ErrDataTooLarge,
+// and a page whose write fails is released again.
+func (pgr *Pager) Append(data []byte) (PageNum, error) {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.closed {
+		return 0, fmt.Errorf("pager/append: %w", ErrClosed)
+	}
+
+	if pgr.opts.ReadOnly {
+		return 0, fmt.Errorf("pager/append: %w", ErrReadOnly)
+	}
+
+	if len(data) > pgr.MaxLogicalPayload() {
+		return 0, fmt.Errorf(
+			"pager/append(size=%d): max %d: %w",
+			len(data), pgr.MaxLogicalPayload(), ErrDataTooLarge,
+		)
+	}
+
+	pg := pgr.getPage()
+	defer pg.Release()
+
+	num, err := pgr.flist.Next()
+	if err != nil {
+		return 0, fmt.Errorf("pager/append: %w", err)
+	}
+	pg.Num = num
+	copy(pg.Data, data)
+
+	start := pgr.observeStart()
+	if err := pgr.write(pg, pgr.opts.BufferedWrites); err != nil {
+		if relErr := pgr.flist.Release(pg.Num); relErr != nil {
+			return 0, fmt.Errorf("pager/append: %w", errors.Join(err, relErr))
+		}
+		return 0, fmt.Errorf("pager/append: %w", err)
+	}
+	pgr.observer.OnWrite(pg.Num, time.Since(start))
+
+	return pg.Num, nil
+}
+
+// ByteOrder is the byte order the metainfo and freelist are encoded in.
+// It is recorded in the metainfo header, so a file reads the same
+// whichever order a pager would create new files with.
+type ByteOrder uint8
+
+const (
+	LittleEndian ByteOrder = iota
+	BigEndian
+)
+
+func (order ByteOrder) binary() binary.ByteOrder {
+	if order == BigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// MetaMagic opens every meta page written by embedstore.
+var MetaMagic = [8]byte{'E', 'M', 'B', 'D', 'S', 'T', 'O', 'R'}
+
+// MetaVersion is the version of the metainfo layout written by Serialize.
+// Version 9 metainfo, from before the reserved page count was recorded,
+// is still read, with the default reserved region, and so are version 10,
+// from before the user fields, and version 11, from before the checksum
+// placement, with checksum headers.
+const MetaVersion uint16 = 12
+
+// metaHeaderSize covers the magic, the version and the byte order flag.
+// The header itself is always little-endian.
+const metaHeaderSize = 8 + 2 + 1
+
+type Metainfo struct {
+	Freelist      PageNum
+	FreelistPages int
+	Classes       PageNum
+	IDs           PageNum
+	Root          PageNum
+	Buckets       PageNum
+	TxID          uint64
+	StateSum      uint64
+	PageSize      int
+	Order         ByteOrder
+
+	// ReservedPages is the number of pages at the start of the file the
+	// freelist never hands out. It is at least BeginFreeBlocks, except in
+	// stores created before the shadow freelist page.
+	ReservedPages int
+
+	// ChecksumPlacement is where the pages other than the meta pages
+	// store their checksum and type.
+	ChecksumPlacement ChecksumPlacement
+
+	// user holds the fields of SetUser.
+	user map[string][]byte
+}
+
+func NewMetainfo() *Metainfo {
+	return &Metainfo{
+		Freelist:      DefaultFlistPage,
+		FreelistPages: 1,
+		ReservedPages: int(BeginFreeBlocks),
+	}
+}
+
+func (meta *Metainfo) Serialize() []byte {
+	b := make([]byte, metaHeaderSize+8+8+8+8+8+4+4+8+8+4+1)
+
+	copy(b[:8], MetaMagic[:])
+	binary.LittleEndian.PutUint16(b[8:10], MetaVersion)
+	b[10] = byte(meta.Order)
+	order := meta.Order.binary()
+
+	body := b[metaHeaderSize:]
+	order.PutUint64(body[:8], uint64(meta.Freelist))
+	order.PutUint64(body[8:16], uint64(meta.Classes))
+	order.PutUint64(body[16:24], meta.TxID)
+	order.PutUint64(body[24:32], uint64(meta.IDs))
+	order.PutUint64(body[32:40], meta.StateSum)
+	order.PutUint32(body[40:44], uint32(meta.PageSize))
+	order.PutUint32(body[44:48], uint32(meta.FreelistPages))
+	order.PutUint64(body[48:56], uint64(meta.Root))
+	order.PutUint64(body[56:64], uint64(meta.Buckets))
+	order.PutUint32(body[64:68], uint32(meta.ReservedPages))
+	body[68] = byte(meta.ChecksumPlacement)
+
+	return meta.appendUser(b)
+}
+
+func (meta *Metainfo) Deserialize(b []byte) error {
+	if len(b) < metaHeaderSize {
+		return fmt.Errorf("meta/deserialize: decode head: %w", ErrWrongBytes)
+	}
+
+	if !bytes.Equal(b[:8], MetaMagic[:]) {
+		return fmt.Errorf("meta/deserialize: %w", ErrBadMagic)
+	}
+
+	version := binary.LittleEndian.Uint16(b[8:10])
+	if version < MetaVersion-3 || version > MetaVersion {
+		return fmt.Errorf("meta/deserialize: version %d: %w", version, ErrUnsupportedVersion)
+	}
+
+	if b[10] > byte(BigEndian) {
+		return fmt.Errorf("meta/deserialize: byte order %d: %w", b[10], ErrUnsupportedVersion)
+	}
+	meta.Order = ByteOrder(b[10])
+	order := meta.Order.binary()
+
+	body := b[metaHeaderSize:]
+	size := 8 + 8 + 8 + 8 + 8 + 4 + 4 + 8 + 8
+	if version >= MetaVersion-2 {
+		size += 4
+	}
+	if version == MetaVersion {
+		size++
+	}
+	if len(body) < size {
+		return fmt.Errorf("meta/deserialize: decode body: %w", ErrWrongBytes)
+	}
+
+	meta.Freelist = PageNum(order.Uint64(body[:8]))
+	meta.Classes = PageNum(order.Uint64(body[8:16]))
+	meta.TxID = order.Uint64(body[16:24])
+	meta.IDs = PageNum(order.Uint64(body[24:32]))
+	meta.StateSum = order.Uint64(body[32:40])
+	meta.PageSize = int(order.Uint32(body[40:44]))
+	meta.FreelistPages = int(order.Uint32(body[44:48]))
+	meta.Root = PageNum(order.Uint64(body[48:56]))
+	meta.Buckets = PageNum(order.Uint64(body[56:64]))
+
+	meta.ReservedPages = int(minReservedPages)
+	if version >= MetaVersion-2 {
+		meta.ReservedPages = int(order.Uint32(body[64:68]))
+	}
+	if meta.ReservedPages < int(minReservedPages) {
+		return fmt.Errorf(
+			"meta/deserialize: %d reserved pages, min %d: %w",
+			meta.ReservedPages, minReservedPages, ErrWrongBytes,
+		)
+	}
+
+	meta.ChecksumPlacement = ChecksumHeader
+	if version == MetaVersion {
+		if body[68] > byte(ChecksumFooter) {
+			return fmt.Errorf("meta/deserialize: checksum placement %d: %w", body[68], ErrUnsupportedVersion)
+		}
+		meta.ChecksumPlacement = ChecksumPlacement(body[68])
+	}
+
+	meta.user = nil
+	if version >= MetaVersion-1 {
+		if err := meta.decodeUser(body[size:]); err != nil {
+			return fmt.Errorf("meta/deserialize: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Clone returns a copy of the metainfo.
+func (meta *Metainfo) Clone() *Metainfo {
+	clone := *meta
+	clone.user = maps.Clone(meta.user)
+	return &clone
+}
+
+func (meta *Metainfo) Equal(other *Metainfo) bool {
+	return meta.Freelist == other.Freelist &&
+		meta.FreelistPages == other.FreelistPages &&
+		meta.Classes == other.Classes &&
+		meta.IDs == other.IDs &&
+		meta.Root == other.Root &&
+		meta.Buckets == other.Buckets &&
+		meta.TxID == other.TxID &&
+		meta.StateSum == other.StateSum &&
+		meta.PageSize == other.PageSize &&
+		meta.Order == other.Order &&
+		meta.ReservedPages == other.ReservedPages &&
+		meta.ChecksumPlacement == other.ChecksumPlacement &&
+		maps.EqualFunc(meta.user, other.user, bytes.Equal)
+}
+
+// AllocStrategy decides which released page Freelist.Next hands out.
+type AllocStrategy uint8
+
+const (
+	// LIFO hands out the most recently released page.
+	LIFO AllocStrategy = iota
+	// LowestFirst hands out the lowest released page, which keeps live
+	// pages low in the file and free ones at its tail.
+	LowestFirst
+)
+
+type Freelist struct {
+	Max      PageNum
+	Released []PageNum
+
+	// Pending holds the pages freed by the transaction with the given id.
+	// They are not handed out until ProcessRelease learns that no reader
+	// older than the transaction is left.
+	Pending map[uint64][]PageNum
+
+	// Order is the byte order Serialize and Deserialize use, the one
+	// recorded in the metainfo.
+	Order ByteOrder
+
+	// flags holds the freelist state: flistDirty when it changed since it
+	// was last flushed or recovered, flistReadOnly to freeze the freelist
+	// of a read-only pager.
+	flags flistFlags
+
+	strategy AllocStrategy
+
+	// begin is the first page past the reserved region, the lowest page
+	// the freelist hands out.
+	begin PageNum
+
+	onEvent allocObserver
+}
+
+type flistFlags uint8
+
+const (
+	flistDirty flistFlags = 1 << iota
+	flistReadOnly
+)
+
+func (flist *Freelist) has(flag flistFlags) bool {
+	return flist.flags&flag != 0
+}
+
+func (flist *Freelist) set(flag flistFlags, on bool) {
+	if on {
+		flist.flags |= flag
+	} else {
+		flist.flags &^= flag
+	}
+}
+
+func NewFreelist() *Freelist {
+	return &Freelist{
+		Max:      BeginFreeBlocks,
+		Released: make([]PageNum, 0),
+
+		flags: flistDirty,
+		begin: BeginFreeBlocks,
+	}
+}
+
+// Begin returns the first page past the reserved region, the lowest page
+// the freelist hands out. It is BeginFreeBlocks unless the store was
+// created with WithReservedPages.
+func (flist *Freelist) Begin() PageNum {
+	return flist.begin
+}
+
+// Next hands out a free page number. A read-only freelist hands out
+// nothing and returns ErrReadOnly.
+func (flist *Freelist) Next() (PageNum, error) {
+	if flist.has(flistReadOnly) {
+		return 0, fmt.Errorf("freelist/next: %w", ErrReadOnly)
+	}
+
+	flist.set(flistDirty, true)
+
+	if len(flist.Released) == 0 {
+		curr := flist.Max
+		flist.Max += 1
+		flist.emit(OpAlloc, curr)
+		return curr, nil
+	}
+
+	// LowestFirst keeps Released in descending order, so both strategies
+	// hand out the last element.
+	num := flist.Released[len(flist.Released)-1]
+	flist.Released = flist.Released[:len(flist.Released)-1]
+	flist.emit(OpAlloc, num)
+
+	return num, nil
+}
+
+// Simulate reports how n calls to Next would split between reusing
+// released pages and growing Max, without changing the freelist. A
+// read-only freelist hands out no pages at all.
+func (flist *Freelist) Simulate(n int) (reused, grown int) {
+	if flist.has(flistReadOnly) || n <= 0 {
+		return 0, 0
+	}
+
+	reused = min(n, len(flist.Released))
+	return reused, n - reused
+}
+
+// Release returns num to the freelist, or ErrPageNotAllocated when num
+// was never handed out, being Max or past it, and ErrPageFree when num is
+// already released or pending. Looking num up is linear in the free pages
+// unless the strategy is LowestFirst; ReleaseAll skips it for batches.
+// Reserved pages are ignored. A read-only freelist returns ErrReadOnly.
+func (flist *Freelist) Release(num PageNum) error {
+	if flist.has(flistReadOnly) {
+		return fmt.Errorf("freelist/release(num=%d): %w", num, ErrReadOnly)
+	}
+
+	if num < flist.begin {
+		return nil
+	}
+
+	if num >= flist.Max {
+		return fmt.Errorf("freelist/release(num=%d): max %d: %w", num, flist.Max, ErrPageNotAllocated)
+	}
+
+	if flist.strategy == LowestFirst {
+		i, found := slices.BinarySearchFunc(flist.Released, num, func(a, b PageNum) int {
+			return cmp.Compare(b, a)
+		})
+		if found || flist.isPending(num) {
+			return fmt.Errorf("freelist/release(num=%d): %w", num, ErrPageFree)
+		}
+		flist.Released = slices.Insert(flist.Released, i, num)
+	} else {
+		if flist.isFree(num) {
+			return fmt.Errorf("freelist/release(num=%d): %w", num, ErrPageFree)
+		}
+		flist.Released = append(flist.Released, num)
+	}
+	flist.set(flistDirty, true)
+	flist.emit(OpRelease, num)
+
+	return nil
+}
+
+// ReleaseAll releases a batch of pages at once, growing Released with a
+// single append instead of one per page. Page numbers repeated within the
+// batch are released once; unlike Release, pages released before are not
+// looked for, keeping the batch linear in its length. It checks the whole batch first and returns
+// ErrPageNotAllocated, releasing nothing, when a page is at or past Max.
+// A read-only freelist returns ErrReadOnly.
+func (flist *Freelist) ReleaseAll(nums []PageNum) error {
+	if flist.has(flistReadOnly) {
+		return fmt.Errorf("freelist/releaseAll: %w", ErrReadOnly)
+	}
+
+	seen := make(map[PageNum]struct{}, len(nums))
+	batch := make([]PageNum, 0, len(nums))
+	for _, num := range nums {
+		if num >= flist.Max {
+			return fmt.Errorf("freelist/releaseAll(num=%d): max %d: %w", num, flist.Max, ErrPageNotAllocated)
+		}
+
+		if _, ok := seen[num]; ok || num < flist.begin {
+			continue
+		}
+		seen[num] = struct{}{}
+		batch = append(batch, num)
+	}
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	flist.Released = append(flist.Released, batch...)
+	if flist.strategy == LowestFirst {
+		slices.SortFunc(flist.Released, func(a, b PageNum) int {
+			return cmp.Compare(b, a)
+		})
+	}
+	flist.set(flistDirty, true)
+
+	for _, num := range batch {
+		flist.emit(OpRelease, num)
+	}
+
+	return nil
+}
+
+// Defragment sorts Released in descending order, so that Next hands out
+// the lowest free page whatever the strategy, and drops the duplicates and
+// the page numbers out of range a damaged freelist may hold. It leaves Max
+// alone: Pager.ShrinkToFit defragments the freelist before it trims the
+// free pages at the tail of the file.
+func (flist *Freelist) Defragment() {
+	if flist.has(flistReadOnly) {
+		return
+	}
+
+	released := slices.SortedFunc(slices.Values(flist.Released), func(a, b PageNum) int {
+		return cmp.Compare(b, a)
+	})
+	released = slices.Compact(released)
+	released = slices.DeleteFunc(released, func(num PageNum) bool {
+		return num < flist.begin || num >= flist.Max
+	})
+
+	if !slices.Equal(released, flist.Released) {
+		flist.Released = released
+		flist.set(flistDirty, true)
+	}
+}
+
+// ReleasePending frees num on behalf of the transaction txid, keeping it
+// from being handed out while older readers may still read it. It does
+// nothing on a read-only freelist.
+func (flist *Freelist) ReleasePending(txid uint64, num PageNum) {
+	if flist.has(flistReadOnly) || num < flist.begin {
+		return
+	}
+
+	if flist.Pending == nil {
+		flist.Pending = make(map[uint64][]PageNum)
+	}
+	flist.Pending[txid] = append(flist.Pending[txid], num)
+	flist.set(flistDirty, true)
+}
+
+// ProcessRelease releases the pages pending for transactions up to
+// oldestReader, the id of the transaction the oldest reader still in
+// progress has seen. It stops at the first transaction holding a page at
+// or past Max with ErrPageNotAllocated, leaving its pages pending.
+func (flist *Freelist) ProcessRelease(oldestReader uint64) error {
+	for _, txid := range slices.Sorted(maps.Keys(flist.Pending)) {
+		if txid > oldestReader {
+			break
+		}
+
+		if err := flist.ReleaseAll(flist.Pending[txid]); err != nil {
+			return fmt.Errorf("freelist/processRelease(txid=%d): %w", txid, err)
+		}
+		delete(flist.Pending, txid)
+	}
+
+	return nil
+}
+
+func clonePending(pending map[uint64][]PageNum) map[uint64][]PageNum {
+	if pending == nil {
+		return nil
+	}
+
+	cloned := make(map[uint64][]PageNum, len(pending))
+	for txid, nums := range pending {
+		cloned[txid] = slices.Clone(nums)
+	}
+	return cloned
+}
+
+// freeCount returns the number of pages Serialize writes as free.
+func (flist *Freelist) freeCount() int {
+	n := len(flist.Released)
+	for _, nums := range flist.Pending {
+		n += len(nums)
+	}
+	return n
+}
+
+// Reserve claims the page number num instead of the one Next would hand
+// out. A released page is taken off the freelist, and a page at or past
+// Max bumps Max, releasing the pages it skips. Reserved pages and pages
+// in use return ErrPageUnavailable.
+func (flist *Freelist) Reserve(num PageNum) error {
+	if flist.has(flistReadOnly) {
+		return fmt.Errorf("freelist/reserve(num=%d): %w", num, ErrReadOnly)
+	}
+
+	switch i := slices.Index(flist.Released, num); {
+	case num < flist.begin:
+		return fmt.Errorf("freelist/reserve(num=%d): reserved page: %w", num, ErrPageUnavailable)
+	case i >= 0:
+		flist.Released = slices.Delete(flist.Released, i, i+1)
+	case num >= flist.Max:
+		skipped := flist.Max
+		flist.Max = num + 1
+		for ; skipped < num; skipped++ {
+			flist.Release(skipped)
+		}
+	default:
+		return fmt.Errorf("freelist/reserve(num=%d): page in use: %w", num, ErrPageUnavailable)
+	}
+
+	flist.set(flistDirty, true)
+	flist.emit(OpAlloc, num)
+
+	return nil
+}
+
+// Clone returns a deep copy of the freelist. The copy does not emit
+// allocation events.
+func (flist *Freelist) Clone() *Freelist {
+	return &Freelist{
+		Max:      flist.Max,
+		Released: slices.Clone(flist.Released),
+		Pending:  clonePending(flist.Pending),
+		Order:    flist.Order,
+
+		flags:    flist.flags,
+		strategy: flist.strategy,
+		begin:    flist.begin,
+	}
+}
+
+// Contains reports whether num is currently free.
+func (flist *Freelist) Contains(num PageNum) bool {
+	return slices.Contains(flist.Released, num)
+}
+
+// isFree reports whether num is released or pending for readers.
+func (flist *Freelist) isFree(num PageNum) bool {
+	return slices.Contains(flist.Released, num) || flist.isPending(num)
+}
+
+// isPending reports whether num is pending for readers.
+func (flist *Freelist) isPending(num PageNum) bool {
+	for _, nums := range flist.Pending {
+		if slices.Contains(nums, num) {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of released pages.
+func (flist *Freelist) Count() int {
+	return len(flist.Released)
+}
+
+// Allocated returns the number of pages handed out and not released,
+// reserved pages excluded.
+func (flist *Freelist) Allocated() int64 {
+	return int64(flist.Max-flist.begin) - int64(len(flist.Released))
+}
+
+func (flist *Freelist) setStrategy(strategy AllocStrategy) {
+	flist.strategy = strategy
+
+	if strategy == LowestFirst {
+		slices.SortFunc(flist.Released, func(a, b PageNum) int {
+			return cmp.Compare(b, a)
+		})
+	}
+}
+
+func (flist *Freelist) emit(op AllocOp, num PageNum) {
+	if flist.onEvent != nil {
+		flist.onEvent.onAllocEvent(op, num)
+	}
+}
+
+// freelistHeaderSize covers the checksum, Max and the released count.
+const freelistHeaderSize = 4 + 8 + 4
+
+// freelistChunk is the number of page numbers WriteTo and ReadFrom
+// encode at a time.
+const freelistChunk = 512
+
+// Serialize encodes the freelist behind a CRC32C of the rest of the
+// encoding. Pending pages are written as released: no reader outlives the
+// pager, so they are free by the time the freelist is read back.
+func (flist *Freelist) Serialize() []byte {
+	var buf bytes.Buffer
+	buf.Grow(freelistHeaderSize + 8*flist.freeCount())
+
+	// Writes to a bytes.Buffer do not fail.
+	_, _ = flist.WriteTo(&buf)
+
+	return buf.Bytes()
+}
+
+// free yields the released pages, then the pending ones by transaction.
+func (flist *Freelist) free() iter.Seq[PageNum] {
+	return func(yield func(PageNum) bool) {
+		for _, num := range flist.Released {
+			if !yield(num) {
+				return
+			}
+		}
+		for _, txid := range slices.Sorted(maps.Keys(flist.Pending)) {
+			for _, num := range flist.Pending[txid] {
+				if !yield(num) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// WriteTo writes the freelist to w in the format of Serialize, a chunk of
+// page numbers at a time instead of all of them at once. The checksum
+// leads the header, so the page numbers are encoded twice: once for the
+// checksum and once for w.
+func (flist *Freelist) WriteTo(w io.Writer) (int64, error) {
+	order := flist.Order.binary()
+	chunk := make([]byte, 0, 8*freelistChunk)
+
+	// each encodes the page numbers into chunk and hands every full chunk,
+	// and the last one, to fn.
+	each := func(fn func([]byte) error) error {
+		chunk = chunk[:0]
+		for num := range flist.free() {
+			chunk = chunk[:len(chunk)+8]
+			order.PutUint64(chunk[len(chunk)-8:], uint64(num))
+			if len(chunk) == cap(chunk) {
+				if err := fn(chunk); err != nil {
+					return err
+				}
+				chunk = chunk[:0]
+			}
+		}
+		return fn(chunk)
+	}
+
+	head := make([]byte, freelistHeaderSize)
+	order.PutUint64(head[4:12], uint64(flist.Max))
+	order.PutUint32(head[12:16], uint32(flist.freeCount()))
+
+	crc := crc32.Checksum(head[4:], castagnoli)
+	_ = each(func(b []byte) error {
+		crc = crc32.Update(crc, castagnoli, b)
+		return nil
+	})
+	order.PutUint32(head[:4], crc)
+
+	n, err := w.Write(head)
+	written := int64(n)
+	if err != nil {
+		return written, fmt.Errorf("freelist/writeTo: %w", err)
+	}
+
+	err = each(func(b []byte) error {
+		n, err := w.Write(b)
+		written += int64(n)
+		return err
+	})
+	if err != nil {
+		return written, fmt.Errorf("freelist/writeTo: %w", err)
+	}
+
+	return written, nil
+}
+
+// Deserialize decodes a freelist written by Serialize. A checksum that
+// does not match, a Max below Begin or a released page outside of
+// [Begin, Max), such as one inside the reserved region, is reported as
+// ErrFreelistCorrupt.
+func (flist *Freelist) Deserialize(b []byte) error {
+	if _, err := flist.readFrom(bytes.NewReader(b)); err != nil {
+		return fmt.Errorf("freelist/deserialize: %w", err)
+	}
+
+	return nil
+}
+
+// ReadFrom reads a freelist written by WriteTo from r, a chunk of page
+// numbers at a time, and fails as Deserialize does. It reads exactly the
+// freelist and leaves whatever follows it in r. The freelist is only
+// replaced once all of it was read and verified.
+func (flist *Freelist) ReadFrom(r io.Reader) (int64, error) {
+	n, err := flist.readFrom(r)
+	if err != nil {
+		return n, fmt.Errorf("freelist/readFrom: %w", err)
+	}
+
+	return n, nil
+}
+
+func (flist *Freelist) readFrom(r io.Reader) (int64, error) {
+	order := flist.Order.binary()
+
+	head := make([]byte, freelistHeaderSize)
+	n, err := io.ReadFull(r, head)
+	read := int64(n)
+	if err != nil {
+		return read, fmt.Errorf("decode head: %w", shortRead(err))
+	}
+
+	max := PageNum(order.Uint64(head[4:12]))
+	count := int(order.Uint32(head[12:16]))
+	crc := crc32.Checksum(head[4:], castagnoli)
+
+	released := make([]PageNum, 0, min(count, freelistChunk))
+	chunk := make([]byte, 8*freelistChunk)
+	for left := count; left > 0; {
+		b := chunk[:8*min(left, freelistChunk)]
+
+		n, err := io.ReadFull(r, b)
+		read += int64(n)
+		if err != nil {
+			return read, fmt.Errorf("decode body: %w", shortRead(err))
+		}
+		crc = crc32.Update(crc, castagnoli, b)
+
+		for off := 0; off < len(b); off += 8 {
+			released = append(released, PageNum(order.Uint64(b[off:off+8])))
+		}
+		left -= len(b) / 8
+	}
+
+	if crc != order.Uint32(head[:4]) {
+		return read, fmt.Errorf("checksum: %w", ErrFreelistCorrupt)
+	}
+
+	if max < flist.begin {
+		return read, fmt.Errorf("max %d: %w", max, ErrFreelistCorrupt)
+	}
+
+	for _, num := range released {
+		if num < flist.begin || num >= max {
+			return read, fmt.Errorf(
+				"released page %d, max %d: %w",
+				num, max, ErrFreelistCorrupt,
+			)
+		}
+	}
+
+	flist.Max = max
+	flist.Released = released
+	flist.Pending = nil
+	flist.set(flistDirty, false)
+
+	return read, nil
+}
+
+// shortRead reports a freelist cut short as ErrWrongBytes.
+func shortRead(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrWrongBytes
+	}
+	return err
+}
+
 func (flist *Freelist) Equal(other *Freelist) bool {
 	if flist.Max != other.Max || len(flist.Released) != len(other.Released) {
 		return false
@@ -0,0 +1,851 @@
+package data
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	prand "github.com/protomem/embedstore/pkg/rand"
+)
+
+func newTestPager(t *testing.T) *Pager {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	t.Cleanup(func() { _ = pgr.Close() })
+
+	for i := 0; i < 5; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.flist))
+		pg.Write([]byte("data"))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+	}
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	return pgr
+}
+
+func assertSameFiles(t *testing.T, expected, actual string) {
+	t.Helper()
+
+	expectedb, err := os.ReadFile(expected)
+	if err != nil {
+		t.Fatalf("Failed to read file %s, with error %s", expected, err)
+	}
+
+	actualb, err := os.ReadFile(actual)
+	if err != nil {
+		t.Fatalf("Failed to read file %s, with error %s", actual, err)
+	}
+
+	if !bytes.Equal(expectedb, actualb) {
+		t.Fatalf(
+			"Failed to compare files: expected %d bytes, actual %d bytes",
+			len(expectedb), len(actualb),
+		)
+	}
+}
+
+func TestPager_SnapshotFallback(t *testing.T) {
+	pgr := newTestPager(t)
+
+	snapname := filepath.Join(t.TempDir(), "test_snapshot")
+	snap, err := os.Create(snapname)
+	if err != nil {
+		t.Fatalf("Failed to create file %s, with error %s", snapname, err)
+	}
+	defer snap.Close()
+
+	if err := pgr.copyPagesTo(snap); err != nil {
+		t.Fatalf("Failed to copy pages, with error %s", err)
+	}
+
+	assertSameFiles(t, pgr.path, snapname)
+}
+
+func TestPager_SnapshotReflink(t *testing.T) {
+	pgr := newTestPager(t)
+
+	snapname := filepath.Join(filepath.Dir(pgr.path), "test_snapshot")
+	snap, err := os.Create(snapname)
+	if err != nil {
+		t.Fatalf("Failed to create file %s, with error %s", snapname, err)
+	}
+	defer snap.Close()
+
+	if err := pgr.reflinkTo(snap); err != nil {
+		if errors.Is(err, errReflinkUnsupported) {
+			t.Skipf("Reflink is not available: %s", err)
+		}
+		t.Fatalf("Failed to reflink file, with error %s", err)
+	}
+
+	assertSameFiles(t, pgr.path, snapname)
+}
+
+func TestPager_FlushOrder(t *testing.T) {
+	var steps []flushStep
+	flushStepHook = func(step flushStep) { steps = append(steps, step) }
+	defer func() { flushStepHook = nil }()
+
+	assertSteps := func(t *testing.T, expected []flushStep) {
+		t.Helper()
+
+		if !slices.Equal(expected, steps) {
+			t.Fatalf("Failed to compare flush steps: expected %q, actual %q", expected, steps)
+		}
+		steps = nil
+	}
+
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	t.Run("create", func(t *testing.T) {
+		assertSteps(t, []flushStep{
+			flushStepSyncData,
+			flushStepWriteFreelist,
+			flushStepWriteMeta,
+			flushStepSyncMeta,
+			flushStepSyncDir,
+		})
+	})
+
+	t.Run("dirty freelist", func(t *testing.T) {
+		pgr.Freelist().Next()
+
+		if _, err := pgr.Flush(); err != nil {
+			t.Fatalf("Failed to flush pager, with error %s", err)
+		}
+
+		assertSteps(t, []flushStep{
+			flushStepSyncData,
+			flushStepWriteFreelist,
+			flushStepWriteMeta,
+			flushStepSyncMeta,
+		})
+	})
+
+	t.Run("clean freelist", func(t *testing.T) {
+		pg := pgr.Alloc().WithNum(pgr.Freelist().Max - 1)
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %d, with error %s", pg.Num, err)
+		}
+
+		if _, err := pgr.Flush(); err != nil {
+			t.Fatalf("Failed to flush pager, with error %s", err)
+		}
+
+		assertSteps(t, []flushStep{
+			flushStepSyncData,
+			flushStepWriteMeta,
+			flushStepSyncMeta,
+		})
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		if _, err := pgr.Flush(); err != nil {
+			t.Fatalf("Failed to flush pager, with error %s", err)
+		}
+
+		assertSteps(t, nil)
+	})
+}
+
+func TestLockFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	open := func(t *testing.T) *os.File {
+		t.Helper()
+
+		f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, DefaultFilePerm)
+		if err != nil {
+			t.Fatalf("Failed to open file %s, with error %s", filename, err)
+		}
+		t.Cleanup(func() { _ = f.Close() })
+
+		return f
+	}
+
+	first, second, third := open(t), open(t), open(t)
+
+	if err := lockFile(first, false); err != nil {
+		t.Fatalf("Failed to lock file, with error %s", err)
+	}
+	for _, shared := range []bool{false, true} {
+		if err := lockFile(second, shared); !errors.Is(err, ErrLocked) {
+			t.Fatalf(
+				"Failed to lock locked file (shared %t): expected error %s, actual %v",
+				shared, ErrLocked, err,
+			)
+		}
+	}
+
+	if err := unlockFile(first); err != nil {
+		t.Fatalf("Failed to unlock file, with error %s", err)
+	}
+
+	// Shared locks coexist, but keep out an exclusive one.
+	for _, f := range []*os.File{second, third} {
+		if err := lockFile(f, true); err != nil {
+			t.Fatalf("Failed to take shared lock, with error %s", err)
+		}
+	}
+	if err := lockFile(first, false); !errors.Is(err, ErrLocked) {
+		t.Fatalf(
+			"Failed to lock shared file exclusively: expected error %s, actual %v",
+			ErrLocked, err,
+		)
+	}
+}
+
+func TestPager_Locked(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	for _, readOnly := range []bool{false, true} {
+		if _, err := NewPagerWithOptions(filename, psize, WithReadOnly(readOnly)); !errors.Is(err, ErrLocked) {
+			t.Fatalf(
+				"Failed to open locked store (read-only %t): expected error %s, actual %v",
+				readOnly, ErrLocked, err,
+			)
+		}
+	}
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	readers := make([]*Pager, 2)
+	for i := range readers {
+		readers[i], err = NewPagerWithOptions(filename, psize, WithReadOnly(true))
+		if err != nil {
+			t.Fatalf("Failed to open store read-only, with error %s", err)
+		}
+		defer readers[i].Close()
+	}
+}
+
+func TestPager_StateChecksumIncremental(t *testing.T) {
+	pgr := newTestPager(t)
+	pgr.opts.StateChecksum = true
+	if err := pgr.recomputeStateSum(); err != nil {
+		t.Fatalf("Failed to recompute state checksum, with error %s", err)
+	}
+
+	max := pgr.Freelist().Max
+	pgr.Freelist().Release(max - 1)
+	pgr.Freelist().Release(max - 3)
+
+	pg := pgr.Alloc().WithNum(max - 2)
+	pg.Write([]byte("updated"))
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	if _, err := pgr.ShrinkToFit(); err != nil {
+		t.Fatalf("Failed to shrink pager, with error %s", err)
+	}
+
+	if err := pgr.Compact(); err != nil {
+		t.Fatalf("Failed to compact pager, with error %s", err)
+	}
+
+	incremental := pgr.StateChecksum()
+	if err := pgr.recomputeStateSum(); err != nil {
+		t.Fatalf("Failed to recompute state checksum, with error %s", err)
+	}
+
+	if incremental != pgr.StateChecksum() {
+		t.Fatalf(
+			"Failed to compare state checksums: incremental %x, recomputed %x",
+			incremental, pgr.StateChecksum(),
+		)
+	}
+}
+
+func TestPager_PageCompressionStorage(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := NewPagerWithOptions(filename, psize, WithPageCompression(true))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	compressible := pgr.Alloc().WithNum(nextFree(t, pgr.flist))
+	copy(compressible.Data, bytes.Repeat([]byte("embedstore"), psize/10))
+
+	incompressible := pgr.Alloc().WithNum(nextFree(t, pgr.flist))
+	_, _ = rand.Read(incompressible.Data[:pgr.MaxLogicalPayload()])
+
+	for _, tc := range []struct {
+		pg   *Page
+		flag byte
+	}{
+		{pg: compressible, flag: pageStoredCompressed},
+		{pg: incompressible, flag: pageStoredRaw},
+	} {
+		if err := pgr.Write(tc.pg); err != nil {
+			t.Fatalf("Failed to write page %d, with error %s", tc.pg.Num, err)
+		}
+
+		b := make([]byte, psize)
+		if _, err := pgr.store.ReadAt(b, int64(tc.pg.Num)*int64(psize)); err != nil {
+			t.Fatalf("Failed to read raw page %d, with error %s", tc.pg.Num, err)
+		}
+
+		body := b[pageHeaderSize:]
+		if body[0] != tc.flag {
+			t.Fatalf(
+				"Failed to compare storage flag of page %d: expected %d, actual %d",
+				tc.pg.Num, tc.flag, body[0],
+			)
+		}
+
+		stored := int(binary.LittleEndian.Uint32(body[1:5]))
+		if tc.flag == pageStoredCompressed && stored >= psize/2 {
+			t.Fatalf(
+				"Failed to check compressed page %d: %d of %d bytes stored",
+				tc.pg.Num, stored, psize,
+			)
+		}
+	}
+}
+
+func TestPager_FlushVectored(t *testing.T) {
+	for name, vectored := range map[string]bool{"vectored": true, "sequential": false} {
+		t.Run(name, func(t *testing.T) {
+			vectoredWrites = vectored
+			defer func() { vectoredWrites = true }()
+
+			pgr := newTestPager(t)
+
+			pgr.Freelist().Release(pgr.Freelist().Max - 1)
+			pgr.Freelist().Release(pgr.Freelist().Max - 2)
+
+			if _, err := pgr.Flush(); err != nil {
+				t.Fatalf("Failed to flush pager, with error %s", err)
+			}
+
+			reopened, err := NewPagerWithOptions(pgr.path, pgr.psize, WithFileLock(false))
+			if err != nil {
+				t.Fatalf(
+					"Failed to open pager by path %s, with error %s",
+					pgr.path, err,
+				)
+			}
+			defer reopened.Close()
+
+			if !pgr.Meta().Equal(reopened.Meta()) || !pgr.Freelist().Equal(reopened.Freelist()) {
+				t.Fatalf(
+					"Failed to compare recovered state: expected %+v %+v, actual %+v %+v",
+					pgr.Meta(), pgr.Freelist(), reopened.Meta(), reopened.Freelist(),
+				)
+			}
+		})
+	}
+}
+
+func BenchmarkPager_FlushMetaPages(b *testing.B) {
+	for name, vectored := range map[string]bool{"vectored": true, "sequential": false} {
+		b.Run(name, func(b *testing.B) {
+			vectoredWrites = vectored
+			defer func() { vectoredWrites = true }()
+
+			filename := filepath.Join(b.TempDir(), "bench_data")
+
+			pgr, err := NewPager(filename, os.Getpagesize())
+			if err != nil {
+				b.Fatalf(
+					"Failed to create pager by path %s, with error %s",
+					filename, err,
+				)
+			}
+			defer pgr.Close()
+
+			metapg := pgr.Alloc().WithNum(DefaultMetaPage)
+			copy(metapg.Data, pgr.Meta().Serialize())
+
+			flistpg := pgr.Alloc().WithNum(DefaultFlistPage)
+			copy(flistpg.Data, pgr.Freelist().Serialize())
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := pgr.writeMetaPages(metapg, flistpg); err != nil {
+					b.Fatalf("Failed to write meta pages, with error %s", err)
+				}
+			}
+		})
+	}
+}
+
+func TestPager_AllocHints(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	open := func(t *testing.T) *Pager {
+		t.Helper()
+
+		pgr, err := NewPagerWithOptions(filename, psize, WithAllocHints(true))
+		if err != nil {
+			t.Fatalf(
+				"Failed to open pager by path %s, with error %s",
+				filename, err,
+			)
+		}
+		return pgr
+	}
+
+	pgr := open(t)
+	for i := 0; i < 5; i++ {
+		pgr.Freelist().Next()
+	}
+	pgr.Freelist().Release(pgr.Freelist().Max - 2)
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	staleHint, err := os.ReadFile(hintPath(filename))
+	if err != nil {
+		t.Fatalf("Failed to read hint file, with error %s", err)
+	}
+
+	t.Run("clean shutdown", func(t *testing.T) {
+		expectedFlist := pgr.Freelist()
+
+		pgr = open(t)
+		defer pgr.Close()
+
+		if !pgr.hinted {
+			t.Fatalf("Failed to check recovery: hint file was not used")
+		}
+
+		if !expectedFlist.Equal(pgr.Freelist()) {
+			t.Fatalf(
+				"Failed to compare freelists: expected %+v, actual %+v",
+				expectedFlist, pgr.Freelist(),
+			)
+		}
+
+		pgr.Freelist().Next()
+		if _, err := pgr.Flush(); err != nil {
+			t.Fatalf("Failed to flush pager, with error %s", err)
+		}
+	})
+
+	t.Run("stale hint", func(t *testing.T) {
+		if err := os.WriteFile(hintPath(filename), staleHint, DefaultFilePerm); err != nil {
+			t.Fatalf("Failed to write hint file, with error %s", err)
+		}
+
+		expectedFlist := pgr.Freelist()
+
+		pgr = open(t)
+		defer pgr.Close()
+
+		if pgr.hinted {
+			t.Fatalf("Failed to check recovery: stale hint file was used")
+		}
+
+		if !expectedFlist.Equal(pgr.Freelist()) {
+			t.Fatalf(
+				"Failed to compare freelists: expected %+v, actual %+v",
+				expectedFlist, pgr.Freelist(),
+			)
+		}
+	})
+}
+
+func TestPager_FlushNoSync(t *testing.T) {
+	var steps []flushStep
+	flushStepHook = func(step flushStep) { steps = append(steps, step) }
+	defer func() { flushStepHook = nil }()
+
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := NewPagerWithOptions(filename, os.Getpagesize(), WithSync(false))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	expected := []flushStep{flushStepWriteFreelist, flushStepWriteMeta}
+	if !slices.Equal(expected, steps) {
+		t.Fatalf("Failed to compare flush steps: expected %q, actual %q", expected, steps)
+	}
+}
+
+type cacheObserver struct {
+	NopObserver
+	hits, misses int
+}
+
+func (o *cacheObserver) OnCacheHit(PageNum)  { o.hits += 1 }
+func (o *cacheObserver) OnCacheMiss(PageNum) { o.misses += 1 }
+
+func TestFreelist_DeserializeReserved(t *testing.T) {
+	flist := NewFreelist()
+	for i := 0; i < 3; i++ {
+		flist.Next()
+	}
+	flist.Release(BeginFreeBlocks)
+
+	reserved := NewFreelist()
+	reserved.begin = BeginFreeBlocks + 1
+	if err := reserved.Deserialize(flist.Serialize()); !errors.Is(err, ErrFreelistCorrupt) {
+		t.Fatalf(
+			"Failed to reject released page inside reserved region: expected error %s, actual %v",
+			ErrFreelistCorrupt, err,
+		)
+	}
+}
+
+func TestPageCache(t *testing.T) {
+	pgr := newTestPager(t)
+	pgr.cache = newPageCache(2, 0)
+	cpgr := pgr.cache
+
+	o := &cacheObserver{}
+	pgr.observer = o
+
+	num := BeginFreeBlocks
+
+	for i := 0; i < 2; i++ {
+		pg, err := pgr.Read(num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", num, err)
+		}
+
+		// Mutating a returned page must not leak into the cache.
+		pg.Write([]byte("mutated"))
+	}
+
+	if hits, misses := cpgr.counters(); hits != 1 || misses != 1 {
+		t.Fatalf(
+			"Failed to compare cache counters: expected 1 hit and 1 miss, actual %d and %d",
+			hits, misses,
+		)
+	}
+	if o.hits != 1 || o.misses != 1 {
+		t.Fatalf(
+			"Failed to compare observed cache lookups: expected 1 hit and 1 miss, actual %d and %d",
+			o.hits, o.misses,
+		)
+	}
+
+	pg, err := pgr.Read(num)
+	if err != nil {
+		t.Fatalf("Failed to read page %d, with error %s", num, err)
+	}
+	if !bytes.Equal(bytes.TrimRight(pg.Data, "\x00"), []byte("data")) {
+		t.Fatalf("Failed to compare cached page data: expected %q, actual %q", "data", pg.Data)
+	}
+
+	pg.Write([]byte("updated"))
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	// The write drops the page, so the next read goes to the file and
+	// caches the new bytes.
+	for i := 0; i < 2; i++ {
+		cached, err := pgr.Read(num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", num, err)
+		}
+		if !bytes.Equal(cached.Data, pg.Data) {
+			t.Fatalf("Failed to compare cached page data after write")
+		}
+	}
+
+	if hits, misses := cpgr.counters(); hits != 3 || misses != 2 {
+		t.Fatalf(
+			"Failed to compare cache counters: expected 3 hits and 2 misses, actual %d and %d",
+			hits, misses,
+		)
+	}
+
+	// Reading two other pages evicts the least recently used one.
+	for _, other := range []PageNum{num + 1, num + 2} {
+		if _, err := pgr.Read(other); err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", other, err)
+		}
+	}
+
+	if _, ok := cpgr.entries[num]; ok || cpgr.lru.Len() != 2 {
+		t.Fatalf("Failed to check cache eviction: page %d still cached", num)
+	}
+}
+
+func TestPageCache_Readahead(t *testing.T) {
+	pgr := newTestPager(t)
+	pgr.cache = newPageCache(8, 3)
+	cpgr := pgr.cache
+
+	// Five pages read in order take one read for the first, one window
+	// for the next three and one more for the last.
+	for num := BeginFreeBlocks; num < BeginFreeBlocks+5; num++ {
+		pg, err := pgr.Read(num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", num, err)
+		}
+		if !bytes.Equal(bytes.TrimRight(pg.Data, "\x00"), []byte("data")) {
+			t.Fatalf("Failed to compare page %d data: expected %q, actual %q", num, "data", pg.Data)
+		}
+	}
+
+	if hits, misses := cpgr.counters(); hits != 2 || misses != 3 {
+		t.Fatalf(
+			"Failed to compare cache counters: expected 2 hits and 3 misses, actual %d and %d",
+			hits, misses,
+		)
+	}
+
+	// The window of the last miss stops at the end of the freelist.
+	if cpgr.lru.Len() != 5 {
+		t.Fatalf("Failed to compare cached pages: expected %d, actual %d", 5, cpgr.lru.Len())
+	}
+}
+
+func TestPager_GrowFileSize(t *testing.T) {
+	pgr := newTestPager(t)
+
+	if _, err := pgr.Grow(10); err != nil {
+		t.Fatalf("Failed to grow pager, with error %s", err)
+	}
+
+	size, err := pgr.storeSize()
+	if err != nil {
+		t.Fatalf("Failed to get store size, with error %s", err)
+	}
+	if pgr.fileSize != size {
+		t.Fatalf("Failed to compare file size after grow: expected %d, actual %d", size, pgr.fileSize)
+	}
+}
+
+func TestPager_TxReplay(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	tx, err := pgr.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction, with error %s", err)
+	}
+
+	var pages []*Page
+	for i := 0; i < 3; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.flist))
+		pg.Write([]byte{byte(i + 1)})
+
+		if err := tx.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		pages = append(pages, pg)
+	}
+
+	if err := tx.log(); err != nil {
+		t.Fatalf("Failed to log transaction, with error %s", err)
+	}
+
+	// Crash while applying: the first page made it, the second one is
+	// torn and the rest of the file is gone.
+	if err := pgr.writeSealed(pages[0].Num, tx.pages[pages[0].Num]); err != nil {
+		t.Fatalf("Failed to apply page %d, with error %s", pages[0].Num, err)
+	}
+	torn := int64(pages[1].Num)*int64(psize) + int64(psize)/2
+	if err := pgr.store.Truncate(torn); err != nil {
+		t.Fatalf("Failed to truncate file, with error %s", err)
+	}
+	_ = pgr.wal.close()
+	_ = pgr.store.Close()
+
+	reopened, err := NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to reopen pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer reopened.Close()
+
+	if expected := pages[len(pages)-1].Num + 1; reopened.Freelist().Max != expected {
+		t.Fatalf(
+			"Failed to compare replayed freelist max: expected %d, actual %d",
+			expected, reopened.Freelist().Max,
+		)
+	}
+
+	for _, expectedPg := range pages {
+		actualPg, err := reopened.Read(expectedPg.Num)
+		if err != nil {
+			t.Fatalf("Failed to read replayed page %d, with error %s", expectedPg.Num, err)
+		}
+
+		if !bytes.Equal(expectedPg.Data, actualPg.Data) {
+			t.Fatalf("Failed to compare replayed page %d data", expectedPg.Num)
+		}
+	}
+
+	if info, err := os.Stat(walPath(filename)); err != nil || info.Size() != 0 {
+		t.Fatalf("Failed to check wal reset after replay: info %v, error %v", info, err)
+	}
+}
+
+func TestBtree(t *testing.T) {
+	pgr, err := NewMemPager(512)
+	if err != nil {
+		t.Fatalf("Failed to create in-memory pager, with error %s", err)
+	}
+	defer pgr.Close()
+
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	r := prand.NewRand(548)
+	shuffled := func(keys []string) []string {
+		keys = slices.Clone(keys)
+		for i := len(keys) - 1; i > 0; i-- {
+			j := r.Range(0, i+1)
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+		return keys
+	}
+
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%04d", i)
+	}
+
+	walked := func(tree *btree) []string {
+		var actual []string
+		err := tree.walk(func(key, val []byte) bool {
+			if string(val) != "val"+string(key) {
+				t.Fatalf("Failed to compare value of key %s: actual %s", key, val)
+			}
+			actual = append(actual, string(key))
+			return true
+		})
+		if err != nil {
+			t.Fatalf("Failed to walk tree, with error %s", err)
+		}
+		return actual
+	}
+
+	tree := &btree{pgr: pgr}
+	for _, key := range shuffled(keys) {
+		if err := tree.put([]byte(key), []byte("val"+key)); err != nil {
+			t.Fatalf("Failed to put key %s, with error %s", key, err)
+		}
+	}
+
+	if actual := walked(tree); !slices.Equal(keys, actual) {
+		t.Fatalf("Failed to compare walked keys: expected %d sorted keys, actual %v", len(keys), actual)
+	}
+
+	if root, err := tree.readNode(tree.root); err != nil || root.leaf {
+		t.Fatalf("Failed to check tree depth: root %+v, error %v", root, err)
+	}
+
+	deleted := shuffled(keys)[:len(keys)/2]
+	for _, key := range deleted {
+		if found, err := tree.delete([]byte(key)); err != nil || !found {
+			t.Fatalf("Failed to delete key %s: found %t, error %v", key, found, err)
+		}
+	}
+
+	var expected []string
+	for _, key := range keys {
+		if !slices.Contains(deleted, key) {
+			expected = append(expected, key)
+		}
+	}
+	if actual := walked(tree); !slices.Equal(expected, actual) {
+		t.Fatalf("Failed to compare walked keys: expected %d sorted keys, actual %v", len(expected), actual)
+	}
+
+	for _, key := range deleted {
+		if _, found, err := tree.get([]byte(key)); err != nil || found {
+			t.Fatalf("Failed to get deleted key %s: found %t, error %v", key, found, err)
+		}
+	}
+
+	for _, key := range expected {
+		if found, err := tree.delete([]byte(key)); err != nil || !found {
+			t.Fatalf("Failed to delete key %s: found %t, error %v", key, found, err)
+		}
+	}
+
+	if tree.root != 0 {
+		t.Fatalf("Failed to compare root of emptied tree: expected 0, actual %d", tree.root)
+	}
+
+	if used := int(pgr.flist.Max-BeginFreeBlocks) - len(pgr.flist.Released); used != 0 {
+		t.Fatalf("Failed to compare pages of emptied tree: expected 0, actual %d", used)
+	}
+}
+
+// nextFree hands out the next page of flist, failing tb when the
+// freelist refuses.
+func nextFree(tb testing.TB, flist *Freelist) PageNum {
+	tb.Helper()
+
+	num, err := flist.Next()
+	if err != nil {
+		tb.Fatalf("Failed to get next free page, with error %s", err)
+	}
+	return num
+}
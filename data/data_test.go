@@ -2,10 +2,18 @@ package data_test
 
 import (
 	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/crc32"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 	"testing"
+	"testing/iotest"
 
 	"github.com/protomem/embedstore/data"
 	"github.com/protomem/embedstore/pkg/rand"
@@ -25,7 +33,7 @@ func TestPager(t *testing.T) {
 		defer pgr.Close()
 
 		for i := 0; i < 10; i++ {
-			pg := pgr.Alloc().WithNum(pgr.Freelist().Next())
+			pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
 			pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
 
 			if err := pgr.Write(pg); err != nil {
@@ -36,7 +44,7 @@ func TestPager(t *testing.T) {
 			}
 		}
 
-		if err := pgr.Flush(); err != nil {
+		if _, err := pgr.Flush(); err != nil {
 			t.Fatalf("Failed to flush metainfo, with error %s", err)
 		}
 	})
@@ -74,9 +82,155 @@ func TestPager(t *testing.T) {
 	})
 }
 
+func TestPage_WithNum(t *testing.T) {
+	pg := data.NewPage(data.BeginFreeBlocks, 8)
+
+	numbered := pg.WithNum(data.BeginFreeBlocks + 1)
+	if pg.Num != data.BeginFreeBlocks {
+		t.Fatalf(
+			"Failed to compare receiver page number: expected %d, actual %d",
+			data.BeginFreeBlocks, pg.Num,
+		)
+	}
+	if numbered.Num != data.BeginFreeBlocks+1 {
+		t.Fatalf(
+			"Failed to compare page number: expected %d, actual %d",
+			data.BeginFreeBlocks+1, numbered.Num,
+		)
+	}
+
+	numbered.Write([]byte("shared"))
+	if !bytes.Equal(pg.Data, numbered.Data) {
+		t.Fatalf("Failed to check shared page data: expected %q, actual %q", numbered.Data, pg.Data)
+	}
+
+	clone := pg.Clone()
+	clone.Write([]byte("cloned"))
+	if bytes.Equal(pg.Data, clone.Data) {
+		t.Fatalf("Failed to check cloned page data: clone shares data with %q", pg.Data)
+	}
+}
+
+func TestPage_WriteAt(t *testing.T) {
+	pg := data.NewPage(data.BeginFreeBlocks, 8)
+	pg.Write([]byte("abcdefgh"))
+
+	if err := pg.WriteAt([]byte("AB"), 0); err != nil {
+		t.Fatalf("Failed to write at offset 0, with error %s", err)
+	}
+	if err := pg.WriteAt([]byte("GH"), len(pg.Data)-2); err != nil {
+		t.Fatalf("Failed to write at end of page, with error %s", err)
+	}
+	if err := pg.WriteAt(nil, len(pg.Data)); err != nil {
+		t.Fatalf("Failed to write nothing past last byte, with error %s", err)
+	}
+
+	if string(pg.Data) != "ABcdefGH" {
+		t.Fatalf("Failed to compare page data: expected %q, actual %q", "ABcdefGH", pg.Data)
+	}
+
+	for _, offset := range []int{len(pg.Data) - 1, len(pg.Data) + 1} {
+		if err := pg.WriteAt([]byte("XY"), offset); !errors.Is(err, data.ErrDataTooLarge) {
+			t.Fatalf(
+				"Failed to write at offset %d: expected error %s, actual %v",
+				offset, data.ErrDataTooLarge, err,
+			)
+		}
+	}
+	if err := pg.WriteAt([]byte("XY"), -1); !errors.Is(err, data.ErrWrongBytes) {
+		t.Fatalf("Failed to write at offset -1: expected error %s, actual %v", data.ErrWrongBytes, err)
+	}
+
+	if string(pg.Data) != "ABcdefGH" {
+		t.Fatalf("Failed to leave page data after refused writes: actual %q", pg.Data)
+	}
+}
+
+func TestPage_Checksum(t *testing.T) {
+	pgr, err := data.NewMemPager(512)
+	if err != nil {
+		t.Fatalf("Failed to create in-memory pager, with error %s", err)
+	}
+	defer pgr.Close()
+
+	pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+	pg.Write([]byte("data"))
+
+	if expected := crc32.Checksum(pg.Data, crc32.MakeTable(crc32.Castagnoli)); pg.Checksum() != expected {
+		t.Fatalf("Failed to compare page checksum: expected %08x, actual %08x", expected, pg.Checksum())
+	}
+	if pg.ValidateChecksum() {
+		t.Fatalf("Failed to validate page never read: expected %t, actual %t", false, true)
+	}
+
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	clean, err := pgr.Read(pg.Num)
+	if err != nil {
+		t.Fatalf("Failed to read page %d, with error %s", pg.Num, err)
+	}
+	if clean.Checksum() != pg.Checksum() || !clean.ValidateChecksum() {
+		t.Fatalf("Failed to validate clean page %d", pg.Num)
+	}
+
+	mutated := clean.Clone()
+	if !mutated.ValidateChecksum() {
+		t.Fatalf("Failed to validate clone of clean page %d", pg.Num)
+	}
+
+	mutated.Data[len(mutated.Data)-1] ^= 0xff
+	if mutated.ValidateChecksum() {
+		t.Fatalf("Failed to detect mutated page %d: expected %t, actual %t", pg.Num, false, true)
+	}
+
+	mutated.Reset()
+	if mutated.ValidateChecksum() {
+		t.Fatalf("Failed to clear checksum of reset page: expected %t, actual %t", false, true)
+	}
+}
+
+func TestPage_Reset(t *testing.T) {
+	pgr, err := data.NewMemPager(512)
+	if err != nil {
+		t.Fatalf("Failed to create in-memory pager, with error %s", err)
+	}
+	defer pgr.Close()
+
+	pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist())).WithType(data.PageTypeOverflow)
+	pg.Write([]byte("long stale contents"))
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %d, with error %s", pg.Num, err)
+	}
+
+	pg.Reset()
+	if pg.Num != 0 || pg.Type() != data.PageTypeData {
+		t.Fatalf("Failed to reset page number and type: %+v", pg)
+	}
+
+	pg = pg.WithNum(nextFree(t, pgr.Freelist()))
+	pg.Write([]byte("short"))
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %d, with error %s", pg.Num, err)
+	}
+
+	actualPg, err := pgr.Read(pg.Num)
+	if err != nil {
+		t.Fatalf("Failed to read page %d, with error %s", pg.Num, err)
+	}
+
+	expected := make([]byte, len(actualPg.Data))
+	copy(expected, "short")
+	if !bytes.Equal(actualPg.Data, expected) {
+		t.Fatalf("Failed to compare reused page data: expected %q, actual %q", "short", actualPg.Data)
+	}
+}
+
 func TestMetainfo_Serialization(t *testing.T) {
 	expectedMeta := data.NewMetainfo()
 	expectedMeta.Freelist = data.PageNum(rand.Range(1, 100))
+	expectedMeta.PageSize = os.Getpagesize()
 	expectedMetab := expectedMeta.Serialize()
 
 	actualMeta := new(data.Metainfo)
@@ -95,30 +249,2071 @@ func TestMetainfo_Serialization(t *testing.T) {
 	}
 }
 
-func TestFreelist_Serialization(t *testing.T) {
-	expectedFlist := data.NewFreelist()
-	for i := 0; i < 10; i++ {
-		expectedFlist.Next()
+func TestFreelist_ReleaseFirstPage(t *testing.T) {
+	flist := data.NewFreelist()
+
+	first := nextFree(t, flist)
+	if first != data.BeginFreeBlocks {
+		t.Fatalf(
+			"Failed to compare first page: expected %d, actual %d",
+			data.BeginFreeBlocks, first,
+		)
+	}
+
+	flist.Release(first)
+	if next := nextFree(t, flist); next != first {
+		t.Fatalf(
+			"Failed to reuse released first page: expected %d, actual %d",
+			first, next,
+		)
+	}
+
+	for _, num := range []data.PageNum{
+		data.DefaultMetaPage, data.DefaultFlistPage, data.ShadowMetaPage, data.ShadowFlistPage,
+	} {
+		flist.Release(num)
+	}
+	if len(flist.Released) != 0 {
+		t.Fatalf("Failed to protect reserved pages: released %v", flist.Released)
+	}
+}
+
+func TestFreelist_ReleaseUnallocated(t *testing.T) {
+	flist := data.NewFreelist()
+
+	allocated := nextFree(t, flist)
+	for _, num := range []data.PageNum{flist.Max, flist.Max + 10} {
+		if err := flist.Release(num); !errors.Is(err, data.ErrPageNotAllocated) {
+			t.Fatalf(
+				"Failed to release page %d past max: expected error %s, actual %v",
+				num, data.ErrPageNotAllocated, err,
+			)
+		}
+	}
+	if flist.Count() != 0 {
+		t.Fatalf("Failed to keep unallocated pages off the freelist: %+v", flist)
+	}
+
+	if err := flist.Release(allocated); err != nil {
+		t.Fatalf("Failed to release allocated page %d, with error %s", allocated, err)
+	}
+	if !flist.Contains(allocated) {
+		t.Fatalf("Failed to release allocated page %d: %+v", allocated, flist)
+	}
+
+	pgr, err := data.NewMemPager(512)
+	if err != nil {
+		t.Fatalf("Failed to create in-memory pager, with error %s", err)
+	}
+	defer pgr.Close()
+
+	if err := pgr.ReleasePage(pgr.Freelist().Max); !errors.Is(err, data.ErrPageNotAllocated) {
+		t.Fatalf("Failed to release page past max: expected error %s, actual %v", data.ErrPageNotAllocated, err)
+	}
+}
+
+func TestFreelist_ReleaseTwice(t *testing.T) {
+	for _, strategy := range []data.AllocStrategy{data.LIFO, data.LowestFirst} {
+		pgr, err := data.NewMemPager(512, data.WithAllocStrategy(strategy))
+		if err != nil {
+			t.Fatalf("Failed to create in-memory pager, with error %s", err)
+		}
+		defer pgr.Close()
+		flist := pgr.Freelist()
+
+		released, pending := nextFree(t, flist), nextFree(t, flist)
+		if err := flist.Release(released); err != nil {
+			t.Fatalf("Failed to release page %d, with error %s", released, err)
+		}
+		flist.ReleasePending(1, pending)
+
+		for _, num := range []data.PageNum{released, pending} {
+			if err := flist.Release(num); !errors.Is(err, data.ErrPageFree) {
+				t.Fatalf(
+					"Failed to release free page %d with strategy %d: expected error %s, actual %v",
+					num, strategy, data.ErrPageFree, err,
+				)
+			}
+		}
+		if !slices.Equal(flist.Released, []data.PageNum{released}) {
+			t.Fatalf(
+				"Failed to keep free pages off the freelist: expected %v, actual %v",
+				[]data.PageNum{released}, flist.Released,
+			)
+		}
 	}
+}
+
+func TestFreelist_ReleaseAll(t *testing.T) {
+	flist := data.NewFreelist()
 	for i := 0; i < 10; i++ {
-		if rand.Bool() {
-			expectedFlist.Release(data.PageNum(i))
+		nextFree(t, flist)
+	}
+
+	first, second := data.BeginFreeBlocks+2, data.BeginFreeBlocks+5
+	if err := flist.ReleaseAll([]data.PageNum{first, second, first}); err != nil {
+		t.Fatalf("Failed to release batch, with error %s", err)
+	}
+
+	if !slices.Equal(flist.Released, []data.PageNum{first, second}) {
+		t.Fatalf(
+			"Failed to compare released pages: expected %v, actual %v",
+			[]data.PageNum{first, second}, flist.Released,
+		)
+	}
+
+	if err := flist.ReleaseAll([]data.PageNum{data.BeginFreeBlocks, flist.Max}); !errors.Is(err, data.ErrPageNotAllocated) {
+		t.Fatalf(
+			"Failed to release batch past max: expected error %s, actual %v",
+			data.ErrPageNotAllocated, err,
+		)
+	}
+	if flist.Count() != 2 {
+		t.Fatalf("Failed to keep a rejected batch off the freelist: %+v", flist)
+	}
+
+	t.Run("lowest first", func(t *testing.T) {
+		pgr, err := data.NewMemPager(512, data.WithAllocStrategy(data.LowestFirst))
+		if err != nil {
+			t.Fatalf("Failed to create in-memory pager, with error %s", err)
+		}
+		defer pgr.Close()
+
+		for pgr.Freelist().Max <= 50 {
+			nextFree(t, pgr.Freelist())
+		}
+
+		pgr.Freelist().Release(40)
+		if err := pgr.Freelist().ReleaseAll([]data.PageNum{50, 10, 30}); err != nil {
+			t.Fatalf("Failed to release batch, with error %s", err)
+		}
+
+		for _, expected := range []data.PageNum{10, 30, 40, 50} {
+			if actual := nextFree(t, pgr.Freelist()); actual != expected {
+				t.Fatalf(
+					"Failed to compare lowest free page: expected %d, actual %d",
+					expected, actual,
+				)
+			}
+		}
+	})
+}
+
+func BenchmarkFreelist_ReleaseAll(b *testing.B) {
+	const n = 10000
+
+	nums := make([]data.PageNum, n)
+	for i := range nums {
+		nums[i] = data.BeginFreeBlocks + data.PageNum(i)
+	}
+
+	newFreelist := func() *data.Freelist {
+		flist := data.NewFreelist()
+		for i := 0; i < n; i++ {
+			nextFree(b, flist)
 		}
+		return flist
 	}
-	expectedFlistb := expectedFlist.Serialize()
 
-	actualFlist := new(data.Freelist)
-	if err := actualFlist.Deserialize(expectedFlistb); err != nil {
+	b.Run("Release", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			flist := newFreelist()
+			b.StartTimer()
+
+			for _, num := range nums {
+				_ = flist.Release(num)
+			}
+		}
+	})
+
+	b.Run("ReleaseAll", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			flist := newFreelist()
+			b.StartTimer()
+
+			if err := flist.ReleaseAll(nums); err != nil {
+				b.Fatalf("Failed to release batch, with error %s", err)
+			}
+		}
+	})
+}
+
+func TestFreelist_Contains(t *testing.T) {
+	flist := data.NewFreelist()
+
+	nums := []data.PageNum{nextFree(t, flist), nextFree(t, flist), nextFree(t, flist)}
+
+	if flist.Contains(nums[1]) || flist.Count() != 0 || flist.Allocated() != 3 {
 		t.Fatalf(
-			"Failed to deserialize freelist %+v, with error %s",
-			expectedFlist, err,
+			"Failed to check freelist before release: contains %t, count %d, allocated %d",
+			flist.Contains(nums[1]), flist.Count(), flist.Allocated(),
 		)
 	}
 
-	if !expectedFlist.Equal(actualFlist) {
+	flist.Release(nums[1])
+
+	if !flist.Contains(nums[1]) || flist.Count() != 1 || flist.Allocated() != 2 {
 		t.Fatalf(
-			"Failed to check for equals freelists: expected %d, actual %d",
-			expectedFlist, actualFlist,
+			"Failed to check freelist after release: contains %t, count %d, allocated %d",
+			flist.Contains(nums[1]), flist.Count(), flist.Allocated(),
+		)
+	}
+
+	if flist.Contains(nums[0]) || flist.Contains(data.DefaultMetaPage) {
+		t.Fatalf("Failed to check allocated pages are not free: %+v", flist)
+	}
+}
+
+func TestFreelist_Reserve(t *testing.T) {
+	flist := data.NewFreelist()
+
+	nums := []data.PageNum{nextFree(t, flist), nextFree(t, flist), nextFree(t, flist)}
+	flist.Release(nums[1])
+
+	if err := flist.Reserve(nums[1]); err != nil {
+		t.Fatalf("Failed to reserve released page %d, with error %s", nums[1], err)
+	}
+	if flist.Contains(nums[1]) {
+		t.Fatalf("Failed to take reserved page %d off the freelist: %+v", nums[1], flist)
+	}
+
+	max := flist.Max
+	if err := flist.Reserve(max); err != nil {
+		t.Fatalf("Failed to reserve max page %d, with error %s", max, err)
+	}
+	if flist.Max != max+1 {
+		t.Fatalf("Failed to compare max after reserve: expected %d, actual %d", max+1, flist.Max)
+	}
+
+	if err := flist.Reserve(flist.Max + 2); err != nil {
+		t.Fatalf("Failed to reserve page %d past max, with error %s", flist.Max+2, err)
+	}
+	if flist.Max != max+4 || flist.Count() != 2 {
+		t.Fatalf("Failed to release pages skipped by reserve: %+v", flist)
+	}
+
+	for _, num := range []data.PageNum{nums[0], data.DefaultMetaPage} {
+		if err := flist.Reserve(num); !errors.Is(err, data.ErrPageUnavailable) {
+			t.Fatalf(
+				"Failed to reserve page %d: expected error %s, actual %v",
+				num, data.ErrPageUnavailable, err,
+			)
+		}
+	}
+}
+
+func TestFreelist_Pending(t *testing.T) {
+	flist := data.NewFreelist()
+
+	nums := []data.PageNum{nextFree(t, flist), nextFree(t, flist), nextFree(t, flist)}
+
+	// A reader holds the snapshot of transaction 1 while transaction 2
+	// frees two of its pages and transaction 3 frees the last one.
+	const reader = 1
+	flist.ReleasePending(2, nums[0])
+	flist.ReleasePending(2, nums[1])
+	flist.ReleasePending(3, nums[2])
+
+	if err := flist.ProcessRelease(reader); err != nil {
+		t.Fatalf("Failed to process pending pages, with error %s", err)
+	}
+	if flist.Count() != 0 {
+		t.Fatalf("Failed to hold pending pages for reader %d: %+v", reader, flist)
+	}
+
+	for i := 0; i < 3; i++ {
+		if num := nextFree(t, flist); slices.Contains(nums, num) {
+			t.Fatalf("Failed to keep pending page %d from reader %d", num, reader)
+		}
+	}
+
+	// Pending pages are written as free, no reader outlives the pager.
+	restored := data.NewFreelist()
+	if err := restored.Deserialize(flist.Serialize()); err != nil {
+		t.Fatalf("Failed to deserialize freelist, with error %s", err)
+	}
+	for _, num := range nums {
+		if !restored.Contains(num) {
+			t.Fatalf("Failed to serialize pending page %d as released: %+v", num, restored)
+		}
+	}
+
+	if err := flist.ProcessRelease(2); err != nil {
+		t.Fatalf("Failed to process pending pages, with error %s", err)
+	}
+	if flist.Count() != 2 || !flist.Contains(nums[0]) || !flist.Contains(nums[1]) {
+		t.Fatalf("Failed to release pages of transaction 2: %+v", flist)
+	}
+	if flist.Contains(nums[2]) {
+		t.Fatalf("Failed to hold page %d of transaction 3: %+v", nums[2], flist)
+	}
+
+	if err := flist.ProcessRelease(math.MaxUint64); err != nil {
+		t.Fatalf("Failed to process pending pages, with error %s", err)
+	}
+	if len(flist.Pending) != 0 || !flist.Contains(nums[2]) {
+		t.Fatalf("Failed to release all pending pages: %+v", flist)
+	}
+
+	for range nums {
+		if num := nextFree(t, flist); !slices.Contains(nums, num) {
+			t.Fatalf("Failed to reuse released pages: got page %d, want one of %v", num, nums)
+		}
+	}
+
+	// A page past Max was never handed out, it stays pending.
+	flist.ReleasePending(4, flist.Max)
+	if err := flist.ProcessRelease(math.MaxUint64); !errors.Is(err, data.ErrPageNotAllocated) {
+		t.Fatalf(
+			"Failed to process page past max: expected error %s, actual %v",
+			data.ErrPageNotAllocated, err,
 		)
 	}
+	if len(flist.Pending[4]) != 1 {
+		t.Fatalf("Failed to keep page past max pending: %+v", flist)
+	}
+}
+
+func TestFreelist_Simulate(t *testing.T) {
+	flist := data.NewFreelist()
+	for i := 0; i < 10; i++ {
+		nextFree(t, flist)
+	}
+	for _, num := range []data.PageNum{data.BeginFreeBlocks + 2, data.BeginFreeBlocks + 5, data.BeginFreeBlocks + 7} {
+		flist.Release(num)
+	}
+
+	tests := []struct {
+		n, reused, grown int
+	}{
+		{0, 0, 0},
+		{-1, 0, 0},
+		{2, 2, 0},
+		{3, 3, 0},
+		{8, 3, 5},
+	}
+	for _, tt := range tests {
+		before := flist.Clone()
+
+		reused, grown := flist.Simulate(tt.n)
+		if reused != tt.reused || grown != tt.grown {
+			t.Fatalf(
+				"Failed to compare simulated split of %d pages: expected %d reused and %d grown, actual %d and %d",
+				tt.n, tt.reused, tt.grown, reused, grown,
+			)
+		}
+		if !flist.Equal(before) {
+			t.Fatalf("Failed to leave freelist untouched by simulate: expected %+v, actual %+v", before, flist)
+		}
+	}
+
+	max := flist.Max
+	for i := 0; i < 8; i++ {
+		nextFree(t, flist)
+	}
+	if flist.Max-max != 5 || flist.Count() != 0 {
+		t.Fatalf("Failed to compare simulated split with allocations: grew by %d, %d left", flist.Max-max, flist.Count())
+	}
+}
+
+func TestFreelist_Defragment(t *testing.T) {
+	flist := data.NewFreelist()
+	for i := 0; i < 20; i++ {
+		nextFree(t, flist)
+	}
+
+	r := rand.NewRand(42)
+	released := make([]data.PageNum, 0)
+	for num := data.BeginFreeBlocks; num < flist.Max; num += 2 {
+		released = append(released, num)
+	}
+	for i := len(released) - 1; i > 0; i-- {
+		j := r.Range(0, i+1)
+		released[i], released[j] = released[j], released[i]
+	}
+
+	for _, num := range released {
+		flist.Release(num)
+	}
+	// A damaged freelist holds duplicates and pages past Max.
+	flist.Released = append(flist.Released, released[0], released[3], flist.Max+5)
+
+	flist.Defragment()
+
+	if !slices.IsSortedFunc(flist.Released, func(a, b data.PageNum) int { return cmp.Compare(b, a) }) {
+		t.Fatalf("Failed to sort released pages: %v", flist.Released)
+	}
+	if len(slices.Compact(slices.Clone(flist.Released))) != len(flist.Released) {
+		t.Fatalf("Failed to drop duplicate released pages: %v", flist.Released)
+	}
+	if len(flist.Released) != len(released) || slices.Contains(flist.Released, flist.Max+5) {
+		t.Fatalf("Failed to compare released pages: expected %d in range, actual %v", len(released), flist.Released)
+	}
+
+	if num := nextFree(t, flist); num != data.BeginFreeBlocks {
+		t.Fatalf("Failed to hand out lowest page after defragment: expected %d, actual %d", data.BeginFreeBlocks, num)
+	}
+}
+
+func TestClone(t *testing.T) {
+	flist := data.NewFreelist()
+	for i := 0; i < 5; i++ {
+		nextFree(t, flist)
+	}
+	flist.Release(data.BeginFreeBlocks + 1)
+
+	flistClone := flist.Clone()
+	if !flist.Equal(flistClone) {
+		t.Fatalf("Failed to check for equals freelists: expected %+v, actual %+v", flist, flistClone)
+	}
+
+	flistClone.Released[0] = data.BeginFreeBlocks + 3
+	flistClone.Release(data.BeginFreeBlocks + 2)
+	flistClone.Max += 1
+
+	if flist.Max != data.BeginFreeBlocks+5 || !slices.Equal(flist.Released, []data.PageNum{data.BeginFreeBlocks + 1}) {
+		t.Fatalf("Failed to check original freelist is unaffected by its clone: %+v", flist)
+	}
+
+	meta := data.NewMetainfo()
+	meta.TxID = 7
+
+	metaClone := meta.Clone()
+	if !meta.Equal(metaClone) {
+		t.Fatalf("Failed to check for equals metainfos: expected %+v, actual %+v", meta, metaClone)
+	}
+
+	metaClone.TxID += 1
+	if meta.TxID != 7 {
+		t.Fatalf("Failed to check original metainfo is unaffected by its clone: %+v", meta)
+	}
+}
+
+func TestFreelist_LowestFirst(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPagerWithOptions(
+		filename, os.Getpagesize(),
+		data.WithAllocStrategy(data.LowestFirst),
+	)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	for pgr.Freelist().Max <= 50 {
+		nextFree(t, pgr.Freelist())
+	}
+
+	for _, num := range []data.PageNum{50, 10, 30} {
+		pgr.Freelist().Release(num)
+	}
+
+	for _, expected := range []data.PageNum{10, 30, 50} {
+		if actual := nextFree(t, pgr.Freelist()); actual != expected {
+			t.Fatalf(
+				"Failed to compare lowest free page: expected %d, actual %d",
+				expected, actual,
+			)
+		}
+	}
+
+	// Another pager releases the pages in its own order, which Reopen
+	// has to sort for the lowest first strategy again.
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	other, err := data.NewPagerWithOptions(filename, os.Getpagesize(), data.WithFileLock(false))
+	if err != nil {
+		t.Fatalf("Failed to open pager by path %s, with error %s", filename, err)
+	}
+	for _, num := range []data.PageNum{10, 50, 30} {
+		other.Freelist().Release(num)
+	}
+	if err := other.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	if err := pgr.Reopen(); err != nil {
+		t.Fatalf("Failed to reopen pager, with error %s", err)
+	}
+
+	for _, expected := range []data.PageNum{10, 30, 50} {
+		if actual := nextFree(t, pgr.Freelist()); actual != expected {
+			t.Fatalf(
+				"Failed to compare lowest free page after reopen: expected %d, actual %d",
+				expected, actual,
+			)
+		}
+	}
+}
+
+func TestFreelist_Serialization(t *testing.T) {
+	expectedFlist := data.NewFreelist()
+	for i := 0; i < 10; i++ {
+		nextFree(t, expectedFlist)
+	}
+	for i := 0; i < 10; i++ {
+		if rand.Bool() {
+			expectedFlist.Release(data.PageNum(i))
+		}
+	}
+	expectedFlistb := expectedFlist.Serialize()
+
+	actualFlist := new(data.Freelist)
+	if err := actualFlist.Deserialize(expectedFlistb); err != nil {
+		t.Fatalf(
+			"Failed to deserialize freelist %+v, with error %s",
+			expectedFlist, err,
+		)
+	}
+
+	if !expectedFlist.Equal(actualFlist) {
+		t.Fatalf(
+			"Failed to check for equals freelists: expected %d, actual %d",
+			expectedFlist, actualFlist,
+		)
+	}
+}
+
+func TestFreelist_WriteTo(t *testing.T) {
+	// More pages than fit in one chunk of WriteTo and ReadFrom.
+	flist := data.NewFreelist()
+	for i := 0; i < 1500; i++ {
+		nextFree(t, flist)
+	}
+	for num := data.BeginFreeBlocks; num < flist.Max; num += 2 {
+		flist.Release(num)
+	}
+	flist.ReleasePending(1, data.BeginFreeBlocks+1)
+
+	// The layout is built by hand, so that WriteTo is not only compared
+	// to Serialize, which wraps it.
+	free := append(slices.Clone(flist.Released), data.BeginFreeBlocks+1)
+	expected := make([]byte, 16, 16+8*len(free))
+	binary.LittleEndian.PutUint64(expected[4:12], uint64(flist.Max))
+	binary.LittleEndian.PutUint32(expected[12:16], uint32(len(free)))
+	for _, num := range free {
+		expected = binary.LittleEndian.AppendUint64(expected, uint64(num))
+	}
+	binary.LittleEndian.PutUint32(expected[:4], crc32.Checksum(expected[4:], crc32.MakeTable(crc32.Castagnoli)))
+
+	var buf bytes.Buffer
+	n, err := flist.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("Failed to write freelist, with error %s", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("Failed to compare written bytes: expected %d, actual %d", buf.Len(), n)
+	}
+
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("Failed to compare WriteTo output with the freelist layout")
+	}
+	if !bytes.Equal(buf.Bytes(), flist.Serialize()) {
+		t.Fatalf("Failed to compare WriteTo output with Serialize output")
+	}
+
+	t.Run("read from", func(t *testing.T) {
+		r := bytes.NewReader(append(bytes.Clone(expected), "trailer"...))
+
+		actual := new(data.Freelist)
+		n, err := actual.ReadFrom(iotest.OneByteReader(r))
+		if err != nil {
+			t.Fatalf("Failed to read freelist, with error %s", err)
+		}
+		if n != int64(len(expected)) || r.Len() != len("trailer") {
+			t.Fatalf(
+				"Failed to compare read bytes: expected %d leaving %d, actual %d leaving %d",
+				len(expected), len("trailer"), n, r.Len(),
+			)
+		}
+
+		if actual.Max != flist.Max || !slices.Equal(actual.Released, free) {
+			t.Fatalf("Failed to compare freelist read back: max %d, %d released", actual.Max, len(actual.Released))
+		}
+	})
+
+	t.Run("short stream", func(t *testing.T) {
+		actual := new(data.Freelist)
+		if _, err := actual.ReadFrom(bytes.NewReader(expected[:len(expected)-3])); !errors.Is(err, data.ErrWrongBytes) {
+			t.Fatalf(
+				"Failed to read truncated freelist: expected error %s, actual %v",
+				data.ErrWrongBytes, err,
+			)
+		}
+		if actual.Max != 0 || actual.Released != nil {
+			t.Fatalf("Failed to leave freelist unchanged after failed read: %+v", actual)
+		}
+	})
+
+	t.Run("failing writer", func(t *testing.T) {
+		errWrite := errors.New("write failed")
+		if _, err := flist.WriteTo(failingWriter{errWrite}); !errors.Is(err, errWrite) {
+			t.Fatalf("Failed to write freelist: expected error %s, actual %v", errWrite, err)
+		}
+	})
+}
+
+type failingWriter struct{ err error }
+
+func (w failingWriter) Write([]byte) (int, error) { return 0, w.err }
+
+func TestFreelist_DeserializeCorrupt(t *testing.T) {
+	flist := data.NewFreelist()
+	for i := 0; i < 10; i++ {
+		nextFree(t, flist)
+	}
+	flist.Release(data.BeginFreeBlocks + 4)
+
+	t.Run("flipped byte", func(t *testing.T) {
+		b := flist.Serialize()
+		b[len(b)-3] ^= 0xff
+
+		if err := new(data.Freelist).Deserialize(b); !errors.Is(err, data.ErrFreelistCorrupt) {
+			t.Fatalf(
+				"Failed to deserialize corrupt freelist: expected error %s, actual %v",
+				data.ErrFreelistCorrupt, err,
+			)
+		}
+	})
+
+	t.Run("released past max", func(t *testing.T) {
+		corrupt := &data.Freelist{
+			Max:      flist.Max,
+			Released: []data.PageNum{flist.Max + 1},
+		}
+
+		if err := new(data.Freelist).Deserialize(corrupt.Serialize()); !errors.Is(err, data.ErrFreelistCorrupt) {
+			t.Fatalf(
+				"Failed to deserialize freelist releasing page %d past max %d: expected error %s, actual %v",
+				flist.Max+1, flist.Max, data.ErrFreelistCorrupt, err,
+			)
+		}
+	})
+
+	t.Run("huge count", func(t *testing.T) {
+		b := flist.Serialize()
+		binary.LittleEndian.PutUint32(b[12:16], 1<<31)
+
+		if err := new(data.Freelist).Deserialize(b); !errors.Is(err, data.ErrWrongBytes) {
+			t.Fatalf(
+				"Failed to deserialize freelist with huge count: expected error %s, actual %v",
+				data.ErrWrongBytes, err,
+			)
+		}
+	})
+}
+
+func TestPager_FreelistChain(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := 4096
+	released := 100_000
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	nums := make([]data.PageNum, released)
+	for i := range nums {
+		nums[i] = nextFree(t, pgr.Freelist())
+	}
+	if err := pgr.Freelist().ReleaseAll(nums); err != nil {
+		t.Fatalf("Failed to release %d pages, with error %s", released, err)
+	}
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	if pgr.Meta().FreelistPages <= 1 {
+		t.Fatalf(
+			"Failed to check freelist chain: %d released pages stored in %d page",
+			released, pgr.Meta().FreelistPages,
+		)
+	}
+
+	expectedFlist := pgr.Freelist()
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	reopened, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to reopen pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer reopened.Close()
+
+	actualFlist := reopened.Freelist()
+	if expectedFlist.Max != actualFlist.Max || !slices.Equal(expectedFlist.Released, actualFlist.Released) {
+		t.Fatalf(
+			"Failed to compare freelist: expected max %d with %d released, actual max %d with %d released",
+			expectedFlist.Max, len(expectedFlist.Released),
+			actualFlist.Max, len(actualFlist.Released),
+		)
+	}
+
+	if !pgr.Meta().Equal(reopened.Meta()) {
+		t.Fatalf(
+			"Failed to compare metainfo: expected %+v, actual %+v",
+			pgr.Meta(), reopened.Meta(),
+		)
+	}
+}
+
+func TestPager_ShadowMeta(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPagerWithOptions(filename, psize, data.WithFlushOnClose(false))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	for i := 0; i < 3; i++ {
+		nextPage(t, pgr)
+
+		if _, err := pgr.Flush(); err != nil {
+			t.Fatalf("Failed to flush pager, with error %s", err)
+		}
+	}
+
+	// Four flushes including the one on creation leave the newest meta in
+	// the shadow slot and the previous one in the default slot.
+	olderTxID := pgr.Meta().TxID - 1
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	f, err := os.OpenFile(filename, os.O_RDWR, data.DefaultFilePerm)
+	if err != nil {
+		t.Fatalf("Failed to open file %s, with error %s", filename, err)
+	}
+
+	off := int64(data.ShadowMetaPage)*int64(psize) + int64(psize/2)
+	if _, err := f.WriteAt([]byte{0xff}, off); err != nil {
+		t.Fatalf("Failed to corrupt shadow meta page, with error %s", err)
+	}
+	_ = f.Close()
+
+	reopened, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to reopen pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer reopened.Close()
+
+	if reopened.Meta().TxID != olderTxID {
+		t.Fatalf(
+			"Failed to compare recovered transaction id: expected %d, actual %d",
+			olderTxID, reopened.Meta().TxID,
+		)
+	}
+}
+
+func TestPager_ShadowMetaGrownFreelist(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := data.MinPageSize
+
+	pgr, err := data.NewPagerWithOptions(filename, psize, data.WithFlushOnClose(false))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	pgr.ReleasePage(nextPage(t, pgr))
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+	older := *pgr.Meta()
+	olderReleased := pgr.Freelist().Count()
+
+	// Enough released pages to spread the freelist over three pages, so
+	// the chain of the older meta slot no longer matches its size.
+	var nums []data.PageNum
+	for i := 0; i < 150; i++ {
+		nums = append(nums, nextPage(t, pgr))
+	}
+	for _, num := range nums {
+		pgr.ReleasePage(num)
+	}
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+	if pgr.Meta().FreelistPages < 3 {
+		t.Fatalf("Failed to grow freelist: expected at least 3 pages, actual %d", pgr.Meta().FreelistPages)
+	}
+
+	newest := data.DefaultMetaPage
+	if meta, err := pgr.ReadMetaAt(data.ShadowMetaPage); err == nil && meta.TxID == pgr.Meta().TxID {
+		newest = data.ShadowMetaPage
+	}
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	f, err := os.OpenFile(filename, os.O_RDWR, data.DefaultFilePerm)
+	if err != nil {
+		t.Fatalf("Failed to open file %s, with error %s", filename, err)
+	}
+	if _, err := f.WriteAt([]byte{0xff}, int64(newest)*int64(psize)+int64(psize/2)); err != nil {
+		t.Fatalf("Failed to corrupt meta page %d, with error %s", newest, err)
+	}
+	_ = f.Close()
+
+	reopened, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to reopen pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer reopened.Close()
+
+	if reopened.Meta().TxID != older.TxID || reopened.Meta().FreelistPages != older.FreelistPages {
+		t.Fatalf("Failed to compare recovered meta: expected %+v, actual %+v", older, *reopened.Meta())
+	}
+	if reopened.Freelist().Count() != olderReleased {
+		t.Fatalf(
+			"Failed to compare recovered released pages: expected %d, actual %d",
+			olderReleased, reopened.Freelist().Count(),
+		)
+	}
+}
+
+func TestPager_PartialFinalPage(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	var last *data.Page
+	for i := 0; i < 3; i++ {
+		last = pgr.Alloc().WithNum(nextPage(t, pgr))
+		if err := last.WriteAt([]byte(fmt.Sprintf("data%d", i+1)), len(last.Data)-5); err != nil {
+			t.Fatalf("Failed to write page data, with error %s", err)
+		}
+
+		if err := pgr.Write(last); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", last, err)
+		}
+	}
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	// A crash in the middle of writing the last page leaves the file
+	// short of a whole number of pages.
+	if err := os.Truncate(filename, int64(last.Num)*int64(psize)+int64(psize/2)); err != nil {
+		t.Fatalf("Failed to truncate file %s, with error %s", filename, err)
+	}
+
+	reopened, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to reopen pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer reopened.Close()
+
+	if _, err := reopened.Read(last.Num - 1); err != nil {
+		t.Fatalf("Failed to read page %d before the partial page, with error %s", last.Num-1, err)
+	}
+
+	if _, err := reopened.Read(last.Num); !errors.Is(err, data.ErrChecksumMismatch) {
+		t.Fatalf(
+			"Failed to read partial page %d: expected error %s, actual %v",
+			last.Num, data.ErrChecksumMismatch, err,
+		)
+	}
+}
+
+func TestPager_FlushCleanFreelist(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+	pg.Write([]byte("data"))
+
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	// A dirty freelist is fully rewritten to the freelist page the latest
+	// metainfo does not reference, so a marker in its unused tail tells
+	// whether it was touched.
+	spare := data.DefaultFlistPage
+	if pgr.Meta().Freelist == spare {
+		spare = data.ShadowFlistPage
+	}
+	marker := []byte("mark")
+	markerOff := int64(spare)*int64(psize) + int64(psize-len(marker))
+
+	writeMarker := func() {
+		f, err := os.OpenFile(filename, os.O_RDWR, data.DefaultFilePerm)
+		if err != nil {
+			t.Fatalf("Failed to open file %s, with error %s", filename, err)
+		}
+		defer f.Close()
+
+		if _, err := f.WriteAt(marker, markerOff); err != nil {
+			t.Fatalf("Failed to write marker, with error %s", err)
+		}
+	}
+
+	readMarker := func() []byte {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("Failed to read file %s, with error %s", filename, err)
+		}
+		return b[markerOff : markerOff+int64(len(marker))]
+	}
+
+	writeMarker()
+	txID := pgr.Meta().TxID
+
+	pg.Write([]byte("updated"))
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	if !bytes.Equal(readMarker(), marker) {
+		t.Fatalf("Failed to check clean freelist: freelist page was rewritten")
+	}
+
+	if pgr.Meta().TxID != txID+1 {
+		t.Fatalf(
+			"Failed to compare meta transaction id: expected %d, actual %d",
+			txID+1, pgr.Meta().TxID,
+		)
+	}
+
+	nextFree(t, pgr.Freelist())
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	if bytes.Equal(readMarker(), marker) {
+		t.Fatalf("Failed to check dirty freelist: freelist page was not rewritten")
+	}
+}
+
+func TestPager_PageData(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+	pg.Write([]byte("data"))
+
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	pgData, err := pgr.PageData(pg.Num)
+	if err != nil {
+		t.Fatalf("Failed to copy page %d data, with error %s", pg.Num, err)
+	}
+	copy(pgData, "mutated")
+
+	actualPg, err := pgr.Read(pg.Num)
+	if err != nil {
+		t.Fatalf("Failed to read page %d, with error %s", pg.Num, err)
+	}
+
+	if actualPgData := string(bytes.TrimRight(actualPg.Data, "\x00")); actualPgData != "data" {
+		t.Fatalf(
+			"Failed to compare page data: expected %s, actual %s",
+			"data", actualPgData,
+		)
+	}
+}
+
+func TestPager_ReadInto(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	pages := []*data.Page{pgr.Alloc().WithNum(nextFree(t, pgr.Freelist())), pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))}
+	for i, pg := range pages {
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+	}
+
+	into := pgr.Alloc()
+	for i, pg := range pages {
+		if err := pgr.ReadInto(pg.Num, into); err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", pg.Num, err)
+		}
+
+		if into.Num != pg.Num || !bytes.Equal(into.Data, pg.Data) {
+			t.Fatalf("Failed to compare page %d read into reused page: %+v", pg.Num, into)
+		}
+		if i == 0 {
+			copy(into.Data, "mutated")
+		}
+	}
+
+	actualPg, err := pgr.Read(pages[0].Num)
+	if err != nil {
+		t.Fatalf("Failed to read page %d, with error %s", pages[0].Num, err)
+	}
+	if !bytes.Equal(actualPg.Data, pages[0].Data) {
+		t.Fatalf("Failed to check page %d is unaffected by its reused copy", pages[0].Num)
+	}
+
+	for _, size := range []int{0, len(into.Data) - 1, len(into.Data) + 1} {
+		short := &data.Page{Data: make([]byte, size)}
+		if err := pgr.ReadInto(pages[0].Num, short); !errors.Is(err, data.ErrWrongPageSize) {
+			t.Fatalf(
+				"Failed to read into page of %d bytes: expected error %s, actual %v",
+				size, data.ErrWrongPageSize, err,
+			)
+		}
+	}
+
+	if err := pgr.ReadInto(pgr.Freelist().Max, into); !errors.Is(err, data.ErrPageOutOfRange) {
+		t.Fatalf(
+			"Failed to read page %d: expected error %s, actual %v",
+			pgr.Freelist().Max, data.ErrPageOutOfRange, err,
+		)
+	}
+}
+
+func BenchmarkPager_ReadInto(b *testing.B) {
+	filename := filepath.Join(b.TempDir(), "bench_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		b.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	pages := make([]*data.Page, 100)
+	for i := range pages {
+		pages[i] = pgr.Alloc().WithNum(nextFree(b, pgr.Freelist()))
+		pages[i].Write([]byte(fmt.Sprintf("data%d", i+1)))
+	}
+	if err := pgr.WriteBatch(pages); err != nil {
+		b.Fatalf("Failed to write pages, with error %s", err)
+	}
+
+	b.Run("Read", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			num := pages[i%len(pages)].Num
+			if _, err := pgr.Read(num); err != nil {
+				b.Fatalf("Failed to read page %d, with error %s", num, err)
+			}
+		}
+	})
+
+	b.Run("ReadInto", func(b *testing.B) {
+		b.ReportAllocs()
+		pg := pgr.Alloc()
+		for i := 0; i < b.N; i++ {
+			num := pages[i%len(pages)].Num
+			if err := pgr.ReadInto(num, pg); err != nil {
+				b.Fatalf("Failed to read page %d, with error %s", num, err)
+			}
+		}
+	})
+}
+
+func TestPager_ReadOutOfRange(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	if _, err := pgr.Read(pg.Num); err != nil {
+		t.Fatalf("Failed to read page %d, with error %s", pg.Num, err)
+	}
+
+	max := pgr.Freelist().Max
+	for _, num := range []data.PageNum{max, max + 1, -1} {
+		if _, err := pgr.Read(num); !errors.Is(err, data.ErrPageOutOfRange) {
+			t.Fatalf(
+				"Failed to read page %d: expected error %s, actual %v",
+				num, data.ErrPageOutOfRange, err,
+			)
+		}
+	}
+}
+
+func TestPager_Append(t *testing.T) {
+	pgr, err := data.NewMemPager(512)
+	if err != nil {
+		t.Fatalf("Failed to create in-memory pager, with error %s", err)
+	}
+	defer pgr.Close()
+
+	seen := make(map[data.PageNum]struct{})
+	for i := 0; i < 3; i++ {
+		payload := []byte(fmt.Sprintf("data%d", i+1))
+
+		num, err := pgr.Append(payload)
+		if err != nil {
+			t.Fatalf("Failed to append page, with error %s", err)
+		}
+		if _, ok := seen[num]; ok || num < data.BeginFreeBlocks {
+			t.Fatalf("Failed to append to a fresh page: got page %d", num)
+		}
+		seen[num] = struct{}{}
+
+		pg, err := pgr.Read(num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", num, err)
+		}
+		if !bytes.Equal(bytes.TrimRight(pg.Data, "\x00"), payload) {
+			t.Fatalf("Failed to compare appended page data: expected %q, actual %q", payload, pg.Data)
+		}
+	}
+
+	full := bytes.Repeat([]byte{1}, pgr.MaxLogicalPayload())
+	if _, err := pgr.Append(full); err != nil {
+		t.Fatalf("Failed to append full page, with error %s", err)
+	}
+
+	max := pgr.Freelist().Max
+	if _, err := pgr.Append(append(full, 1)); !errors.Is(err, data.ErrDataTooLarge) {
+		t.Fatalf("Failed to append over-length data: expected error %s, actual %v", data.ErrDataTooLarge, err)
+	}
+	if pgr.Freelist().Max != max || pgr.Freelist().Count() != 0 {
+		t.Fatalf("Failed to leave freelist untouched by refused append: %+v", pgr.Freelist())
+	}
+}
+
+func TestPager_StrictWrites(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPagerWithOptions(filename, os.Getpagesize(), data.WithStrictWrites(true))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	shortPg := &data.Page{Num: pg.Num, Data: pg.Data[:len(pg.Data)/2]}
+	if err := pgr.Write(shortPg); !errors.Is(err, data.ErrWrongPageSize) {
+		t.Fatalf(
+			"Failed to write short page: expected error %s, actual %v",
+			data.ErrWrongPageSize, err,
+		)
+	}
+}
+
+func TestPager_ReadOnly(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+	pg.Write([]byte("data"))
+
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	ropgr, err := data.NewReadOnlyPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to open read-only pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer ropgr.Close()
+
+	actualPg, err := ropgr.Read(pg.Num)
+	if err != nil {
+		t.Fatalf("Failed to read page %d, with error %s", pg.Num, err)
+	}
+
+	if !bytes.Equal(pg.Data, actualPg.Data) {
+		t.Fatalf("Failed to compare read-only page %d data", pg.Num)
+	}
+
+	max := ropgr.Freelist().Max
+	if _, err := ropgr.Freelist().Next(); !errors.Is(err, data.ErrReadOnly) {
+		t.Fatalf("Failed to get next read-only page: expected error %s, actual %v", data.ErrReadOnly, err)
+	}
+	if _, err := ropgr.NextPage(); !errors.Is(err, data.ErrReadOnly) {
+		t.Fatalf("Failed to get next read-only page: expected error %s, actual %v", data.ErrReadOnly, err)
+	}
+	if err := ropgr.Freelist().Release(pg.Num); !errors.Is(err, data.ErrReadOnly) {
+		t.Fatalf("Failed to release read-only page %d: expected error %s, actual %v", pg.Num, data.ErrReadOnly, err)
+	}
+	if err := ropgr.ReleasePage(pg.Num); !errors.Is(err, data.ErrReadOnly) {
+		t.Fatalf("Failed to release read-only page %d: expected error %s, actual %v", pg.Num, data.ErrReadOnly, err)
+	}
+
+	if ropgr.Freelist().Max != max || len(ropgr.Freelist().Released) != 0 {
+		t.Fatalf("Failed to check read-only freelist: %+v", ropgr.Freelist())
+	}
+
+	if err := ropgr.Write(pg); !errors.Is(err, data.ErrReadOnly) {
+		t.Fatalf(
+			"Failed to write read-only page: expected error %s, actual %v",
+			data.ErrReadOnly, err,
+		)
+	}
+
+	if _, err := ropgr.Flush(); !errors.Is(err, data.ErrReadOnly) {
+		t.Fatalf(
+			"Failed to flush read-only pager: expected error %s, actual %v",
+			data.ErrReadOnly, err,
+		)
+	}
+
+	if err := ropgr.Compact(); !errors.Is(err, data.ErrReadOnly) {
+		t.Fatalf(
+			"Failed to compact read-only pager: expected error %s, actual %v",
+			data.ErrReadOnly, err,
+		)
+	}
+
+	if _, err := data.NewReadOnlyPager(filename+".missing", psize); err == nil {
+		t.Fatalf("Failed to open missing read-only pager: expected error, actual nil")
+	}
+}
+
+func TestPager_FilePerm(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+	perm := os.FileMode(0o600)
+
+	pgr, err := data.NewPagerWithOptions(
+		filename, psize,
+		data.WithFilePerm(perm), data.WithInitialPages(8),
+	)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("Failed to stat file %s, with error %s", filename, err)
+	}
+
+	if info.Mode().Perm() != perm {
+		t.Fatalf(
+			"Failed to compare file permission: expected %s, actual %s",
+			perm, info.Mode().Perm(),
+		)
+	}
+
+	if expected := int64(8 * psize); info.Size() != expected {
+		t.Fatalf(
+			"Failed to compare preallocated file size: expected %d, actual %d",
+			expected, info.Size(),
+		)
+	}
+}
+
+func TestPager_Sync(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	for i := 0; i < 3; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+	}
+
+	if err := pgr.Sync(); err != nil {
+		t.Fatalf("Failed to sync pager, with error %s", err)
+	}
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	expectedMax := pgr.Freelist().Max
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	pgr, err = data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to open pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	if actualMax := pgr.Freelist().Max; expectedMax != actualMax {
+		t.Fatalf(
+			"Failed to compare freelist max: expected %d, actual %d",
+			expectedMax, actualMax,
+		)
+	}
+}
+
+func TestPager_ReservedPages(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPagerWithOptions(filename, psize, data.WithReservedPages(4))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	if pgr.Freelist().Begin() != 4 || pgr.Meta().ReservedPages != 4 {
+		t.Fatalf("Failed to reserve 4 pages: begin %d, meta %+v", pgr.Freelist().Begin(), pgr.Meta())
+	}
+
+	num, err := pgr.Append([]byte("data"))
+	if err != nil {
+		t.Fatalf("Failed to append page, with error %s", err)
+	}
+	if num != 4 {
+		t.Fatalf("Failed to compare first allocated page: expected %d, actual %d", 4, num)
+	}
+	if err := pgr.ReleasePage(num); err != nil {
+		t.Fatalf("Failed to release page %d, with error %s", num, err)
+	}
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	// The reserved region is fixed when the file is created.
+	reopened, err := data.NewPagerWithOptions(filename, psize, data.WithReservedPages(8))
+	if err != nil {
+		t.Fatalf(
+			"Failed to reopen pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer reopened.Close()
+
+	if reopened.Freelist().Begin() != 4 || reopened.Meta().ReservedPages != 4 {
+		t.Fatalf("Failed to keep reserved pages of existing file: begin %d, meta %+v", reopened.Freelist().Begin(), reopened.Meta())
+	}
+	if num := nextPage(t, reopened); num != 4 {
+		t.Fatalf("Failed to compare reused page: expected %d, actual %d", 4, num)
+	}
+
+	if _, err := data.NewMemPager(512, data.WithReservedPages(2)); !errors.Is(err, data.ErrInvalidReservedPages) {
+		t.Fatalf(
+			"Failed to create pager with 2 reserved pages: expected error %s, actual %v",
+			data.ErrInvalidReservedPages, err,
+		)
+	}
+}
+
+func TestPager_InvalidReservedPages(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	if _, err := data.NewPagerWithOptions(filename, psize, data.WithReservedPages(2)); !errors.Is(err, data.ErrInvalidReservedPages) {
+		t.Fatalf(
+			"Failed to create pager with 2 reserved pages: expected error %s, actual %v",
+			data.ErrInvalidReservedPages, err,
+		)
+	}
+	if _, err := os.Stat(filename); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Failed to leave no file behind for invalid reserved pages, with error %v", err)
+	}
+
+	// An empty file left by the rejected create would be taken for an
+	// existing store.
+	pgr, err := data.NewPagerWithOptions(filename, psize, data.WithReservedPages(4))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	if pgr.Meta().ReservedPages != 4 {
+		t.Fatalf("Failed to compare reserved pages: expected %d, actual %d", 4, pgr.Meta().ReservedPages)
+	}
+}
+
+func TestPager_InvalidPageSize(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	for _, psize := range []int{0, -data.MinPageSize, 64, data.MinPageSize + 1} {
+		if _, err := data.NewPager(filename, psize); !errors.Is(err, data.ErrInvalidPageSize) {
+			t.Fatalf(
+				"Failed to create pager with page size %d: expected error %s, actual %v",
+				psize, data.ErrInvalidPageSize, err,
+			)
+		}
+
+		if _, err := data.NewMemPager(psize); !errors.Is(err, data.ErrInvalidPageSize) {
+			t.Fatalf(
+				"Failed to create in-memory pager with page size %d: expected error %s, actual %v",
+				psize, data.ErrInvalidPageSize, err,
+			)
+		}
+	}
+
+	// The payload check needs the cipher, so a bad key is rejected before
+	// the file is created too.
+	if _, err := data.NewPagerWithOptions(filename, data.MinPageSize, data.WithEncryption([]byte("short"))); err == nil {
+		t.Fatalf("Failed to reject pager with a 5-byte encryption key")
+	}
+
+	if _, err := os.Stat(filename); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Failed to leave no file behind for invalid page sizes, with error %v", err)
+	}
+
+	// The smallest page holds the meta page even with the encryption
+	// overhead taken off its payload.
+	pgr, err := data.NewPagerWithOptions(filename, data.MinPageSize, data.WithEncryption(bytes.Repeat([]byte{0x42}, 32)))
+	if err != nil {
+		t.Fatalf("Failed to create encrypted pager with page size %d, with error %s", data.MinPageSize, err)
+	}
+	defer pgr.Close()
+}
+
+func TestPager_PageSizeMismatch(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPager(filename, 4096)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	if _, err := data.NewPager(filename, 8192); !errors.Is(err, data.ErrPageSizeMismatch) {
+		t.Fatalf(
+			"Failed to reopen pager with another page size: expected error %s, actual %v",
+			data.ErrPageSizeMismatch, err,
+		)
+	}
+
+	pgr, err = data.NewPager(filename, 4096)
+	if err != nil {
+		t.Fatalf(
+			"Failed to reopen pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	if pgr.Meta().PageSize != 4096 {
+		t.Fatalf(
+			"Failed to compare stored page size: expected %d, actual %d",
+			4096, pgr.Meta().PageSize,
+		)
+	}
+}
+
+func TestByteOrder(t *testing.T) {
+	expectedMeta := data.NewMetainfo()
+	expectedMeta.TxID = 42
+	expectedMeta.PageSize = os.Getpagesize()
+	expectedMeta.Order = data.BigEndian
+
+	actualMeta := new(data.Metainfo)
+	if err := actualMeta.Deserialize(expectedMeta.Serialize()); err != nil {
+		t.Fatalf("Failed to deserialize big-endian metainfo, with error %s", err)
+	}
+
+	if !expectedMeta.Equal(actualMeta) {
+		t.Fatalf(
+			"Failed to check for equals metainfos: expected %+v, actual %+v",
+			expectedMeta, actualMeta,
+		)
+	}
+
+	expectedFlist := data.NewFreelist()
+	expectedFlist.Order = data.BigEndian
+	for i := 0; i < 10; i++ {
+		nextFree(t, expectedFlist)
+	}
+	expectedFlist.Release(data.BeginFreeBlocks + 3)
+
+	actualFlist := &data.Freelist{Order: data.BigEndian}
+	if err := actualFlist.Deserialize(expectedFlist.Serialize()); err != nil {
+		t.Fatalf("Failed to deserialize big-endian freelist, with error %s", err)
+	}
+
+	if !expectedFlist.Equal(actualFlist) {
+		t.Fatalf(
+			"Failed to check for equals freelists: expected %+v, actual %+v",
+			expectedFlist, actualFlist,
+		)
+	}
+
+	t.Run("reopen", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "test_data")
+		psize := os.Getpagesize()
+
+		pgr, err := data.NewPagerWithOptions(filename, psize, data.WithByteOrder(data.BigEndian))
+		if err != nil {
+			t.Fatalf(
+				"Failed to create pager by path %s, with error %s",
+				filename, err,
+			)
+		}
+		for i := 0; i < 3; i++ {
+			nextPage(t, pgr)
+		}
+		pgr.ReleasePage(data.BeginFreeBlocks + 1)
+		max := pgr.Freelist().Max
+
+		if err := pgr.Close(); err != nil {
+			t.Fatalf("Failed to close pager, with error %s", err)
+		}
+
+		reopened, err := data.NewPager(filename, psize)
+		if err != nil {
+			t.Fatalf(
+				"Failed to reopen pager by path %s, with error %s",
+				filename, err,
+			)
+		}
+		defer reopened.Close()
+
+		if reopened.Meta().Order != data.BigEndian || reopened.Freelist().Max != max ||
+			!reopened.Freelist().Contains(data.BeginFreeBlocks+1) {
+			t.Fatalf(
+				"Failed to recover big-endian store: meta %+v, freelist %+v",
+				reopened.Meta(), reopened.Freelist(),
+			)
+		}
+	})
+}
+
+func TestPage_Type(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	written := make(map[data.PageNum]data.PageType)
+	for _, typ := range []data.PageType{
+		data.PageTypeData, data.PageTypeMeta, data.PageTypeFreelist, data.PageTypeOverflow,
+	} {
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr)).WithType(typ)
+		pg.Write([]byte(typ.String()))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write %s page %d, with error %s", typ, pg.Num, err)
+		}
+		written[pg.Num] = typ
+	}
+
+	head, err := pgr.WriteOverflow([]byte("overflow"))
+	if err != nil {
+		t.Fatalf("Failed to write overflow, with error %s", err)
+	}
+	written[head] = data.PageTypeOverflow
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+	written[data.DefaultMetaPage] = data.PageTypeMeta
+	written[data.DefaultFlistPage] = data.PageTypeFreelist
+	written[data.ShadowMetaPage] = data.PageTypeMeta
+	written[data.ShadowFlistPage] = data.PageTypeFreelist
+
+	for num, expected := range written {
+		pg, err := pgr.Read(num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", num, err)
+		}
+
+		if pg.Type() != expected {
+			t.Fatalf(
+				"Failed to compare type of page %d: expected %s, actual %s",
+				num, expected, pg.Type(),
+			)
+		}
+	}
+}
+
+func TestMetainfo_DeserializeForeign(t *testing.T) {
+	t.Run("random bytes", func(t *testing.T) {
+		b := make([]byte, os.Getpagesize())
+		for i := range b {
+			b[i] = byte(rand.Range(0, 256))
+		}
+
+		if err := new(data.Metainfo).Deserialize(b); !errors.Is(err, data.ErrBadMagic) {
+			t.Fatalf(
+				"Failed to deserialize random bytes: expected error %s, actual %v",
+				data.ErrBadMagic, err,
+			)
+		}
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		b := data.NewMetainfo().Serialize()
+		binary.LittleEndian.PutUint16(b[8:10], data.MetaVersion+1)
+
+		if err := new(data.Metainfo).Deserialize(b); !errors.Is(err, data.ErrUnsupportedVersion) {
+			t.Fatalf(
+				"Failed to deserialize future version: expected error %s, actual %v",
+				data.ErrUnsupportedVersion, err,
+			)
+		}
+	})
+
+	t.Run("previous version", func(t *testing.T) {
+		// Version 11 lacks the checksum placement, version 10 the user
+		// fields as well and version 9 the reserved page count too. The
+		// placement and the user fields of a new metainfo are all zero, so
+		// cutting one byte off leaves a valid empty user area behind.
+		for version, cut := range map[uint16]int{
+			data.MetaVersion - 1: 1,
+			data.MetaVersion - 2: 1 + 2,
+			data.MetaVersion - 3: 1 + 2 + 4,
+		} {
+			b := data.NewMetainfo().Serialize()
+			binary.LittleEndian.PutUint16(b[8:10], version)
+
+			meta := new(data.Metainfo)
+			if err := meta.Deserialize(b[:len(b)-cut]); err != nil {
+				t.Fatalf("Failed to deserialize version %d, with error %s", version, err)
+			}
+			// Version 9 predates the stored reserved region, which ended at
+			// the shadow meta page back then.
+			expected := int(data.BeginFreeBlocks)
+			if version == data.MetaVersion-3 {
+				expected = int(data.ShadowMetaPage + 1)
+			}
+			if meta.ReservedPages != expected {
+				t.Fatalf(
+					"Failed to compare reserved pages of version %d: expected %d, actual %d",
+					version, expected, meta.ReservedPages,
+				)
+			}
+		}
+	})
+
+	t.Run("foreign file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "test_data")
+		if err := os.WriteFile(filename, bytes.Repeat([]byte("foreign"), 4096), data.DefaultFilePerm); err != nil {
+			t.Fatalf("Failed to write file %s, with error %s", filename, err)
+		}
+
+		if _, err := data.NewPager(filename, 4096); !errors.Is(err, data.ErrBadMagic) {
+			t.Fatalf(
+				"Failed to open foreign file: expected error %s, actual %v",
+				data.ErrBadMagic, err,
+			)
+		}
+	})
+}
+
+func TestPager_Concurrent(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPagerWithOptions(filename, os.Getpagesize(), data.WithSync(false))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	first := nextPage(t, pgr)
+	pg := pgr.Alloc().WithNum(first)
+	pg.Write([]byte("data"))
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	const (
+		readers = 8
+		writes  = 200
+	)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, readers+1)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < writes; i++ {
+			pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+			pg.Write([]byte(fmt.Sprintf("data%d", i)))
+
+			if err := pgr.Write(pg); err != nil {
+				errs <- err
+				return
+			}
+
+			if i%10 == 0 {
+				pgr.ReleasePage(pg.Num)
+				if _, err := pgr.Flush(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}
+	}()
+
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < writes; j++ {
+				pg, err := pgr.Read(first)
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				if !bytes.HasPrefix(pg.Data, []byte("data")) {
+					errs <- fmt.Errorf("page %d: unexpected data %q", first, pg.Data[:4])
+					return
+				}
+
+				if _, err := pgr.Stats(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("Failed to use pager concurrently, with error %s", err)
+	}
+}
+
+func TestPager_Errors(t *testing.T) {
+	psize := os.Getpagesize()
+
+	newPager := func(t *testing.T) (*data.Pager, string) {
+		t.Helper()
+
+		filename := filepath.Join(t.TempDir(), "test_data")
+
+		pgr, err := data.NewPager(filename, psize)
+		if err != nil {
+			t.Fatalf(
+				"Failed to create pager by path %s, with error %s",
+				filename, err,
+			)
+		}
+
+		return pgr, filename
+	}
+
+	corrupt := func(t *testing.T, filename string, num data.PageNum) {
+		t.Helper()
+
+		f, err := os.OpenFile(filename, os.O_RDWR, data.DefaultFilePerm)
+		if err != nil {
+			t.Fatalf("Failed to open file %s, with error %s", filename, err)
+		}
+		defer f.Close()
+
+		if _, err := f.WriteAt([]byte{0xff}, int64(num)*int64(psize)+int64(psize)/2); err != nil {
+			t.Fatalf("Failed to corrupt page %d, with error %s", num, err)
+		}
+	}
+
+	t.Run("read past max", func(t *testing.T) {
+		pgr, _ := newPager(t)
+		defer pgr.Close()
+
+		if _, err := pgr.Read(pgr.Freelist().Max); !errors.Is(err, data.ErrPageOutOfRange) {
+			t.Fatalf("Failed to read past max: expected error %s, actual %v", data.ErrPageOutOfRange, err)
+		}
+	})
+
+	t.Run("read past end of file", func(t *testing.T) {
+		pgr, _ := newPager(t)
+		defer pgr.Close()
+
+		if _, err := pgr.Read(nextPage(t, pgr)); !errors.Is(err, data.ErrPageOutOfRange) {
+			t.Fatalf("Failed to read past end of file: expected error %s, actual %v", data.ErrPageOutOfRange, err)
+		}
+	})
+
+	t.Run("read corrupt page", func(t *testing.T) {
+		pgr, filename := newPager(t)
+		defer pgr.Close()
+
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		corrupt(t, filename, pg.Num)
+
+		if _, err := pgr.Read(pg.Num); !errors.Is(err, data.ErrChecksumMismatch) {
+			t.Fatalf("Failed to read corrupt page: expected error %s, actual %v", data.ErrChecksumMismatch, err)
+		}
+	})
+
+	t.Run("write and flush read-only", func(t *testing.T) {
+		pgr, filename := newPager(t)
+		if err := pgr.Close(); err != nil {
+			t.Fatalf("Failed to close pager, with error %s", err)
+		}
+
+		pgr, err := data.NewReadOnlyPager(filename, psize)
+		if err != nil {
+			t.Fatalf("Failed to open read-only pager by path %s, with error %s", filename, err)
+		}
+		defer pgr.Close()
+
+		if err := pgr.Write(pgr.Alloc().WithNum(data.BeginFreeBlocks)); !errors.Is(err, data.ErrReadOnly) {
+			t.Fatalf("Failed to write read-only pager: expected error %s, actual %v", data.ErrReadOnly, err)
+		}
+
+		if _, err := pgr.Flush(); !errors.Is(err, data.ErrReadOnly) {
+			t.Fatalf("Failed to flush read-only pager: expected error %s, actual %v", data.ErrReadOnly, err)
+		}
+	})
+
+	t.Run("recover corrupt freelist", func(t *testing.T) {
+		pgr, filename := newPager(t)
+		if err := pgr.Close(); err != nil {
+			t.Fatalf("Failed to close pager, with error %s", err)
+		}
+		corrupt(t, filename, pgr.Meta().Freelist)
+
+		if _, err := data.NewPager(filename, psize); !errors.Is(err, data.ErrChecksumMismatch) {
+			t.Fatalf("Failed to recover corrupt freelist: expected error %s, actual %v", data.ErrChecksumMismatch, err)
+		}
+	})
+}
+
+func TestPager_UseAfterClose(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	if _, err := pgr.Read(pg.Num); !errors.Is(err, data.ErrClosed) {
+		t.Fatalf("Failed to read closed pager: expected error %s, actual %v", data.ErrClosed, err)
+	}
+
+	if err := pgr.Write(pg); !errors.Is(err, data.ErrClosed) {
+		t.Fatalf("Failed to write closed pager: expected error %s, actual %v", data.ErrClosed, err)
+	}
+
+	if _, err := pgr.Flush(); !errors.Is(err, data.ErrClosed) {
+		t.Fatalf("Failed to flush closed pager: expected error %s, actual %v", data.ErrClosed, err)
+	}
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close closed pager: expected no error, actual %s", err)
+	}
+}
+
+func TestPager_CloseFlushes(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	for i := 0; i < 5; i++ {
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+	}
+	max := pgr.Freelist().Max
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	reopened, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to reopen pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer reopened.Close()
+
+	if reopened.Freelist().Max != max {
+		t.Fatalf(
+			"Failed to compare freelist max: expected %d, actual %d",
+			max, reopened.Freelist().Max,
+		)
+	}
+}
+
+// nextFree hands out the next page of flist, failing tb when the
+// freelist refuses.
+func nextFree(tb testing.TB, flist *data.Freelist) data.PageNum {
+	tb.Helper()
+
+	num, err := flist.Next()
+	if err != nil {
+		tb.Fatalf("Failed to get next free page, with error %s", err)
+	}
+	return num
+}
+
+// nextPage is nextFree through Pager.NextPage.
+func nextPage(tb testing.TB, pgr *data.Pager) data.PageNum {
+	tb.Helper()
+
+	num, err := pgr.NextPage()
+	if err != nil {
+		tb.Fatalf("Failed to get next page, with error %s", err)
+	}
+	return num
 }
@@ -0,0 +1,32 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// flushDirty writes the pages buffered by WithBufferedWrites in page
+// order, coalescing adjacent pages into one write.
+func (pgr *Pager) flushDirty(ctx context.Context) error {
+	if len(pgr.dirty) == 0 {
+		return nil
+	}
+
+	nums := slices.SortedFunc(maps.Keys(pgr.dirty), cmp.Compare[PageNum])
+
+	pages := make([]*Page, len(nums))
+	sealed := make([][]byte, len(nums))
+	for i, num := range nums {
+		pages[i] = &Page{Num: num}
+		sealed[i] = pgr.dirty[num]
+	}
+
+	if err := pgr.writeRuns(ctx, pages, sealed); err != nil {
+		return fmt.Errorf("flush dirty pages: %w", err)
+	}
+
+	return nil
+}
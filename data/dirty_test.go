@@ -0,0 +1,75 @@
+package data_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_BufferedWrites(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPagerWithOptions(filename, os.Getpagesize(), data.WithBufferedWrites(true))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	var pages []*data.Page
+	for i := 0; i < 10; i++ {
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		pg.Write([]byte(fmt.Sprintf("data%02d", i)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		pages = append(pages, pg)
+	}
+
+	onDisk := func(t *testing.T) int {
+		t.Helper()
+
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("Failed to read file %s, with error %s", filename, err)
+		}
+
+		n := 0
+		for i := range pages {
+			if bytes.Contains(b, []byte(fmt.Sprintf("data%02d", i))) {
+				n++
+			}
+		}
+		return n
+	}
+
+	if n := onDisk(t); n != 0 {
+		t.Fatalf("Failed to check pages are buffered: %d of %d on disk before flush", n, len(pages))
+	}
+
+	for _, expectedPg := range pages {
+		actualPg, err := pgr.Read(expectedPg.Num)
+		if err != nil {
+			t.Fatalf("Failed to read buffered page %d, with error %s", expectedPg.Num, err)
+		}
+
+		if !bytes.Equal(expectedPg.Data, actualPg.Data) {
+			t.Fatalf("Failed to compare buffered page %d data", expectedPg.Num)
+		}
+	}
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	if n := onDisk(t); n != len(pages) {
+		t.Fatalf("Failed to check pages are flushed: %d of %d on disk after flush", n, len(pages))
+	}
+}
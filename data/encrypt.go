@@ -0,0 +1,93 @@
+package data
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+)
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: %w", err)
+	}
+
+	return aead, nil
+}
+
+// sealOverhead is the room encryption takes in every page: the nonce and
+// the authentication tag.
+func (pgr *Pager) sealOverhead() int {
+	if pgr.aead == nil {
+		return 0
+	}
+	return pgr.aead.NonceSize() + pgr.aead.Overhead()
+}
+
+// pageAAD binds a ciphertext to its page number and type, so pages cannot
+// be swapped or retagged on disk without failing authentication.
+func pageAAD(num PageNum, typ PageType) []byte {
+	return append(binary.LittleEndian.AppendUint64(nil, uint64(num)), byte(typ))
+}
+
+// encryptPage encrypts the payload at the start of b in place, leaving
+// b holding a fresh nonce followed by the ciphertext and its tag.
+func (pgr *Pager) encryptPage(num PageNum, typ PageType, b []byte) error {
+	ns := pgr.aead.NonceSize()
+	plain := make([]byte, len(b)-pgr.sealOverhead())
+	copy(plain, b)
+
+	if _, err := rand.Read(b[:ns]); err != nil {
+		return fmt.Errorf("encrypt page %d: %w", num, err)
+	}
+	pgr.aead.Seal(b[ns:ns], b[:ns], plain, pageAAD(num, typ))
+
+	return nil
+}
+
+// decryptPage authenticates and decrypts the bytes encryptPage left in b.
+func (pgr *Pager) decryptPage(num PageNum, typ PageType, b []byte) ([]byte, error) {
+	ns := pgr.aead.NonceSize()
+
+	plain, err := pgr.aead.Open(nil, b[:ns], b[ns:], pageAAD(num, typ))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt page %d: %w", num, ErrDecryptFailed)
+	}
+
+	return plain, nil
+}
+
+// encryptBlob encrypts b, which is stored outside of a page under the
+// given number, such as the freelist of a write-ahead log commit.
+func (pgr *Pager) encryptBlob(num PageNum, b []byte) ([]byte, error) {
+	if pgr.aead == nil {
+		return b, nil
+	}
+
+	out := make([]byte, len(b)+pgr.sealOverhead())
+	copy(out, b)
+	if err := pgr.encryptPage(num, PageTypeData, out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (pgr *Pager) decryptBlob(num PageNum, b []byte) ([]byte, error) {
+	if pgr.aead == nil {
+		return b, nil
+	}
+
+	if len(b) < pgr.sealOverhead() {
+		return nil, fmt.Errorf("decrypt page %d: %w", num, ErrDecryptFailed)
+	}
+
+	return pgr.decryptPage(num, PageTypeData, b)
+}
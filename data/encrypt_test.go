@@ -0,0 +1,103 @@
+package data_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_Encryption(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	pgr, err := data.NewPagerWithOptions(filename, psize, data.WithEncryption(key))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+	copy(pg.Data, bytes.Repeat([]byte("secret"), 64))
+
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file %s, with error %s", filename, err)
+	}
+
+	if bytes.Contains(b, []byte("secret")) || bytes.Contains(b, data.MetaMagic[:]) {
+		t.Fatalf("Failed to check file %s holds no plaintext", filename)
+	}
+
+	t.Run("round-trip", func(t *testing.T) {
+		pgr, err := data.NewPagerWithOptions(filename, psize, data.WithEncryption(key))
+		if err != nil {
+			t.Fatalf("Failed to open pager by path %s, with error %s", filename, err)
+		}
+		defer pgr.Close()
+
+		actualPg, err := pgr.Read(pg.Num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", pg.Num, err)
+		}
+
+		if !bytes.Equal(pg.Data, actualPg.Data) {
+			t.Fatalf("Failed to compare page %d data after encryption round-trip", pg.Num)
+		}
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		wrong := bytes.Repeat([]byte{0x24}, 32)
+
+		if _, err := data.NewPagerWithOptions(filename, psize, data.WithEncryption(wrong)); !errors.Is(err, data.ErrDecryptFailed) {
+			t.Fatalf(
+				"Failed to open pager with the wrong key: expected error %s, actual %v",
+				data.ErrDecryptFailed, err,
+			)
+		}
+	})
+
+	t.Run("tampered ciphertext", func(t *testing.T) {
+		tampered := bytes.Clone(b)
+
+		stored := tampered[int(pg.Num)*psize : int(pg.Num+1)*psize]
+		stored[psize/2] ^= 0xff
+		binary.LittleEndian.PutUint32(stored[:4], data.CRC32C(stored[4:]))
+
+		if err := os.WriteFile(filename, tampered, data.DefaultFilePerm); err != nil {
+			t.Fatalf("Failed to write file %s, with error %s", filename, err)
+		}
+
+		pgr, err := data.NewPagerWithOptions(filename, psize, data.WithEncryption(key))
+		if err != nil {
+			t.Fatalf("Failed to open pager by path %s, with error %s", filename, err)
+		}
+		defer pgr.Close()
+
+		if _, err := pgr.Read(pg.Num); !errors.Is(err, data.ErrDecryptFailed) {
+			t.Fatalf(
+				"Failed to read tampered page %d: expected error %s, actual %v",
+				pg.Num, data.ErrDecryptFailed, err,
+			)
+		}
+	})
+}
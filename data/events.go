@@ -0,0 +1,63 @@
+package data
+
+import (
+	"time"
+)
+
+const DefaultAllocEventsBuffer = 256
+
+type AllocOp uint8
+
+const (
+	OpAlloc AllocOp = iota + 1
+	OpRelease
+)
+
+func (op AllocOp) String() string {
+	switch op {
+	case OpAlloc:
+		return "alloc"
+	case OpRelease:
+		return "release"
+	default:
+		return "unknown"
+	}
+}
+
+type AllocEvent struct {
+	Op  AllocOp
+	Num PageNum
+	At  time.Time
+}
+
+// AllocEvents returns the stream of freelist allocations and releases.
+// Sends never block: when the consumer falls behind, events are dropped
+// and counted by DroppedAllocEvents. The channel is closed by Close.
+func (pgr *Pager) AllocEvents() <-chan AllocEvent {
+	return pgr.events
+}
+
+func (pgr *Pager) DroppedAllocEvents() uint64 {
+	return pgr.dropped.Load()
+}
+
+func (pgr *Pager) emitAllocEvent(op AllocOp, num PageNum) {
+	select {
+	case pgr.events <- AllocEvent{Op: op, Num: num, At: time.Now()}:
+	default:
+		pgr.dropped.Add(1)
+	}
+}
+
+// allocObserver is notified of the allocations and releases of a freelist.
+type allocObserver interface {
+	onAllocEvent(op AllocOp, num PageNum)
+}
+
+// closeAllocEvents detaches the freelist and closes the event stream. It
+// is called with pgr.mu held, so no release in flight sends on the closed
+// channel.
+func (pgr *Pager) closeAllocEvents() {
+	pgr.flist.onEvent = nil
+	close(pgr.events)
+}
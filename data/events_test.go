@@ -0,0 +1,79 @@
+package data_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_AllocEvents(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	first := nextFree(t, pgr.Freelist())
+	second := nextFree(t, pgr.Freelist())
+	pgr.Freelist().Release(second)
+
+	expectedEvents := []data.AllocEvent{
+		{Op: data.OpAlloc, Num: first},
+		{Op: data.OpAlloc, Num: second},
+		{Op: data.OpRelease, Num: second},
+	}
+
+	events := pgr.AllocEvents()
+	for _, expectedEvent := range expectedEvents {
+		actualEvent := <-events
+		if expectedEvent.Op != actualEvent.Op || expectedEvent.Num != actualEvent.Num {
+			t.Fatalf(
+				"Failed to compare alloc events: expected %s(%d), actual %s(%d)",
+				expectedEvent.Op, expectedEvent.Num,
+				actualEvent.Op, actualEvent.Num,
+			)
+		}
+		if actualEvent.At.IsZero() {
+			t.Fatalf("Failed to check alloc event %+v: timestamp not set", actualEvent)
+		}
+	}
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	if _, ok := <-events; ok {
+		t.Fatalf("Failed to check alloc events: channel not closed after close")
+	}
+}
+
+func TestPager_AllocEventsDropped(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	extra := 10
+	for i := 0; i < data.DefaultAllocEventsBuffer+extra; i++ {
+		nextFree(t, pgr.Freelist())
+	}
+
+	if dropped := pgr.DroppedAllocEvents(); dropped != uint64(extra) {
+		t.Fatalf(
+			"Failed to compare dropped alloc events: expected %d, actual %d",
+			extra, dropped,
+		)
+	}
+}
@@ -0,0 +1,101 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+type flushStep string
+
+const (
+	flushStepSyncData      flushStep = "sync data"
+	flushStepWriteFreelist flushStep = "write freelist"
+	flushStepWriteMeta     flushStep = "write meta"
+	flushStepSyncMeta      flushStep = "sync meta"
+	flushStepSyncDir       flushStep = "sync dir"
+)
+
+// flushStepHook observes every completed flush step. It is only set by
+// tests pinning down the durability order of Flush.
+var flushStepHook func(step flushStep)
+
+func traceFlushStep(step flushStep) {
+	if flushStepHook != nil {
+		flushStepHook(step)
+	}
+}
+
+var errVectoredUnsupported = errors.New("vectored writes unsupported")
+
+// vectoredWrites enables the single-syscall write of adjacent meta and
+// freelist pages. Benchmarks turn it off to measure the sequential path.
+var vectoredWrites = true
+
+// writeMetaPages writes the freelist page, when it is dirty, and the meta
+// page. When the two are adjacent they go out in one vectored write where
+// the platform supports it, otherwise the freelist is written first. The
+// vectored write bypasses the backend, so it is only used for a plain file:
+// the mmap backend has to see every write to track the file size.
+func (pgr *Pager) writeMetaPages(metapg, flistpg *Page) error {
+	metab, err := pgr.sealPage(metapg.Num, PageTypeMeta, metapg.Data)
+	if err != nil {
+		return fmt.Errorf("flush metainfo: %w", err)
+	}
+
+	var flistb []byte
+	if flistpg != nil {
+		if flistb, err = pgr.sealPage(flistpg.Num, PageTypeFreelist, flistpg.Data); err != nil {
+			return fmt.Errorf("flush freelist: %w", err)
+		}
+	}
+
+	f, isFile := pgr.store.(*os.File)
+	if vectoredWrites && isFile && flistpg != nil {
+		var (
+			bufs [][]byte
+			off  int64
+		)
+
+		switch flistpg.Num {
+		case metapg.Num + 1:
+			bufs, off = [][]byte{metab, flistb}, int64(metapg.Num)*int64(pgr.psize)
+		case metapg.Num - 1:
+			bufs, off = [][]byte{flistb, metab}, int64(flistpg.Num)*int64(pgr.psize)
+		}
+
+		if bufs != nil {
+			err := pwritev(f, bufs, off)
+			if err == nil {
+				traceFlushStep(flushStepWriteFreelist)
+				traceFlushStep(flushStepWriteMeta)
+
+				pgr.notifyWrite(metapg.Num)
+				pgr.notifyWrite(flistpg.Num)
+
+				return nil
+			}
+
+			if errors.Is(err, ErrShortWrite) {
+				_ = pgr.logShortWrite(PageNum(off/int64(pgr.psize)), err)
+			}
+			if !errors.Is(err, errVectoredUnsupported) {
+				return fmt.Errorf("flush metainfo and freelist: %w", err)
+			}
+		}
+	}
+
+	if flistpg != nil {
+		if err := pgr.writeSealed(flistpg.Num, flistb); err != nil {
+			return fmt.Errorf("flush freelist: %w", err)
+		}
+		traceFlushStep(flushStepWriteFreelist)
+	}
+
+	if err := pgr.writeSealed(metapg.Num, metab); err != nil {
+		return fmt.Errorf("flush metainfo: %w", err)
+	}
+	traceFlushStep(flushStepWriteMeta)
+
+	return nil
+}
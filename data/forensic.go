@@ -0,0 +1,60 @@
+package data
+
+import (
+	"fmt"
+)
+
+// ReadMetaAt reads and decodes the metainfo stored in page num, such as
+// DefaultMetaPage or ShadowMetaPage, without going through recovery and
+// without changing the pager. It is meant for tools inspecting a damaged
+// store and for tests of the shadow meta scheme.
+func (pgr *Pager) ReadMetaAt(num PageNum) (*Metainfo, error) {
+	pgr.mu.RLock()
+	defer pgr.mu.RUnlock()
+
+	if pgr.closed {
+		return nil, fmt.Errorf("pager/readMetaAt(num=%d): %w", num, ErrClosed)
+	}
+
+	if num < 0 {
+		return nil, fmt.Errorf("pager/readMetaAt(num=%d): %w", num, ErrPageOutOfRange)
+	}
+
+	meta, err := pgr.readMetaSlot(num)
+	if err != nil {
+		return nil, fmt.Errorf("pager/readMetaAt(num=%d): %w", num, err)
+	}
+
+	return meta, nil
+}
+
+// ReadFreelistAt reads and decodes the freelist whose chain starts at page
+// num, in the byte order and with the reserved region of the metainfo of
+// the pager. The freelist returned is detached from the pager: allocating
+// from it or releasing to it changes nothing in the store.
+func (pgr *Pager) ReadFreelistAt(num PageNum) (*Freelist, error) {
+	pgr.mu.RLock()
+	defer pgr.mu.RUnlock()
+
+	if pgr.closed {
+		return nil, fmt.Errorf("pager/readFreelistAt(num=%d): %w", num, ErrClosed)
+	}
+
+	if num < 0 {
+		return nil, fmt.Errorf("pager/readFreelistAt(num=%d): %w", num, ErrPageOutOfRange)
+	}
+
+	b, _, err := pgr.readChain(num)
+	if err != nil {
+		return nil, fmt.Errorf("pager/readFreelistAt(num=%d): %w", num, err)
+	}
+
+	flist := NewFreelist()
+	flist.Order = pgr.meta.Order
+	flist.begin = PageNum(pgr.meta.ReservedPages)
+	if err := flist.Deserialize(b); err != nil {
+		return nil, fmt.Errorf("pager/readFreelistAt(num=%d): %w", num, err)
+	}
+
+	return flist, nil
+}
@@ -0,0 +1,83 @@
+package data_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_ReadMetaAt(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPager(filename, data.MinPageSize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	// Two flushes fill both meta slots, one transaction apart.
+	var pages []*data.Page
+	for i := 0; i < 2; i++ {
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		if _, err := pgr.Flush(); err != nil {
+			t.Fatalf("Failed to flush pager, with error %s", err)
+		}
+		pages = append(pages, pg)
+	}
+
+	meta, err := pgr.ReadMetaAt(data.DefaultMetaPage)
+	if err != nil {
+		t.Fatalf("Failed to read meta page %d, with error %s", data.DefaultMetaPage, err)
+	}
+	shadow, err := pgr.ReadMetaAt(data.ShadowMetaPage)
+	if err != nil {
+		t.Fatalf("Failed to read meta page %d, with error %s", data.ShadowMetaPage, err)
+	}
+
+	latest, previous := meta, shadow
+	if shadow.TxID > meta.TxID {
+		latest, previous = shadow, meta
+	}
+	if !latest.Equal(pgr.Meta()) {
+		t.Fatalf("Failed to compare latest meta slot: expected %+v, actual %+v", pgr.Meta(), latest)
+	}
+	if previous.TxID+1 != latest.TxID {
+		t.Fatalf(
+			"Failed to compare txid of previous meta slot: expected %d, actual %d",
+			latest.TxID-1, previous.TxID,
+		)
+	}
+
+	flist, err := pgr.ReadFreelistAt(latest.Freelist)
+	if err != nil {
+		t.Fatalf("Failed to read freelist at page %d, with error %s", latest.Freelist, err)
+	}
+	if !flist.Equal(pgr.Freelist()) || flist.Begin() != pgr.Freelist().Begin() {
+		t.Fatalf("Failed to compare freelist: expected %+v, actual %+v", pgr.Freelist(), flist)
+	}
+
+	if _, err := pgr.ReadMetaAt(pages[0].Num); !errors.Is(err, data.ErrBadMagic) {
+		t.Fatalf(
+			"Failed to read meta from data page %d: expected error %s, actual %v",
+			pages[0].Num, data.ErrBadMagic, err,
+		)
+	}
+
+	if _, err := pgr.ReadMetaAt(-1); !errors.Is(err, data.ErrPageOutOfRange) {
+		t.Fatalf(
+			"Failed to read meta from page -1: expected error %s, actual %v",
+			data.ErrPageOutOfRange, err,
+		)
+	}
+}
@@ -0,0 +1,46 @@
+package data
+
+// growFor extends the file by GrowChunk pages at once when page num, just
+// handed out by the freelist, lies past its end, instead of letting every
+// write past the end grow it by a page. A failure is only logged: the
+// write of the page grows the file anyway.
+func (pgr *Pager) growFor(num PageNum) {
+	end := (int64(num) + 1) * int64(pgr.psize)
+	if end <= pgr.fileSize {
+		return
+	}
+
+	// The size is asked for again before growing, since writes past the
+	// end and truncations change it behind fileSize.
+	size, err := pgr.storeSize()
+	if err != nil {
+		pgr.log.Warn("grow file", "page", num, "err", err)
+		return
+	}
+	pgr.fileSize = size
+
+	if end <= size {
+		return
+	}
+
+	pages := (size + int64(pgr.psize) - 1) / int64(pgr.psize)
+	size = max(end, (pages+int64(pgr.opts.GrowChunk))*int64(pgr.psize))
+
+	if err := pgr.truncateStore(size); err != nil {
+		pgr.log.Warn("grow file", "page", num, "size", size, "err", err)
+		return
+	}
+	pgr.fileSize = size
+}
+
+// tailSize returns the size the file is cut to when its tail is trimmed:
+// the end of the page space and, with WithGrowChunk, up to a chunk of the
+// pages preallocated past it.
+func (pgr *Pager) tailSize() int64 {
+	size := int64(pgr.flist.Max) * int64(pgr.psize)
+	if pgr.opts.GrowChunk > 0 {
+		chunk := int64(pgr.opts.GrowChunk) * int64(pgr.psize)
+		size = max(size, min(pgr.fileSize, size+chunk))
+	}
+	return size
+}
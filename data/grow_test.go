@@ -0,0 +1,141 @@
+package data_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_GrowChunk(t *testing.T) {
+	const chunk = 16
+
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPagerWithOptions(filename, data.MinPageSize, data.WithGrowChunk(chunk))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	fileSize := func() int64 {
+		t.Helper()
+
+		info, err := os.Stat(filename)
+		if err != nil {
+			t.Fatalf("Failed to stat file %s, with error %s", filename, err)
+		}
+		return info.Size()
+	}
+
+	// The file grows by a whole chunk whenever an allocated page lies past
+	// its end, and not at all for the rest of the chunk.
+	var grows []data.PageNum
+	size := fileSize()
+	for range 3*chunk + 1 {
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		pg.Write([]byte("data"))
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %d, with error %s", pg.Num, err)
+		}
+
+		actual := fileSize()
+		if actual == size {
+			continue
+		}
+		if len(grows) > 0 && actual != size+chunk*data.MinPageSize {
+			t.Fatalf(
+				"Failed to compare file size after page %d: expected %d, actual %d",
+				pg.Num, size+chunk*data.MinPageSize, actual,
+			)
+		}
+
+		grows = append(grows, pg.Num)
+		size = actual
+	}
+
+	// The first grow also covers the pages before the first allocation
+	// that were not written yet, so only the later ones are spaced a chunk
+	// apart.
+	if len(grows) < 3 {
+		t.Fatalf("Failed to compare number of grows: expected at least %d, actual %d", 3, len(grows))
+	}
+	for i := 2; i < len(grows); i++ {
+		if grows[i]-grows[i-1] != chunk {
+			t.Fatalf(
+				"Failed to compare pages between grows: expected %d, actual %d",
+				chunk, grows[i]-grows[i-1],
+			)
+		}
+	}
+}
+
+func TestPager_GrowChunkTrim(t *testing.T) {
+	const chunk = 16
+
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := data.MinPageSize
+
+	pgr, err := data.NewPagerWithOptions(
+		filename, psize,
+		data.WithGrowChunk(chunk), data.WithAutoShrink(true),
+	)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	var nums []data.PageNum
+	for i := 0; i < 4; i++ {
+		num, err := pgr.Append([]byte("data"))
+		if err != nil {
+			t.Fatalf("Failed to append page, with error %s", err)
+		}
+		nums = append(nums, num)
+	}
+
+	stats, err := pgr.Stats()
+	if err != nil {
+		t.Fatalf("Failed to get pager stats, with error %s", err)
+	}
+	if stats.PreallocatedPages == 0 || stats.FileSize != (int64(stats.HighWaterMark)+stats.PreallocatedPages)*int64(psize) {
+		t.Fatalf("Failed to report preallocated pages: %+v", stats)
+	}
+
+	// Both the flush shrinking the freelist and ShrinkToFit keep a chunk
+	// of preallocated pages past the lowered Max.
+	check := func(t *testing.T) {
+		t.Helper()
+
+		stats, err := pgr.Stats()
+		if err != nil {
+			t.Fatalf("Failed to get pager stats, with error %s", err)
+		}
+		if stats.PreallocatedPages == 0 || stats.PreallocatedPages > chunk {
+			t.Fatalf("Failed to keep up to %d preallocated pages after trim: %+v", chunk, stats)
+		}
+	}
+
+	if err := pgr.ReleasePage(nums[3]); err != nil {
+		t.Fatalf("Failed to release page %d, with error %s", nums[3], err)
+	}
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+	check(t)
+
+	if err := pgr.ReleasePage(nums[2]); err != nil {
+		t.Fatalf("Failed to release page %d, with error %s", nums[2], err)
+	}
+	if _, err := pgr.ShrinkToFit(); err != nil {
+		t.Fatalf("Failed to shrink pager, with error %s", err)
+	}
+	check(t)
+}
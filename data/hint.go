@@ -0,0 +1,91 @@
+package data
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+const hintHeaderSize = 8 + 4
+
+func hintPath(path string) string {
+	return path + ".hint"
+}
+
+// writeHint saves the freelist, followed by the overflow pages of its
+// chain, next to the store on a clean shutdown, so the next Recovery can
+// skip reading it from its pages. The hint is keyed by the meta TxID and
+// only written when the freelist has been flushed.
+func (pgr *Pager) writeHint() error {
+	if !pgr.opts.AllocHints || pgr.opts.ReadOnly || pgr.path == "" || pgr.aead != nil || pgr.flist.has(flistDirty) {
+		return nil
+	}
+
+	body := pgr.flist.Serialize()
+	for _, num := range pgr.flistChain.overflow() {
+		body = binary.LittleEndian.AppendUint64(body, uint64(num))
+	}
+
+	b := make([]byte, hintHeaderSize+len(body))
+	binary.LittleEndian.PutUint64(b[:8], pgr.meta.TxID)
+	binary.LittleEndian.PutUint32(b[8:12], crc32.Checksum(body, castagnoli))
+	copy(b[hintHeaderSize:], body)
+
+	if err := os.WriteFile(hintPath(pgr.path), b, DefaultFilePerm); err != nil {
+		return fmt.Errorf("write hint: %w", err)
+	}
+
+	return nil
+}
+
+// recoverHint loads the freelist from the hint file. It reports false,
+// leaving the freelist untouched, when the hint is missing, damaged or
+// was written for another transaction than the recovered meta.
+func (pgr *Pager) recoverHint() (bool, error) {
+	if !pgr.opts.AllocHints || pgr.path == "" || pgr.aead != nil {
+		return false, nil
+	}
+
+	b, err := os.ReadFile(hintPath(pgr.path))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("recover hint: %w", err)
+	}
+
+	if len(b) < hintHeaderSize || binary.LittleEndian.Uint64(b[:8]) != pgr.meta.TxID {
+		return false, nil
+	}
+
+	body := b[hintHeaderSize:]
+	if crc32.Checksum(body, castagnoli) != binary.LittleEndian.Uint32(b[8:12]) {
+		return false, nil
+	}
+
+	flist := NewFreelist()
+	flist.Order, flist.begin = pgr.meta.Order, pgr.flist.begin
+	if err := flist.Deserialize(body); err != nil {
+		return false, nil
+	}
+
+	overflow := body[freelistHeaderSize+8*len(flist.Released):]
+	if len(overflow) != 8*(pgr.meta.FreelistPages-1) {
+		return false, nil
+	}
+
+	nums := make([]PageNum, 0, pgr.meta.FreelistPages)
+	nums = append(nums, pgr.meta.Freelist)
+	for i := 0; i < len(overflow); i += 8 {
+		nums = append(nums, PageNum(binary.LittleEndian.Uint64(overflow[i:i+8])))
+	}
+	pgr.flistChain = shadowChain{cur: nums}
+
+	pgr.flist.Max = flist.Max
+	pgr.flist.Released = flist.Released
+	pgr.flist.set(flistDirty, false)
+
+	return true, nil
+}
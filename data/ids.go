@@ -0,0 +1,134 @@
+package data
+
+import (
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// StableID names a page independently of its page number, so references
+// held outside the store survive pages being relocated by Compact.
+type StableID uint64
+
+type IDTable struct {
+	Last    StableID
+	Entries map[StableID]PageNum
+}
+
+func NewIDTable() *IDTable {
+	return &IDTable{
+		Entries: make(map[StableID]PageNum),
+	}
+}
+
+func (ids *IDTable) Serialize() []byte {
+	b := make([]byte, 8+4+(16*len(ids.Entries)))
+
+	binary.LittleEndian.PutUint64(b[:8], uint64(ids.Last))
+	binary.LittleEndian.PutUint32(b[8:12], uint32(len(ids.Entries)))
+
+	off := 12
+	for _, id := range slices.Sorted(maps.Keys(ids.Entries)) {
+		binary.LittleEndian.PutUint64(b[off:off+8], uint64(id))
+		binary.LittleEndian.PutUint64(b[off+8:off+16], uint64(ids.Entries[id]))
+		off += 16
+	}
+
+	return b
+}
+
+func (ids *IDTable) Deserialize(b []byte) error {
+	if len(b) < 8+4 {
+		return fmt.Errorf("idTable/deserialize: decode head: %w", ErrWrongBytes)
+	}
+
+	ids.Last = StableID(binary.LittleEndian.Uint64(b[:8]))
+	count := int(binary.LittleEndian.Uint32(b[8:12]))
+
+	if len(b) < (8+4)+(16*count) {
+		return fmt.Errorf("idTable/deserialize: decode body: %w", ErrWrongBytes)
+	}
+
+	ids.Entries = make(map[StableID]PageNum, count)
+	for i, off := 0, 12; i < count; i, off = i+1, off+16 {
+		id := StableID(binary.LittleEndian.Uint64(b[off : off+8]))
+		ids.Entries[id] = PageNum(binary.LittleEndian.Uint64(b[off+8 : off+16]))
+	}
+
+	return nil
+}
+
+func (ids *IDTable) Equal(other *IDTable) bool {
+	return ids.Last == other.Last && maps.Equal(ids.Entries, other.Entries)
+}
+
+// AssignID returns a new stable id resolving to num.
+func (pgr *Pager) AssignID(num PageNum) StableID {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	pgr.ids.Last += 1
+	pgr.ids.Entries[pgr.ids.Last] = num
+	pgr.changed = true
+	return pgr.ids.Last
+}
+
+func (pgr *Pager) Resolve(id StableID) (PageNum, error) {
+	pgr.mu.RLock()
+	defer pgr.mu.RUnlock()
+
+	num, ok := pgr.ids.Entries[id]
+	if !ok {
+		return 0, fmt.Errorf("pager/resolve(id=%d): %w", id, ErrUnknownID)
+	}
+
+	return num, nil
+}
+
+func (pgr *Pager) ForgetID(id StableID) {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	delete(pgr.ids.Entries, id)
+	pgr.changed = true
+}
+
+func (pgr *Pager) flushIDs() error {
+	if len(pgr.idChain.cur) == 0 && len(pgr.ids.Entries) == 0 && pgr.ids.Last == 0 {
+		pgr.meta.IDs = 0
+		return nil
+	}
+
+	nums, err := pgr.writeChain(pgr.idChain.target(), PageTypeOverflow, pgr.ids.Serialize())
+	if err != nil {
+		return fmt.Errorf("flush ids: %w", err)
+	}
+
+	pgr.idChain.wrote(nums)
+	pgr.meta.IDs = nums[0]
+
+	return nil
+}
+
+func (pgr *Pager) recoverIDs() error {
+	pgr.ids = NewIDTable()
+	pgr.idChain = shadowChain{}
+
+	if pgr.meta.IDs == 0 {
+		return nil
+	}
+
+	b, nums, err := pgr.readChain(pgr.meta.IDs)
+	if err != nil {
+		return fmt.Errorf("recover ids: %w", err)
+	}
+
+	if err := pgr.ids.Deserialize(b); err != nil {
+		return fmt.Errorf("recover ids: %w", err)
+	}
+
+	pgr.idChain = shadowChain{cur: nums}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !unix && !windows
+
+package data
+
+import "os"
+
+// lockFile does nothing where the platform offers no file locks.
+func lockFile(_ *os.File, _ bool) error {
+	return nil
+}
+
+func unlockFile(_ *os.File) error {
+	return nil
+}
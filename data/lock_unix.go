@@ -0,0 +1,38 @@
+//go:build unix
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile takes an advisory lock on f without waiting for it, shared for
+// readers and exclusive for writers. It returns ErrLocked when another
+// open of the file holds a conflicting lock. Closing f releases it.
+func lockFile(f *os.File, shared bool) error {
+	how := unix.LOCK_EX
+	if shared {
+		how = unix.LOCK_SH
+	}
+
+	if err := unix.Flock(int(f.Fd()), how|unix.LOCK_NB); err != nil {
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			return fmt.Errorf("lock file %s: %w", f.Name(), ErrLocked)
+		}
+		return fmt.Errorf("lock file %s: %w", f.Name(), err)
+	}
+
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_UN); err != nil {
+		return fmt.Errorf("unlock file %s: %w", f.Name(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+//go:build windows
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile takes an advisory lock on f without waiting for it, shared for
+// readers and exclusive for writers. It returns ErrLocked when another
+// open of the file holds a conflicting lock. Closing f releases it.
+func lockFile(f *os.File, shared bool) error {
+	flags := uint32(windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if !shared {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+
+	err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, math.MaxUint32, math.MaxUint32, new(windows.Overlapped))
+	if err != nil {
+		if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+			return fmt.Errorf("lock file %s: %w", f.Name(), ErrLocked)
+		}
+		return fmt.Errorf("lock file %s: %w", f.Name(), err)
+	}
+
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	err := windows.UnlockFileEx(windows.Handle(f.Fd()), 0, math.MaxUint32, math.MaxUint32, new(windows.Overlapped))
+	if err != nil {
+		return fmt.Errorf("unlock file %s: %w", f.Name(), err)
+	}
+
+	return nil
+}
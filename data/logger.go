@@ -0,0 +1,22 @@
+package data
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler drops every record, the handler of a pager without
+// WithLogger.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// logShortWrite reports a write the store cut short, which leaves a torn
+// page behind until the next successful write of it.
+func (pgr *Pager) logShortWrite(num PageNum, err error) error {
+	pgr.log.Error("short write", "page", num, "err", err)
+	return err
+}
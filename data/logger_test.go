@@ -0,0 +1,78 @@
+package data_test
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_Logger(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	pgr, err := data.NewPagerWithOptions(filename, psize, data.WithLogger(logger))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	// A flush on top of the one on creation fills both meta slots.
+	nextPage(t, pgr)
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	newest, older := data.DefaultMetaPage, data.ShadowMetaPage
+	if meta, err := pgr.ReadMetaAt(older); err == nil && meta.TxID == pgr.Meta().TxID {
+		newest, older = older, newest
+	}
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	if out.Len() != 0 {
+		t.Fatalf("Failed to keep the write path quiet: %s", out.String())
+	}
+
+	f, err := os.OpenFile(filename, os.O_RDWR, data.DefaultFilePerm)
+	if err != nil {
+		t.Fatalf("Failed to open file %s, with error %s", filename, err)
+	}
+
+	off := int64(newest)*int64(psize) + int64(psize/2)
+	if _, err := f.WriteAt([]byte{0xff}, off); err != nil {
+		t.Fatalf("Failed to corrupt newest meta page, with error %s", err)
+	}
+	_ = f.Close()
+
+	reopened, err := data.NewPagerWithOptions(filename, psize, data.WithLogger(logger))
+	if err != nil {
+		t.Fatalf(
+			"Failed to reopen pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer reopened.Close()
+
+	for _, expected := range []string{
+		fmt.Sprintf("level=WARN msg=\"page checksum mismatch\" page=%d", newest),
+		fmt.Sprintf("level=DEBUG msg=\"skipped meta slot\" slot=%d", newest),
+		fmt.Sprintf("level=DEBUG msg=\"recovered metainfo\" slot=%d txid=%d", older, reopened.Meta().TxID),
+		"level=DEBUG msg=\"recovered freelist\"",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("Failed to find %q in log output:\n%s", expected, out.String())
+		}
+	}
+}
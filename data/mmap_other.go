@@ -0,0 +1,27 @@
+//go:build !unix
+
+package data
+
+import (
+	"os"
+)
+
+type mmapBackend struct {
+	*os.File
+}
+
+func newMmapBackend(_ *os.File) (*mmapBackend, error) {
+	return nil, errMmapUnsupported
+}
+
+func (be *mmapBackend) osFile() *os.File {
+	return be.File
+}
+
+func (be *mmapBackend) mapped(_ int64, _ int) ([]byte, bool) {
+	return nil, false
+}
+
+func (be *mmapBackend) refresh() error {
+	return nil
+}
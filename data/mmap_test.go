@@ -0,0 +1,188 @@
+package data_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+	"github.com/protomem/embedstore/pkg/rand"
+)
+
+func TestPager_Mmap(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPagerWithOptions(filename, psize, data.WithMmap(true))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	// Reading between writes makes the mapping grow with the file.
+	var pages []*data.Page
+	for i := 0; i < 20; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		pages = append(pages, pg)
+
+		for _, expectedPg := range pages {
+			actualPg, err := pgr.Read(expectedPg.Num)
+			if err != nil {
+				t.Fatalf("Failed to read page %d, with error %s", expectedPg.Num, err)
+			}
+
+			if !bytes.Equal(expectedPg.Data, actualPg.Data) {
+				t.Fatalf("Failed to compare mapped page %d data", expectedPg.Num)
+			}
+		}
+	}
+
+	pages[0].Write([]byte("updated"))
+	if err := pgr.Write(pages[0]); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pages[0], err)
+	}
+
+	b, err := pgr.PageData(pages[0].Num)
+	if err != nil {
+		t.Fatalf("Failed to read page %d, with error %s", pages[0].Num, err)
+	}
+
+	if !bytes.Equal(pages[0].Data, b) {
+		t.Fatalf("Failed to compare mapped page %d data after overwrite", pages[0].Num)
+	}
+}
+
+func TestPager_MmapFlushSize(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPagerWithOptions(filename, psize, data.WithMmap(true))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	// Flushes write the meta page next to its freelist page, which must go
+	// through the mapping rather than straight to the file.
+	for i := 0; i < 2; i++ {
+		nextPage(t, pgr)
+		if _, err := pgr.Flush(); err != nil {
+			t.Fatalf("Failed to flush pager, with error %s", err)
+		}
+
+		info, err := os.Stat(filename)
+		if err != nil {
+			t.Fatalf("Failed to stat file %s, with error %s", filename, err)
+		}
+		stats, err := pgr.Stats()
+		if err != nil {
+			t.Fatalf("Failed to get pager stats, with error %s", err)
+		}
+		if stats.FileSize != info.Size() {
+			t.Fatalf("Failed to compare file size: expected %d, actual %d", info.Size(), stats.FileSize)
+		}
+	}
+}
+
+func TestPager_MmapHeldPage(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPagerWithOptions(filename, psize, data.WithMmap(true))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	var nums []data.PageNum
+	for i := 0; i < 8; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		nums = append(nums, pg.Num)
+	}
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	last := nums[len(nums)-1]
+	held, err := pgr.Read(last)
+	if err != nil {
+		t.Fatalf("Failed to read page %d, with error %s", last, err)
+	}
+
+	// Pages held across a shrink or a close stay readable.
+	for _, num := range nums[len(nums)/2:] {
+		if err := pgr.ReleasePage(num); err != nil {
+			t.Fatalf("Failed to release page %d, with error %s", num, err)
+		}
+	}
+	if _, err := pgr.ShrinkToFit(); err != nil {
+		t.Fatalf("Failed to shrink pager, with error %s", err)
+	}
+	if !bytes.HasPrefix(held.Data, []byte("data8")) {
+		t.Fatalf("Failed to compare held page %d data after shrink: actual %q", last, held.Data[:5])
+	}
+
+	first, err := pgr.Read(nums[0])
+	if err != nil {
+		t.Fatalf("Failed to read page %d, with error %s", nums[0], err)
+	}
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+	if !bytes.HasPrefix(first.Data, []byte("data1")) {
+		t.Fatalf("Failed to compare held page %d data after close: actual %q", nums[0], first.Data[:5])
+	}
+}
+
+func BenchmarkPager_ReadRandom(b *testing.B) {
+	for name, mmap := range map[string]bool{"mmap": true, "readAt": false} {
+		b.Run(name, func(b *testing.B) {
+			filename := filepath.Join(b.TempDir(), "bench_data")
+
+			pgr, err := data.NewPagerWithOptions(filename, os.Getpagesize(), data.WithMmap(mmap))
+			if err != nil {
+				b.Fatalf(
+					"Failed to create pager by path %s, with error %s",
+					filename, err,
+				)
+			}
+			defer pgr.Close()
+
+			pages := make([]*data.Page, 1000)
+			for i := range pages {
+				pages[i] = pgr.Alloc().WithNum(nextFree(b, pgr.Freelist()))
+				pages[i].Write([]byte(fmt.Sprintf("data%d", i+1)))
+			}
+			if err := pgr.WriteBatch(pages); err != nil {
+				b.Fatalf("Failed to write pages, with error %s", err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				num := pages[rand.Range(0, len(pages))].Num
+				if _, err := pgr.Read(num); err != nil {
+					b.Fatalf("Failed to read page %d, with error %s", num, err)
+				}
+			}
+		})
+	}
+}
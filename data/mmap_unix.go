@@ -0,0 +1,156 @@
+//go:build unix
+
+package data
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmapBackend serves reads from a read-only shared mapping of the file
+// and writes through the file. The mapping grows with the file; earlier
+// mappings stay alive until Close, since a read in flight may still copy
+// out of them. Nothing handed out by the pager points into a mapping.
+type mmapBackend struct {
+	*os.File
+
+	mu   sync.RWMutex
+	size int64
+	data []byte
+	old  [][]byte
+}
+
+func newMmapBackend(f *os.File) (*mmapBackend, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("mmap: %w", err)
+	}
+
+	return &mmapBackend{File: f, size: info.Size()}, nil
+}
+
+func (be *mmapBackend) osFile() *os.File {
+	return be.File
+}
+
+// mapped returns the n bytes at off straight from the mapping. It reports
+// false when the range is past the end of the file.
+func (be *mmapBackend) mapped(off int64, n int) ([]byte, bool) {
+	end := off + int64(n)
+
+	be.mu.RLock()
+	if end <= be.size && end <= int64(len(be.data)) {
+		b := be.data[off:end:end]
+		be.mu.RUnlock()
+		return b, true
+	}
+	be.mu.RUnlock()
+
+	be.mu.Lock()
+	defer be.mu.Unlock()
+
+	if end > be.size {
+		return nil, false
+	}
+
+	if end > int64(len(be.data)) {
+		// Mapping past the end of the file is fine as long as those
+		// bytes are never touched, and it saves remaps as the file grows.
+		length := max(be.size, 2*int64(len(be.data)))
+
+		data, err := unix.Mmap(int(be.Fd()), 0, int(length), unix.PROT_READ, unix.MAP_SHARED)
+		if err != nil {
+			return nil, false
+		}
+
+		if be.data != nil {
+			be.old = append(be.old, be.data)
+		}
+		be.data = data
+	}
+
+	return be.data[off:end:end], true
+}
+
+func (be *mmapBackend) ReadAt(b []byte, off int64) (int, error) {
+	if mapped, ok := be.mapped(off, len(b)); ok {
+		return copy(b, mapped), nil
+	}
+
+	return be.File.ReadAt(b, off)
+}
+
+func (be *mmapBackend) WriteAt(b []byte, off int64) (int, error) {
+	n, err := be.File.WriteAt(b, off)
+
+	be.mu.Lock()
+	be.size = max(be.size, off+int64(n))
+	be.mu.Unlock()
+
+	return n, err
+}
+
+// Truncate cuts the file. Mapped pages past the new end must not be
+// touched afterwards, so mapped only serves ranges within the file.
+func (be *mmapBackend) Truncate(size int64) error {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+
+	if err := be.File.Truncate(size); err != nil {
+		return err
+	}
+
+	be.size = size
+
+	if size < int64(len(be.data)) {
+		be.old = append(be.old, be.data)
+		be.data = nil
+	}
+
+	return nil
+}
+
+// refresh picks up the size of a file changed by another process. A file
+// that shrank retires the mapping, as Truncate does.
+func (be *mmapBackend) refresh() error {
+	info, err := be.File.Stat()
+	if err != nil {
+		return fmt.Errorf("mmap: %w", err)
+	}
+
+	be.mu.Lock()
+	defer be.mu.Unlock()
+
+	be.size = info.Size()
+
+	if be.size < int64(len(be.data)) {
+		be.old = append(be.old, be.data)
+		be.data = nil
+	}
+
+	return nil
+}
+
+func (be *mmapBackend) Size() (int64, error) {
+	be.mu.RLock()
+	defer be.mu.RUnlock()
+
+	return be.size, nil
+}
+
+func (be *mmapBackend) Close() error {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+
+	for _, data := range append(be.old, be.data) {
+		if data != nil {
+			_ = unix.Munmap(data)
+		}
+	}
+	be.data, be.old = nil, nil
+
+	return be.File.Close()
+}
@@ -0,0 +1,40 @@
+package data
+
+import "time"
+
+// Observer is told about completed pager operations, for metrics and
+// tracing. Only operations that succeed are reported. Callbacks run
+// synchronously inside the operation with its locks held, concurrently for
+// concurrent reads, so they must be quick, safe for concurrent use and
+// must not call back into the pager.
+type Observer interface {
+	// OnRead reports a page returned by Read, ReadInto or ReadRange.
+	OnRead(num PageNum, dur time.Duration)
+	// OnWrite reports a page stored by Write.
+	OnWrite(num PageNum, dur time.Duration)
+	// OnFlush reports a flush, whether from Flush, a commit or Close.
+	OnFlush(dur time.Duration)
+	// OnCacheHit and OnCacheMiss report reads through the page cache.
+	OnCacheHit(num PageNum)
+	OnCacheMiss(num PageNum)
+}
+
+// NopObserver ignores every callback. It is the observer of a pager
+// without WithObserver, and can be embedded by observers that only care
+// about some of the callbacks.
+type NopObserver struct{}
+
+func (NopObserver) OnRead(PageNum, time.Duration)  {}
+func (NopObserver) OnWrite(PageNum, time.Duration) {}
+func (NopObserver) OnFlush(time.Duration)          {}
+func (NopObserver) OnCacheHit(PageNum)             {}
+func (NopObserver) OnCacheMiss(PageNum)            {}
+
+// observeStart returns the start time of an observed operation, or the
+// zero time without an observer, which spares reading the clock.
+func (pgr *Pager) observeStart() time.Time {
+	if _, ok := pgr.observer.(NopObserver); ok {
+		return time.Time{}
+	}
+	return time.Now()
+}
@@ -0,0 +1,86 @@
+package data_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/protomem/embedstore/data"
+)
+
+type recordingObserver struct {
+	data.NopObserver
+
+	mu      sync.Mutex
+	reads   []data.PageNum
+	writes  []data.PageNum
+	flushes int
+}
+
+func (o *recordingObserver) OnRead(num data.PageNum, _ time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.reads = append(o.reads, num)
+}
+
+func (o *recordingObserver) OnWrite(num data.PageNum, _ time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.writes = append(o.writes, num)
+}
+
+func (o *recordingObserver) OnFlush(time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.flushes += 1
+}
+
+func TestPager_Observer(t *testing.T) {
+	o := &recordingObserver{}
+
+	pgr, err := data.NewMemPager(512, data.WithObserver(o))
+	if err != nil {
+		t.Fatalf("Failed to create in-memory pager, with error %s", err)
+	}
+	defer pgr.Close()
+
+	// Creating the store flushes it once.
+	if o.flushes != 1 || len(o.reads) != 0 || len(o.writes) != 0 {
+		t.Fatalf("Failed to compare callbacks after create: %+v", o)
+	}
+
+	pages := []*data.Page{pgr.Alloc().WithNum(nextFree(t, pgr.Freelist())), pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))}
+	for _, pg := range pages {
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %d, with error %s", pg.Num, err)
+		}
+	}
+
+	if _, err := pgr.Read(pages[0].Num); err != nil {
+		t.Fatalf("Failed to read page %d, with error %s", pages[0].Num, err)
+	}
+	if err := pgr.ReadInto(pages[1].Num, pgr.Alloc()); err != nil {
+		t.Fatalf("Failed to read page %d, with error %s", pages[1].Num, err)
+	}
+	if _, err := pgr.Read(pgr.Freelist().Max); !errors.Is(err, data.ErrPageOutOfRange) {
+		t.Fatalf("Failed to read page past max: expected error %s, actual %v", data.ErrPageOutOfRange, err)
+	}
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.writes) != 2 || o.writes[0] != pages[0].Num || o.writes[1] != pages[1].Num {
+		t.Fatalf("Failed to compare observed writes: expected pages %d and %d, actual %v", pages[0].Num, pages[1].Num, o.writes)
+	}
+	if len(o.reads) != 2 || o.reads[0] != pages[0].Num || o.reads[1] != pages[1].Num {
+		t.Fatalf("Failed to compare observed reads: expected pages %d and %d, actual %v", pages[0].Num, pages[1].Num, o.reads)
+	}
+	if o.flushes != 2 {
+		t.Fatalf("Failed to compare observed flushes: expected 2, actual %d", o.flushes)
+	}
+}
@@ -0,0 +1,372 @@
+package data
+
+import (
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Options configures a Pager. The zero value is the default configuration.
+type Options struct {
+	// StrictWrites makes Write reject pages whose Data is not exactly one
+	// page long.
+	StrictWrites bool
+
+	// GroupCommitWindow is how long Commit waits for concurrent commits to
+	// share one flush. Zero disables group commit.
+	GroupCommitWindow time.Duration
+
+	// PageCompression transparently compresses data pages. It changes the
+	// on-disk page format, so a file must always be opened with the same
+	// setting. Compressed pages keep their whole page on disk, so the file
+	// is no smaller; see Codec.
+	PageCompression bool
+
+	// Codec compresses pages with PageCompression, nil means FlateCodec.
+	Codec Codec
+
+	// AllocHints writes the freelist to a ".hint" file next to the store
+	// on Close and lets the next open read it from there when it matches
+	// the recovered metainfo.
+	AllocHints bool
+
+	// NoSync skips fsync in Flush, trading durability for speed. By
+	// default every flush is durable.
+	NoSync bool
+
+	// StateChecksum maintains the checksum Pager.StateChecksum reports.
+	// It costs a map entry per page written and, for the first write of a
+	// page since the open, a read of what the page held. A store that was
+	// not closed cleanly, or was closed by a pager with NoSync, has the
+	// checksum recomputed by reading the whole file when it is opened.
+	StateChecksum bool
+
+	// Checksum computes the checksum stored in every page header, nil
+	// means CRC32C. It must be the same every time a file is opened.
+	Checksum ChecksumFunc
+
+	// NoChecksum disables writing and verifying page checksums.
+	NoChecksum bool
+
+	// FilePerm is the permission a new file is created with, zero means
+	// DefaultFilePerm.
+	FilePerm os.FileMode
+
+	// ReadOnly opens an existing file without write access. Every
+	// mutation of the file returns ErrReadOnly.
+	ReadOnly bool
+
+	// NoLock skips the advisory lock NewPager takes on the file, which is
+	// exclusive for read-write opens and shared for read-only ones, and
+	// makes a conflicting open fail with ErrLocked.
+	NoLock bool
+
+	// AllocStrategy picks the released page the freelist hands out
+	// next. The zero value is LIFO.
+	AllocStrategy AllocStrategy
+
+	// Mmap serves reads from a shared mapping of the file, where the
+	// platform supports it, instead of a read call per page. Pages
+	// returned by Read are still copies and stay valid after the file
+	// shrinks or the pager is closed.
+	Mmap bool
+
+	// InitialPages extends a newly created file to hold that many pages
+	// up front. Zero only writes the meta and freelist pages.
+	InitialPages int
+
+	// GrowChunk extends the file by that many pages at once whenever the
+	// freelist hands out a page past its end. Zero lets writes grow the
+	// file a page at a time.
+	GrowChunk int
+
+	// AutoShrink makes Flush lower the freelist Max past the pages released
+	// last when they are the highest ones, and truncate the file to match.
+	AutoShrink bool
+
+	// ZeroOnRelease overwrites released pages with zeros on the next
+	// flush, unless they were handed out again in the meantime.
+	ZeroOnRelease bool
+
+	// EncryptionKey encrypts every page, meta and freelist pages included,
+	// with AES-GCM under this 16, 24 or 32 byte key. Each page takes a
+	// nonce and a tag out of its payload. Allocation hints are not written
+	// for encrypted stores, and StateChecksum covers the ciphertext.
+	EncryptionKey []byte
+
+	// BufferedWrites keeps written pages in memory until the next Flush,
+	// which writes them all and syncs once. Reads see buffered pages.
+	BufferedWrites bool
+
+	// NoFlushOnClose makes Close drop whatever was not flushed instead of
+	// flushing it.
+	NoFlushOnClose bool
+
+	// ByteOrder is the byte order a new file encodes its metainfo and
+	// freelist in. Existing files keep the order they were created with.
+	ByteOrder ByteOrder
+
+	// ChecksumPlacement is where a new file stores the checksum and type
+	// of its pages. Existing files keep the placement they were created
+	// with.
+	ChecksumPlacement ChecksumPlacement
+
+	// ReservedPages is the number of pages at the start of a new file the
+	// freelist never hands out, for structures of the caller or of later
+	// versions of the pager. Zero means BeginFreeBlocks, the least there
+	// is. Existing files keep the count they were created with.
+	ReservedPages int
+
+	// CacheSize is the number of pages the page cache holds. Zero
+	// disables the cache.
+	CacheSize int
+
+	// Readahead is the number of pages the page cache reads at once when
+	// a sequential read misses it, at most CacheSize. Zero reads one page
+	// at a time, as does a pager without a cache.
+	Readahead int
+
+	// Observer is told about reads, writes, flushes and cache lookups,
+	// nil means NopObserver.
+	Observer Observer
+
+	// Logger receives debug records about recovery, warnings about
+	// damaged pages and errors about failed writes. Nil discards them.
+	Logger *slog.Logger
+
+	// CorruptionPolicy decides what Recovery does with a freelist that
+	// fails to load. The zero value is PolicyFail.
+	CorruptionPolicy CorruptionPolicy
+}
+
+func (opts Options) filePerm() os.FileMode {
+	if opts.FilePerm == 0 {
+		return DefaultFilePerm
+	}
+	return opts.FilePerm
+}
+
+func (opts Options) observer() Observer {
+	if opts.Observer == nil {
+		return NopObserver{}
+	}
+	return opts.Observer
+}
+
+func (opts Options) logger() *slog.Logger {
+	if opts.Logger == nil {
+		return slog.New(discardHandler{})
+	}
+	return opts.Logger
+}
+
+func (opts Options) codec() Codec {
+	if opts.Codec == nil {
+		return FlateCodec{}
+	}
+	return opts.Codec
+}
+
+func (opts Options) checksumFunc() ChecksumFunc {
+	switch {
+	case opts.NoChecksum:
+		return nil
+	case opts.Checksum != nil:
+		return opts.Checksum
+	default:
+		return CRC32C
+	}
+}
+
+type PagerOption func(*Options)
+
+func WithStrictWrites(strict bool) PagerOption {
+	return func(opts *Options) {
+		opts.StrictWrites = strict
+	}
+}
+
+func WithGroupCommit(window time.Duration) PagerOption {
+	return func(opts *Options) {
+		opts.GroupCommitWindow = window
+	}
+}
+
+func WithPageCompression(enabled bool) PagerOption {
+	return func(opts *Options) {
+		opts.PageCompression = enabled
+	}
+}
+
+// WithCodec enables page compression with codec, nil disables it.
+func WithCodec(codec Codec) PagerOption {
+	return func(opts *Options) {
+		opts.Codec = codec
+		opts.PageCompression = codec != nil
+	}
+}
+
+func WithAllocHints(enabled bool) PagerOption {
+	return func(opts *Options) {
+		opts.AllocHints = enabled
+	}
+}
+
+// WithStateChecksum controls whether the pager maintains its state
+// checksum, see Options.StateChecksum.
+func WithStateChecksum(enabled bool) PagerOption {
+	return func(opts *Options) {
+		opts.StateChecksum = enabled
+	}
+}
+
+// WithSync controls whether Flush syncs the file to stable storage.
+func WithSync(enabled bool) PagerOption {
+	return func(opts *Options) {
+		opts.NoSync = !enabled
+	}
+}
+
+// WithChecksum sets the page checksum function, nil disables checksums.
+func WithChecksum(fn ChecksumFunc) PagerOption {
+	return func(opts *Options) {
+		opts.Checksum = fn
+		opts.NoChecksum = fn == nil
+	}
+}
+
+func WithChecksumPlacement(place ChecksumPlacement) PagerOption {
+	return func(opts *Options) {
+		opts.ChecksumPlacement = place
+	}
+}
+
+func WithFilePerm(perm os.FileMode) PagerOption {
+	return func(opts *Options) {
+		opts.FilePerm = perm
+	}
+}
+
+func WithReadOnly(enabled bool) PagerOption {
+	return func(opts *Options) {
+		opts.ReadOnly = enabled
+	}
+}
+
+func WithCorruptionPolicy(policy CorruptionPolicy) PagerOption {
+	return func(opts *Options) {
+		opts.CorruptionPolicy = policy
+	}
+}
+
+// WithFileLock(false) opens the file without locking it, for tools that
+// inspect or repair a store another pager has open.
+func WithFileLock(enabled bool) PagerOption {
+	return func(opts *Options) {
+		opts.NoLock = !enabled
+	}
+}
+
+// WithGrowChunk grows the file by pages at a time as pages are allocated,
+// which saves syscalls and keeps the file less fragmented.
+func WithGrowChunk(pages int) PagerOption {
+	return func(opts *Options) {
+		opts.GrowChunk = pages
+	}
+}
+
+// WithAutoShrink lets a store that frees the pages it allocated last give
+// the space back at the next flush, without a ShrinkToFit.
+func WithAutoShrink(enabled bool) PagerOption {
+	return func(opts *Options) {
+		opts.AutoShrink = enabled
+	}
+}
+
+func WithInitialPages(n int) PagerOption {
+	return func(opts *Options) {
+		opts.InitialPages = n
+	}
+}
+
+func WithMmap(enabled bool) PagerOption {
+	return func(opts *Options) {
+		opts.Mmap = enabled
+	}
+}
+
+func WithAllocStrategy(strategy AllocStrategy) PagerOption {
+	return func(opts *Options) {
+		opts.AllocStrategy = strategy
+	}
+}
+
+func WithZeroOnRelease(enabled bool) PagerOption {
+	return func(opts *Options) {
+		opts.ZeroOnRelease = enabled
+	}
+}
+
+func WithEncryption(key []byte) PagerOption {
+	return func(opts *Options) {
+		opts.EncryptionKey = key
+	}
+}
+
+func WithBufferedWrites(enabled bool) PagerOption {
+	return func(opts *Options) {
+		opts.BufferedWrites = enabled
+	}
+}
+
+// WithFlushOnClose controls whether Close flushes the pager first.
+func WithFlushOnClose(enabled bool) PagerOption {
+	return func(opts *Options) {
+		opts.NoFlushOnClose = !enabled
+	}
+}
+
+func WithByteOrder(order ByteOrder) PagerOption {
+	return func(opts *Options) {
+		opts.ByteOrder = order
+	}
+}
+
+// WithCache keeps the n pages read most recently in memory, so that Read
+// and PageData serve them without going to the file. The observer is told
+// about every lookup through OnCacheHit and OnCacheMiss.
+func WithCache(n int) PagerOption {
+	return func(opts *Options) {
+		opts.CacheSize = n
+	}
+}
+
+// WithReadahead makes the page cache of WithCache read n pages in one
+// read when a read misses it on the page following the one read last, and
+// keep them all. It does nothing without WithCache.
+func WithReadahead(n int) PagerOption {
+	return func(opts *Options) {
+		opts.Readahead = n
+	}
+}
+
+// WithObserver reports pager activity to o.
+func WithObserver(o Observer) PagerOption {
+	return func(opts *Options) {
+		opts.Observer = o
+	}
+}
+
+// WithLogger sends the log records of the pager to logger.
+func WithLogger(logger *slog.Logger) PagerOption {
+	return func(opts *Options) {
+		opts.Logger = logger
+	}
+}
+
+// WithReservedPages reserves the first n pages of a new file.
+func WithReservedPages(n int) PagerOption {
+	return func(opts *Options) {
+		opts.ReservedPages = n
+	}
+}
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"fmt"
+)
+
+// WriteOverflow stores b in a chain of freshly allocated pages, each
+// holding the number of the next page and the length of its chunk, and
+// returns the head of the chain.
+func (pgr *Pager) WriteOverflow(b []byte) (PageNum, error) {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.closed {
+		return 0, fmt.Errorf("pager/writeOverflow: %w", ErrClosed)
+	}
+
+	if pgr.opts.ReadOnly {
+		return 0, fmt.Errorf("pager/writeOverflow: %w", ErrReadOnly)
+	}
+
+	nums, err := pgr.writeChain(nil, PageTypeOverflow, b)
+	if err != nil {
+		return 0, fmt.Errorf("pager/writeOverflow: %w", err)
+	}
+
+	return nums[0], nil
+}
+
+// ReadOverflow returns the value stored by WriteOverflow at head.
+func (pgr *Pager) ReadOverflow(head PageNum) ([]byte, error) {
+	pgr.mu.RLock()
+	defer pgr.mu.RUnlock()
+
+	if pgr.closed {
+		return nil, fmt.Errorf("pager/readOverflow: %w", ErrClosed)
+	}
+
+	b, _, err := pgr.readChain(head)
+	if err != nil {
+		return nil, fmt.Errorf("pager/readOverflow: %w", err)
+	}
+
+	return b, nil
+}
+
+// FreeOverflow releases every page of the chain starting at head, which
+// is read in full first: a chain pointing back into itself returns
+// ErrCorruptChain and a page that fails to read returns its error, both
+// without releasing any page.
+func (pgr *Pager) FreeOverflow(head PageNum) error {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.closed {
+		return fmt.Errorf("pager/freeOverflow: %w", ErrClosed)
+	}
+
+	if pgr.opts.ReadOnly {
+		return fmt.Errorf("pager/freeOverflow: %w", ErrReadOnly)
+	}
+
+	if err := pgr.freeChain(head); err != nil {
+		return fmt.Errorf("pager/freeOverflow: %w", err)
+	}
+
+	return nil
+}
+
+// freeChain releases every page of the chain starting at head.
+func (pgr *Pager) freeChain(head PageNum) error {
+	_, nums, err := pgr.readChain(head)
+	if err != nil {
+		return err
+	}
+
+	return pgr.flist.ReleaseAll(nums)
+}
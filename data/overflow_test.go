@@ -0,0 +1,189 @@
+package data_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_Overflow(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	for name, size := range map[string]int{
+		"page size":     psize,
+		"page size+1":   psize + 1,
+		"several pages": 5*psize + psize/3,
+	} {
+		t.Run(name, func(t *testing.T) {
+			expected := bytes.Repeat([]byte{0xab, 0xcd, 0xef}, size/3+1)[:size]
+
+			head, err := pgr.WriteOverflow(expected)
+			if err != nil {
+				t.Fatalf("Failed to write %d byte overflow value, with error %s", size, err)
+			}
+
+			actual, err := pgr.ReadOverflow(head)
+			if err != nil {
+				t.Fatalf("Failed to read overflow value at %d, with error %s", head, err)
+			}
+
+			if !bytes.Equal(expected, actual) {
+				t.Fatalf(
+					"Failed to compare overflow value: expected %d bytes, actual %d bytes",
+					len(expected), len(actual),
+				)
+			}
+
+			released := len(pgr.Freelist().Released)
+			if err := pgr.FreeOverflow(head); err != nil {
+				t.Fatalf("Failed to free overflow value at %d, with error %s", head, err)
+			}
+
+			if pages := len(pgr.Freelist().Released) - released; pages < size/psize+1 {
+				t.Fatalf(
+					"Failed to compare released overflow pages: expected at least %d, actual %d",
+					size/psize+1, pages,
+				)
+			}
+		})
+	}
+}
+
+// chainNums follows the next page numbers of the chain at head without
+// checking for cycles, stopping after limit pages.
+func chainNums(t *testing.T, pgr *data.Pager, head data.PageNum, limit int) []data.PageNum {
+	t.Helper()
+
+	var nums []data.PageNum
+	for num := head; num != 0 && len(nums) < limit; {
+		pg, err := pgr.Read(num)
+		if err != nil {
+			t.Fatalf("Failed to read chain page %d, with error %s", num, err)
+		}
+		nums = append(nums, num)
+		num = data.PageNum(binary.LittleEndian.Uint64(pg.Data[:8]))
+	}
+
+	return nums
+}
+
+func TestPager_FreeOverflow(t *testing.T) {
+	psize := data.MinPageSize
+
+	t.Run("three pages", func(t *testing.T) {
+		pgr, err := data.NewMemPager(psize)
+		if err != nil {
+			t.Fatalf("Failed to create pager, with error %s", err)
+		}
+		defer pgr.Close()
+
+		capacity := pgr.MaxLogicalPayload() - 12
+		head, err := pgr.WriteOverflow(bytes.Repeat([]byte{0xab}, 2*capacity+1))
+		if err != nil {
+			t.Fatalf("Failed to write overflow value, with error %s", err)
+		}
+
+		nums := chainNums(t, pgr, head, 10)
+		if len(nums) != 3 {
+			t.Fatalf("Failed to compare chain length: expected %d, actual %d", 3, len(nums))
+		}
+
+		if err := pgr.FreeOverflow(head); err != nil {
+			t.Fatalf("Failed to free overflow value at %d, with error %s", head, err)
+		}
+
+		for _, num := range nums {
+			if !pgr.Freelist().Contains(num) {
+				t.Fatalf("Failed to release chain page %d: freelist %+v", num, pgr.Freelist())
+			}
+		}
+		if count := pgr.Freelist().Count(); count != len(nums) {
+			t.Fatalf("Failed to compare released pages: expected %d, actual %d", len(nums), count)
+		}
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		pgr, err := data.NewMemPager(psize)
+		if err != nil {
+			t.Fatalf("Failed to create pager, with error %s", err)
+		}
+		defer pgr.Close()
+
+		capacity := pgr.MaxLogicalPayload() - 12
+		head, err := pgr.WriteOverflow(bytes.Repeat([]byte{0xab}, 2*capacity+1))
+		if err != nil {
+			t.Fatalf("Failed to write overflow value, with error %s", err)
+		}
+
+		// Point the last page of the chain back at its second one.
+		nums := chainNums(t, pgr, head, 10)
+		last, err := pgr.Read(nums[2])
+		if err != nil {
+			t.Fatalf("Failed to read chain page %d, with error %s", nums[2], err)
+		}
+		last = last.Clone()
+		binary.LittleEndian.PutUint64(last.Data[:8], uint64(nums[1]))
+		if err := pgr.Write(last); err != nil {
+			t.Fatalf("Failed to write chain page %d, with error %s", last.Num, err)
+		}
+
+		if err := pgr.FreeOverflow(head); !errors.Is(err, data.ErrCorruptChain) {
+			t.Fatalf(
+				"Failed to free cyclic chain: expected error %s, actual %v",
+				data.ErrCorruptChain, err,
+			)
+		}
+
+		if count := pgr.Freelist().Count(); count != 0 {
+			t.Fatalf("Failed to leave freelist of cyclic chain alone: %d pages released", count)
+		}
+	})
+}
+
+func TestPager_OverflowAfterClose(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	head, err := pgr.WriteOverflow([]byte("overflow"))
+	if err != nil {
+		t.Fatalf("Failed to write overflow, with error %s", err)
+	}
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	if _, err := pgr.WriteOverflow([]byte("overflow")); !errors.Is(err, data.ErrClosed) {
+		t.Fatalf("Failed to write overflow to closed pager: expected error %s, actual %v", data.ErrClosed, err)
+	}
+
+	if _, err := pgr.ReadOverflow(head); !errors.Is(err, data.ErrClosed) {
+		t.Fatalf("Failed to read overflow of closed pager: expected error %s, actual %v", data.ErrClosed, err)
+	}
+
+	if err := pgr.FreeOverflow(head); !errors.Is(err, data.ErrClosed) {
+		t.Fatalf("Failed to free overflow of closed pager: expected error %s, actual %v", data.ErrClosed, err)
+	}
+}
@@ -0,0 +1,65 @@
+package data
+
+import (
+	"fmt"
+	"iter"
+)
+
+// Pages yields every live data page from the first free block to Max-1 in page
+// order, reading each one as it is reached. Released and pooled pages and
+// the pages holding pager structures are skipped. The set of live pages is
+// taken when the iteration starts. A page that fails to read is yielded
+// with a nil page; Read returns its error.
+func (pgr *Pager) Pages() iter.Seq2[PageNum, *Page] {
+	return func(yield func(PageNum, *Page) bool) {
+		pgr.mu.RLock()
+		begin, max, free := pgr.flist.begin, pgr.flist.Max, pgr.freePages()
+		pgr.mu.RUnlock()
+
+		for num := begin; num < max; num++ {
+			if _, ok := free[num]; ok {
+				continue
+			}
+
+			pg, err := pgr.Read(num)
+			if err != nil {
+				pg = nil
+			}
+
+			if !yield(num, pg) {
+				return
+			}
+		}
+	}
+}
+
+// Iterate calls fn with every live page Pages yields, in page order, until
+// fn returns stop or an error. Pages after the one fn stopped at are not
+// read. A page that fails to read ends the iteration with its error, and
+// an error of fn is returned as is.
+func (pgr *Pager) Iterate(fn func(num PageNum, pg *Page) (stop bool, err error)) error {
+	pgr.mu.RLock()
+	begin, max, free := pgr.flist.begin, pgr.flist.Max, pgr.freePages()
+	pgr.mu.RUnlock()
+
+	for num := begin; num < max; num++ {
+		if _, ok := free[num]; ok {
+			continue
+		}
+
+		pg, err := pgr.Read(num)
+		if err != nil {
+			return fmt.Errorf("pager/iterate: %w", err)
+		}
+
+		stop, err := fn(num, pg)
+		if err != nil {
+			return err
+		}
+		if stop {
+			return nil
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package data_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_Pages(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	var nums []data.PageNum
+	for i := 0; i < 10; i++ {
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		nums = append(nums, pg.Num)
+	}
+
+	pgr.ReleasePage(nums[2])
+	pgr.ReleasePage(nums[7])
+
+	expected := slices.Concat(nums[:2], nums[3:7], nums[8:])
+
+	var actual []data.PageNum
+	for num, pg := range pgr.Pages() {
+		if pg == nil {
+			t.Fatalf("Failed to read page %d while iterating", num)
+		}
+
+		if want := fmt.Sprintf("data%d", slices.Index(nums, num)+1); string(pg.Data[:len(want)]) != want {
+			t.Fatalf(
+				"Failed to compare page %d data: expected %q, actual %q",
+				num, want, pg.Data[:len(want)],
+			)
+		}
+		actual = append(actual, num)
+	}
+
+	if !slices.Equal(expected, actual) {
+		t.Fatalf(
+			"Failed to compare live pages: expected %v, actual %v",
+			expected, actual,
+		)
+	}
+}
+
+func TestPager_Iterate(t *testing.T) {
+	be := new(recordingBackend)
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPagerFromBackend(be, psize)
+	if err != nil {
+		t.Fatalf("Failed to create pager from backend, with error %s", err)
+	}
+	defer pgr.Close()
+
+	var nums []data.PageNum
+	for i := 0; i < 10; i++ {
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		nums = append(nums, pg.Num)
+	}
+	pgr.ReleasePage(nums[1])
+
+	be.reads = nil
+
+	var visited []data.PageNum
+	err = pgr.Iterate(func(num data.PageNum, pg *data.Page) (bool, error) {
+		visited = append(visited, num)
+		return len(visited) == 3, nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to iterate pages, with error %s", err)
+	}
+
+	if expected := []data.PageNum{nums[0], nums[2], nums[3]}; !slices.Equal(expected, visited) {
+		t.Fatalf("Failed to compare visited pages: expected %v, actual %v", expected, visited)
+	}
+
+	if len(be.reads) == 0 {
+		t.Fatalf("Failed to record reads of the backend while iterating")
+	}
+	for _, off := range be.reads {
+		if num := data.PageNum(off / int64(psize)); num > nums[3] {
+			t.Fatalf("Failed to stop iteration: page %d past page %d was read", num, nums[3])
+		}
+	}
+
+	errStop := errors.New("stop")
+	visited = nil
+	err = pgr.Iterate(func(num data.PageNum, pg *data.Page) (bool, error) {
+		visited = append(visited, num)
+		return false, errStop
+	})
+	if !errors.Is(err, errStop) || len(visited) != 1 {
+		t.Fatalf(
+			"Failed to stop iteration on error: expected error %s after 1 page, actual %v after %d",
+			errStop, err, len(visited),
+		)
+	}
+}
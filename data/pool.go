@@ -0,0 +1,63 @@
+package data
+
+import "sync"
+
+// bufferPool recycles byte buffers of a single size. Buffers of any other
+// size are dropped instead of pooled, so that a page truncated or grown by
+// its caller never comes back out of Alloc.
+type bufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+func newBufferPool(size int) *bufferPool {
+	return &bufferPool{size: size}
+}
+
+// get returns a zeroed buffer of the pool size.
+func (p *bufferPool) get() []byte {
+	if b, ok := p.pool.Get().(*[]byte); ok {
+		clear(*b)
+		return *b
+	}
+
+	return make([]byte, p.size)
+}
+
+func (p *bufferPool) put(b []byte) {
+	if len(b) != p.size || cap(b) != p.size {
+		return
+	}
+
+	p.pool.Put(&b)
+}
+
+// getPage returns an empty page whose payload buffer comes from the pool
+// of the pager.
+func (pgr *Pager) getPage() *Page {
+	return &Page{Data: pgr.pages.get(), pool: pgr.pages}
+}
+
+// putPage returns the payload buffer of pg to the pool of the pager. Pages
+// that did not come from it, such as mapped ones, are left alone.
+func (pgr *Pager) putPage(pg *Page) {
+	if pg.pool != pgr.pages {
+		return
+	}
+
+	pg.Release()
+}
+
+// Release hands the payload buffer of a page taken from Alloc or Read back
+// to the pager for reuse, which saves an allocation per page in hot loops.
+// Neither the page nor any page sharing its Data may be used afterwards,
+// and only one of the pages sharing Data may be released. Release does
+// nothing for pages that did not come from a pager's pool.
+func (pg *Page) Release() {
+	if pg.pool != nil {
+		pg.pool.put(pg.Data)
+	}
+
+	pg.Data, pg.pool = nil, nil
+	pg.summed = false
+}
@@ -0,0 +1,90 @@
+package data_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPage_Release(t *testing.T) {
+	pgr, err := data.NewMemPager(512)
+	if err != nil {
+		t.Fatalf("Failed to create in-memory pager, with error %s", err)
+	}
+	defer pgr.Close()
+
+	size := len(pgr.Alloc().Data)
+
+	pg := pgr.Alloc()
+	pg.Write([]byte("data"))
+	pg.Release()
+	if pg.Data != nil {
+		t.Fatalf("Failed to drop data of released page: %+v", pg)
+	}
+	pg.Release()
+
+	// Pages cut short or grown by their callers must not come back out of
+	// Alloc.
+	short := pgr.Alloc()
+	short.Data = short.Data[:size/2]
+	short.Release()
+
+	grown := pgr.Alloc()
+	grown.Data = append(grown.Data, 1)
+	grown.Release()
+
+	(&data.Page{Data: make([]byte, size*2)}).Release()
+
+	for i := 0; i < 10; i++ {
+		pg := pgr.Alloc()
+		if len(pg.Data) != size || cap(pg.Data) != size {
+			t.Fatalf("Failed to compare size of allocated page: expected %d, actual %d (cap %d)", size, len(pg.Data), cap(pg.Data))
+		}
+		if !bytes.Equal(pg.Data, make([]byte, size)) {
+			t.Fatalf("Failed to zero reused page: %q", bytes.TrimRight(pg.Data, "\x00"))
+		}
+	}
+}
+
+func BenchmarkPager_ReadRelease(b *testing.B) {
+	filename := filepath.Join(b.TempDir(), "bench_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		b.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	pages := make([]*data.Page, 100)
+	for i := range pages {
+		pages[i] = pgr.Alloc().WithNum(nextFree(b, pgr.Freelist()))
+		pages[i].Write([]byte(fmt.Sprintf("data%d", i+1)))
+	}
+	if err := pgr.WriteBatch(pages); err != nil {
+		b.Fatalf("Failed to write pages, with error %s", err)
+	}
+
+	for name, release := range map[string]bool{"keep": false, "release": true} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				num := pages[i%len(pages)].Num
+				pg, err := pgr.Read(num)
+				if err != nil {
+					b.Fatalf("Failed to read page %d, with error %s", num, err)
+				}
+
+				if release {
+					pg.Release()
+				}
+			}
+		})
+	}
+}
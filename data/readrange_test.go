@@ -0,0 +1,111 @@
+package data_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_ReadRange(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPagerWithOptions(filename, os.Getpagesize(), data.WithBufferedWrites(true))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	pages := make([]*data.Page, 8)
+	for i := range pages {
+		pages[i] = pgr.Alloc().WithNum(nextPage(t, pgr))
+		pages[i].Write([]byte(fmt.Sprintf("data%d", i+1)))
+	}
+	if err := pgr.WriteBatch(pages[:6]); err != nil {
+		t.Fatalf("Failed to write pages, with error %s", err)
+	}
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	// The last pages are only buffered and must be served from memory.
+	if err := pgr.WriteBatch(pages[6:]); err != nil {
+		t.Fatalf("Failed to write buffered pages, with error %s", err)
+	}
+
+	actual, err := pgr.ReadRange(pages[0].Num, len(pages))
+	if err != nil {
+		t.Fatalf("Failed to read range of %d pages, with error %s", len(pages), err)
+	}
+
+	if len(actual) != len(pages) {
+		t.Fatalf("Failed to compare range length: expected %d, actual %d", len(pages), len(actual))
+	}
+	for i, pg := range actual {
+		if pg.Num != pages[i].Num || !bytes.Equal(pg.Data, pages[i].Data) {
+			t.Fatalf(
+				"Failed to compare page %d of range: expected %d %q, actual %d %q",
+				i, pages[i].Num, bytes.TrimRight(pages[i].Data, "\x00"),
+				pg.Num, bytes.TrimRight(pg.Data, "\x00"),
+			)
+		}
+	}
+
+	if _, err := pgr.ReadRange(pages[1].Num, len(pages)); !errors.Is(err, data.ErrPageOutOfRange) {
+		t.Fatalf(
+			"Failed to read range past max: expected error %s, actual %v",
+			data.ErrPageOutOfRange, err,
+		)
+	}
+}
+
+func BenchmarkPager_ReadRange(b *testing.B) {
+	filename := filepath.Join(b.TempDir(), "bench_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		b.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	pages := make([]*data.Page, 64)
+	for i := range pages {
+		pages[i] = pgr.Alloc().WithNum(nextFree(b, pgr.Freelist()))
+		pages[i].Write([]byte(fmt.Sprintf("data%d", i+1)))
+	}
+	if err := pgr.WriteBatch(pages); err != nil {
+		b.Fatalf("Failed to write pages, with error %s", err)
+	}
+
+	start := pages[0].Num
+
+	b.Run("Read", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, pg := range pages {
+				if _, err := pgr.Read(pg.Num); err != nil {
+					b.Fatalf("Failed to read page %d, with error %s", pg.Num, err)
+				}
+			}
+		}
+	})
+
+	b.Run("ReadRange", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := pgr.ReadRange(start, len(pages)); err != nil {
+				b.Fatalf("Failed to read range, with error %s", err)
+			}
+		}
+	})
+}
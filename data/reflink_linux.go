@@ -0,0 +1,28 @@
+//go:build linux
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflink clones src into dst with FICLONE. Filesystems that cannot clone,
+// files on different filesystems, and files that take no ioctl at all or
+// reject FICLONE as an invalid request report errReflinkUnsupported so the
+// caller falls back to copying the pages.
+func reflink(dst, src *os.File) error {
+	err := unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, unix.EOPNOTSUPP), errors.Is(err, unix.EXDEV),
+		errors.Is(err, unix.ENOTTY), errors.Is(err, unix.EINVAL):
+		return fmt.Errorf("%w: %w", errReflinkUnsupported, err)
+	default:
+		return fmt.Errorf("reflink: %w", err)
+	}
+}
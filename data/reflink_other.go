@@ -0,0 +1,11 @@
+//go:build !linux
+
+package data
+
+import (
+	"os"
+)
+
+func reflink(_, _ *os.File) error {
+	return errReflinkUnsupported
+}
@@ -0,0 +1,141 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Reindex rebuilds the freelist, the class pools and the stable id table
+// from the pages that are actually present in the file, then flushes them.
+// It is meant to be run after raw pages were written behind the pager's
+// back. Every page from the start of the free blocks to the end of the
+// file is scanned by its page type tag: data and overflow pages that pass
+// their checksum are live, while pages that were never written, fail
+// their checksum or hold a stale meta or freelist are free. Max ends past
+// the last live page, so preallocated and garbage pages at the tail do
+// not count. Released entries stay released, and ids resolving to pages
+// that are not live are dropped.
+func (pgr *Pager) Reindex() error {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.closed {
+		return fmt.Errorf("pager/reindex: %w", ErrClosed)
+	}
+
+	if pgr.opts.ReadOnly {
+		return fmt.Errorf("pager/reindex: %w", ErrReadOnly)
+	}
+
+	fileSize, err := pgr.storeSize()
+	if err != nil {
+		return fmt.Errorf("pager/reindex: %w", err)
+	}
+	filePages := PageNum((fileSize + int64(pgr.psize) - 1) / int64(pgr.psize))
+
+	// Chain pages of the pager's structures and pages set aside for
+	// readers or size classes keep their role, they only bound Max.
+	held := make(map[PageNum]struct{})
+	for _, num := range slices.Concat(pgr.flistChain.pages(), pgr.classChain.pages(), pgr.idChain.pages()) {
+		held[num] = struct{}{}
+	}
+	for _, nums := range pgr.flist.Pending {
+		for _, num := range nums {
+			held[num] = struct{}{}
+		}
+	}
+	for _, pool := range pgr.classes.Pools {
+		for _, num := range pool {
+			held[num] = struct{}{}
+		}
+	}
+
+	released := make(map[PageNum]struct{})
+	free := make([]PageNum, 0, len(pgr.flist.Released))
+	for _, num := range pgr.flist.Released {
+		if _, ok := held[num]; ok || num < pgr.flist.begin || num >= filePages {
+			continue
+		}
+		if _, ok := released[num]; ok {
+			continue
+		}
+
+		released[num] = struct{}{}
+		free = append(free, num)
+	}
+
+	end := pgr.flist.begin
+	live := make(map[PageNum]struct{})
+	for num := pgr.flist.begin; num < filePages; num++ {
+		if _, ok := held[num]; ok {
+			end = num + 1
+			continue
+		}
+		if _, ok := released[num]; ok {
+			continue
+		}
+
+		ok, err := pgr.isLive(num)
+		if err != nil {
+			return fmt.Errorf("pager/reindex: %w", err)
+		}
+		if !ok {
+			free = append(free, num)
+			continue
+		}
+
+		live[num] = struct{}{}
+		end = num + 1
+	}
+
+	pgr.flist.Max = end
+	pgr.flist.Released = slices.DeleteFunc(free, func(num PageNum) bool { return num >= end })
+	pgr.flist.setStrategy(pgr.flist.strategy)
+	pgr.flist.set(flistDirty, true)
+
+	for id, num := range pgr.ids.Entries {
+		if _, ok := live[num]; !ok {
+			delete(pgr.ids.Entries, id)
+		}
+	}
+	pgr.changed = true
+
+	if err := pgr.recomputeStateSum(); err != nil {
+		return fmt.Errorf("pager/reindex: %w", err)
+	}
+
+	if err := pgr.flush(context.Background()); err != nil {
+		return fmt.Errorf("pager/reindex: %w", err)
+	}
+
+	return nil
+}
+
+// isLive reports whether page num holds data by its page type tag: it was
+// written, passes its checksum and is tagged as a data or overflow page.
+// A page cut short by the end of the file is not live.
+func (pgr *Pager) isLive(num PageNum) (bool, error) {
+	b := make([]byte, pgr.psize)
+
+	n, err := pgr.store.ReadAt(b, int64(num)*int64(pgr.psize))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("read page %d: %w", num, err)
+	}
+	if n < len(b) {
+		return false, nil
+	}
+
+	if isZero(b) || pgr.openPage(num, b, make([]byte, pgr.payloadSize())) != nil {
+		return false, nil
+	}
+
+	switch pgr.storedType(num, b) {
+	case PageTypeData, PageTypeOverflow:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,160 @@
+package data_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_Reindex(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	// Raw imports bypass the pager, so they carry no page checksums.
+	pgr, err := data.NewPagerWithOptions(filename, psize, data.WithChecksum(nil))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	for i := 0; i < 3; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		pg.Write([]byte("data"))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+	}
+
+	released := pgr.Freelist().Max - 1
+	pgr.Freelist().Release(released)
+	pgr.Freelist().Release(released)
+
+	// Import raw pages past the known high-water mark.
+	imported := pgr.Freelist().Max + 2
+	f, err := os.OpenFile(filename, os.O_RDWR, data.DefaultFilePerm)
+	if err != nil {
+		t.Fatalf("Failed to open file %s, with error %s", filename, err)
+	}
+	// Leave the 5 byte page header empty, checksums are disabled.
+	rawpg := make([]byte, psize)
+	copy(rawpg[5:], "imported")
+	if _, err := f.WriteAt(rawpg, int64(imported)*int64(psize)); err != nil {
+		t.Fatalf("Failed to import raw page %d, with error %s", imported, err)
+	}
+	_ = f.Close()
+
+	if err := pgr.Reindex(); err != nil {
+		t.Fatalf("Failed to reindex pager, with error %s", err)
+	}
+
+	if pgr.Freelist().Max != imported+1 {
+		t.Fatalf(
+			"Failed to compare freelist max: expected %d, actual %d",
+			imported+1, pgr.Freelist().Max,
+		)
+	}
+
+	// The pages between the old high-water mark and the import were never
+	// written, so they are free.
+	if expected := []data.PageNum{released, imported - 2, imported - 1}; !slices.Equal(pgr.Freelist().Released, expected) {
+		t.Fatalf(
+			"Failed to compare released pages: expected %v, actual %v",
+			expected, pgr.Freelist().Released,
+		)
+	}
+
+	pg, err := pgr.Read(imported)
+	if err != nil {
+		t.Fatalf("Failed to read page %d, with error %s", imported, err)
+	}
+
+	if actualPgData := string(bytes.TrimRight(pg.Data, "\x00")); actualPgData != "imported" {
+		t.Fatalf(
+			"Failed to compare page data: expected %s, actual %s",
+			"imported", actualPgData,
+		)
+	}
+
+	reopened, err := data.NewPagerWithOptions(filename, psize, data.WithChecksum(nil), data.WithFileLock(false))
+	if err != nil {
+		t.Fatalf(
+			"Failed to open pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer reopened.Close()
+
+	if !pgr.Freelist().Equal(reopened.Freelist()) {
+		t.Fatalf(
+			"Failed to compare freelists: expected %+v, actual %+v",
+			pgr.Freelist(), reopened.Freelist(),
+		)
+	}
+}
+
+func TestPager_ReindexTail(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := data.MinPageSize
+
+	pgr, err := data.NewPagerWithOptions(filename, psize, data.WithGrowChunk(8))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	var last data.PageNum
+	for i := 0; i < 3; i++ {
+		if last, err = pgr.Append([]byte("data")); err != nil {
+			t.Fatalf("Failed to append page, with error %s", err)
+		}
+	}
+	// Two flushes of the id table give it a chain for each meta slot, so
+	// the flush of Reindex allocates no page for it.
+	var id data.StableID
+	for i := 0; i < 2; i++ {
+		id = pgr.AssignID(last)
+		if _, err := pgr.Flush(); err != nil {
+			t.Fatalf("Failed to flush pager, with error %s", err)
+		}
+	}
+	max := pgr.Freelist().Max
+
+	// Garbage behind the preallocated pages, as a torn raw import leaves.
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("Failed to stat file %s, with error %s", filename, err)
+	}
+	f, err := os.OpenFile(filename, os.O_RDWR, data.DefaultFilePerm)
+	if err != nil {
+		t.Fatalf("Failed to open file %s, with error %s", filename, err)
+	}
+	if _, err := f.WriteAt(bytes.Repeat([]byte{0xde, 0xad}, psize), info.Size()); err != nil {
+		t.Fatalf("Failed to append garbage, with error %s", err)
+	}
+	_ = f.Close()
+
+	if err := pgr.Reindex(); err != nil {
+		t.Fatalf("Failed to reindex pager, with error %s", err)
+	}
+
+	if pgr.Freelist().Max != max || len(pgr.Freelist().Released) != 0 {
+		t.Fatalf(
+			"Failed to compare freelist: expected max %d and no released pages, actual %+v",
+			max, pgr.Freelist(),
+		)
+	}
+	if num, err := pgr.Resolve(id); err != nil || num != last {
+		t.Fatalf("Failed to resolve id %d: expected page %d, actual %d, with error %v", id, last, num, err)
+	}
+}
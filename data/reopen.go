@@ -0,0 +1,48 @@
+package data
+
+import (
+	"fmt"
+)
+
+// Reopen reloads the metainfo and the freelist from the file into the
+// pager, for a long-lived pager whose file was changed by another process
+// or a repair tool. Whatever was not flushed is dropped. When the file
+// now holds another page size Reopen returns ErrPageSizeMismatch and
+// leaves the pager as it was.
+func (pgr *Pager) Reopen() error {
+	pgr.commitMu.Lock()
+	defer pgr.commitMu.Unlock()
+
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.closed {
+		return fmt.Errorf("pager/reopen: %w", ErrClosed)
+	}
+
+	if pgr.tx != nil {
+		return fmt.Errorf("pager/reopen: %w", ErrTxInProgress)
+	}
+
+	if m, ok := pgr.store.(*mmapBackend); ok {
+		if err := m.refresh(); err != nil {
+			return fmt.Errorf("pager/reopen: %w", err)
+		}
+	}
+
+	if _, err := pgr.latestMeta(); err != nil {
+		return fmt.Errorf("pager/reopen: %w", err)
+	}
+
+	clear(pgr.dirty)
+	clear(pgr.scrub)
+	pgr.cache.reset()
+
+	if err := pgr.recovery(); err != nil {
+		return fmt.Errorf("pager/reopen: %w", err)
+	}
+	pgr.flist.setStrategy(pgr.opts.AllocStrategy)
+	pgr.flushed, pgr.changed = pgr.meta.Clone(), false
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package data_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_Reopen(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := 2 * data.MinPageSize
+
+	writer, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer writer.Close()
+
+	// The second pager stands in for another process, which the lock of
+	// the first would keep out.
+	reader, err := data.NewPagerWithOptions(filename, psize, data.WithFileLock(false))
+	if err != nil {
+		t.Fatalf(
+			"Failed to open second pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer reader.Close()
+
+	pages := make([]*data.Page, 3)
+	for i := range pages {
+		pages[i] = writer.Alloc().WithNum(nextPage(t, writer))
+		pages[i].Write([]byte(fmt.Sprintf("data%d", i+1)))
+	}
+	if err := writer.WriteBatch(pages); err != nil {
+		t.Fatalf("Failed to write pages, with error %s", err)
+	}
+	if _, err := writer.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	if reader.Freelist().Max == writer.Freelist().Max {
+		t.Fatalf("Failed to set up stale pager: max %d already up to date", reader.Freelist().Max)
+	}
+
+	if err := reader.Reopen(); err != nil {
+		t.Fatalf("Failed to reopen pager, with error %s", err)
+	}
+
+	if reader.Freelist().Max != writer.Freelist().Max {
+		t.Fatalf(
+			"Failed to compare freelist max after reopen: expected %d, actual %d",
+			writer.Freelist().Max, reader.Freelist().Max,
+		)
+	}
+	if !reader.Meta().Equal(writer.Meta()) {
+		t.Fatalf("Failed to compare metainfo after reopen: expected %+v, actual %+v", writer.Meta(), reader.Meta())
+	}
+
+	for _, pg := range pages {
+		actual, err := reader.Read(pg.Num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d after reopen, with error %s", pg.Num, err)
+		}
+		if !bytes.Equal(actual.Data, pg.Data) {
+			t.Fatalf("Failed to compare page %d data after reopen", pg.Num)
+		}
+	}
+
+	t.Run("page size changed", func(t *testing.T) {
+		other := filepath.Join(t.TempDir(), "test_data")
+		opgr, err := data.NewPager(other, 2*psize)
+		if err != nil {
+			t.Fatalf(
+				"Failed to create pager by path %s, with error %s",
+				other, err,
+			)
+		}
+		if err := opgr.Close(); err != nil {
+			t.Fatalf("Failed to close pager, with error %s", err)
+		}
+
+		b, err := os.ReadFile(other)
+		if err != nil {
+			t.Fatalf("Failed to read file %s, with error %s", other, err)
+		}
+		if err := os.WriteFile(filename, b, data.DefaultFilePerm); err != nil {
+			t.Fatalf("Failed to write file %s, with error %s", filename, err)
+		}
+
+		max := reader.Freelist().Max
+		if err := reader.Reopen(); !errors.Is(err, data.ErrPageSizeMismatch) {
+			t.Fatalf(
+				"Failed to reopen file of another page size: expected error %s, actual %v",
+				data.ErrPageSizeMismatch, err,
+			)
+		}
+
+		if reader.Freelist().Max != max {
+			t.Fatalf("Failed to compare freelist max after failed reopen: expected %d, actual %d", max, reader.Freelist().Max)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package data
+
+import (
+	"fmt"
+)
+
+// CorruptionPolicy decides how Recovery handles a corrupt freelist.
+type CorruptionPolicy uint8
+
+const (
+	// PolicyFail makes opening the store fail with the error of the
+	// freelist.
+	PolicyFail CorruptionPolicy = iota
+	// PolicyRepair rebuilds the freelist as NewPagerRepair does and keeps
+	// the store writable. The rebuilt freelist is written by the next
+	// flush.
+	PolicyRepair
+	// PolicyReadOnly rebuilds the freelist in memory only and opens the
+	// store read-only, so that its pages can still be read and copied
+	// without the file being changed. The file stays locked as for a
+	// read-write open.
+	PolicyReadOnly
+)
+
+// NewPagerRepair opens an existing file whose freelist may be lost or
+// corrupt. When the freelist fails to load, it is rebuilt conservatively:
+// nothing is released and Max covers every page in the file, so no live
+// page is handed out again at the cost of leaking the free ones. The
+// rebuilt freelist is flushed before the pager is returned.
+func NewPagerRepair(path string, psize int, opts ...PagerOption) (*Pager, error) {
+	opts = append(opts, WithCorruptionPolicy(PolicyRepair))
+
+	pgr, err := NewPagerWithOptions(path, psize, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("pager/repair: %w", err)
+	}
+
+	if pgr.opts.ReadOnly || !pgr.flist.has(flistDirty) {
+		return pgr, nil
+	}
+
+	if _, err := pgr.Flush(); err != nil {
+		_ = pgr.Close()
+		return nil, fmt.Errorf("pager/repair: %w", err)
+	}
+
+	return pgr, nil
+}
+
+// corruptFreelist applies the corruption policy to a freelist that failed
+// to load with cause.
+func (pgr *Pager) corruptFreelist(cause error) error {
+	switch pgr.opts.CorruptionPolicy {
+	case PolicyRepair:
+		pgr.log.Warn("rebuilding corrupt freelist", "err", cause)
+		return pgr.repairFreelist()
+
+	case PolicyReadOnly:
+		pgr.log.Warn("opening read-only with corrupt freelist", "err", cause)
+		if err := pgr.repairFreelist(); err != nil {
+			return err
+		}
+		pgr.flist.set(flistDirty, false)
+		pgr.flist.set(flistReadOnly, true)
+		pgr.opts.ReadOnly = true
+		return nil
+
+	default:
+		return cause
+	}
+}
+
+func (pgr *Pager) repairFreelist() error {
+	fileSize, err := pgr.storeSize()
+	if err != nil {
+		return fmt.Errorf("repair freelist: %w", err)
+	}
+
+	filePages := PageNum((fileSize + int64(pgr.psize) - 1) / int64(pgr.psize))
+
+	pgr.flist.Max = max(filePages, pgr.flist.begin)
+	pgr.flist.Released = make([]PageNum, 0)
+	pgr.flist.set(flistDirty, true)
+	pgr.resetFlistChain()
+
+	return nil
+}
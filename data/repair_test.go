@@ -0,0 +1,245 @@
+package data_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestNewPagerRepair(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	var pages []*data.Page
+	for i := 0; i < 5; i++ {
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		pages = append(pages, pg)
+	}
+	pgr.ReleasePage(pages[2].Num)
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+	max := pgr.Freelist().Max
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	f, err := os.OpenFile(filename, os.O_RDWR, data.DefaultFilePerm)
+	if err != nil {
+		t.Fatalf("Failed to open file %s, with error %s", filename, err)
+	}
+	if _, err := f.WriteAt(make([]byte, psize), int64(pgr.Meta().Freelist)*int64(psize)); err != nil {
+		t.Fatalf("Failed to zero freelist page, with error %s", err)
+	}
+	_ = f.Close()
+
+	if _, err := data.NewPager(filename, psize); err == nil {
+		t.Fatalf("Failed to open pager with a zeroed freelist: expected error, actual nil")
+	}
+
+	pgr, err = data.NewPagerRepair(filename, psize)
+	if err != nil {
+		t.Fatalf("Failed to repair pager by path %s, with error %s", filename, err)
+	}
+
+	if pgr.Freelist().Max != max || pgr.Freelist().Count() != 0 {
+		t.Fatalf(
+			"Failed to check repaired freelist: expected max %d and no released pages, actual %+v",
+			max, pgr.Freelist(),
+		)
+	}
+
+	for _, expectedPg := range pages {
+		actualPg, err := pgr.Read(expectedPg.Num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", expectedPg.Num, err)
+		}
+
+		if !bytes.Equal(expectedPg.Data, actualPg.Data) {
+			t.Fatalf("Failed to compare page %d data after repair", expectedPg.Num)
+		}
+	}
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	pgr, err = data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf("Failed to reopen repaired pager by path %s, with error %s", filename, err)
+	}
+	defer pgr.Close()
+
+	if pgr.Freelist().Max != max {
+		t.Fatalf(
+			"Failed to compare freelist max after reopen: expected %d, actual %d",
+			max, pgr.Freelist().Max,
+		)
+	}
+}
+
+func TestPager_CorruptionPolicy(t *testing.T) {
+	psize := data.MinPageSize
+
+	// corrupt writes a store of a few pages and zeroes its freelist page.
+	corrupt := func(t *testing.T) (string, []*data.Page, data.PageNum) {
+		t.Helper()
+
+		filename := filepath.Join(t.TempDir(), "test_data")
+
+		pgr, err := data.NewPager(filename, psize)
+		if err != nil {
+			t.Fatalf(
+				"Failed to create pager by path %s, with error %s",
+				filename, err,
+			)
+		}
+
+		var pages []*data.Page
+		for i := 0; i < 5; i++ {
+			pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+			pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+			if err := pgr.Write(pg); err != nil {
+				t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+			}
+			pages = append(pages, pg)
+		}
+		max := pgr.Freelist().Max
+
+		if err := pgr.Close(); err != nil {
+			t.Fatalf("Failed to close pager, with error %s", err)
+		}
+
+		f, err := os.OpenFile(filename, os.O_RDWR, data.DefaultFilePerm)
+		if err != nil {
+			t.Fatalf("Failed to open file %s, with error %s", filename, err)
+		}
+		if _, err := f.WriteAt(make([]byte, psize), int64(pgr.Meta().Freelist)*int64(psize)); err != nil {
+			t.Fatalf("Failed to zero freelist page, with error %s", err)
+		}
+		_ = f.Close()
+
+		return filename, pages, max
+	}
+
+	readAll := func(t *testing.T, pgr *data.Pager, pages []*data.Page) {
+		t.Helper()
+
+		for _, expectedPg := range pages {
+			actualPg, err := pgr.Read(expectedPg.Num)
+			if err != nil {
+				t.Fatalf("Failed to read page %d, with error %s", expectedPg.Num, err)
+			}
+			if !bytes.Equal(expectedPg.Data, actualPg.Data) {
+				t.Fatalf("Failed to compare page %d data", expectedPg.Num)
+			}
+		}
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		filename, _, _ := corrupt(t)
+
+		if _, err := data.NewPagerWithOptions(
+			filename, psize, data.WithCorruptionPolicy(data.PolicyFail),
+		); err == nil {
+			t.Fatalf("Failed to open pager with a zeroed freelist: expected error, actual nil")
+		}
+	})
+
+	t.Run("repair", func(t *testing.T) {
+		filename, pages, max := corrupt(t)
+
+		pgr, err := data.NewPagerWithOptions(filename, psize, data.WithCorruptionPolicy(data.PolicyRepair))
+		if err != nil {
+			t.Fatalf("Failed to open pager by path %s, with error %s", filename, err)
+		}
+
+		if pgr.Freelist().Max != max || pgr.Freelist().Count() != 0 {
+			t.Fatalf(
+				"Failed to check repaired freelist: expected max %d and no released pages, actual %+v",
+				max, pgr.Freelist(),
+			)
+		}
+		readAll(t, pgr, pages)
+
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write repaired pager, with error %s", err)
+		}
+		if err := pgr.Close(); err != nil {
+			t.Fatalf("Failed to close pager, with error %s", err)
+		}
+
+		reopened, err := data.NewPager(filename, psize)
+		if err != nil {
+			t.Fatalf("Failed to reopen repaired pager by path %s, with error %s", filename, err)
+		}
+		defer reopened.Close()
+
+		if reopened.Freelist().Max != max+1 {
+			t.Fatalf(
+				"Failed to compare freelist max after reopen: expected %d, actual %d",
+				max+1, reopened.Freelist().Max,
+			)
+		}
+	})
+
+	t.Run("read-only", func(t *testing.T) {
+		filename, pages, _ := corrupt(t)
+
+		before, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("Failed to read file %s, with error %s", filename, err)
+		}
+
+		pgr, err := data.NewPagerWithOptions(filename, psize, data.WithCorruptionPolicy(data.PolicyReadOnly))
+		if err != nil {
+			t.Fatalf("Failed to open pager by path %s, with error %s", filename, err)
+		}
+		readAll(t, pgr, pages)
+
+		if err := pgr.CopyTo(io.Discard); err != nil {
+			t.Fatalf("Failed to copy degraded pager, with error %s", err)
+		}
+
+		if err := pgr.Write(pgr.Alloc().WithNum(pages[0].Num)); !errors.Is(err, data.ErrReadOnly) {
+			t.Fatalf("Failed to write degraded pager: expected error %s, actual %v", data.ErrReadOnly, err)
+		}
+		if _, err := pgr.Flush(); !errors.Is(err, data.ErrReadOnly) {
+			t.Fatalf("Failed to flush degraded pager: expected error %s, actual %v", data.ErrReadOnly, err)
+		}
+		if err := pgr.Close(); err != nil {
+			t.Fatalf("Failed to close pager, with error %s", err)
+		}
+
+		after, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("Failed to read file %s, with error %s", filename, err)
+		}
+		if !bytes.Equal(before, after) {
+			t.Fatalf("Failed to leave file of degraded pager unchanged")
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package data
+
+import (
+	"fmt"
+)
+
+// onAllocEvent tracks the pages to scrub before emitting the event.
+func (pgr *Pager) onAllocEvent(op AllocOp, num PageNum) {
+	if pgr.opts.ZeroOnRelease {
+		switch op {
+		case OpRelease:
+			pgr.scrub[num] = struct{}{}
+		case OpAlloc:
+			delete(pgr.scrub, num)
+		}
+	}
+
+	if op == OpAlloc && pgr.opts.GrowChunk > 0 {
+		pgr.growFor(num)
+	}
+
+	pgr.emitAllocEvent(op, num)
+}
+
+// scrubReleased zeroes the pages released since the last flush that are
+// still free. Pages reclaimed otherwise, such as by a rollback or a
+// compaction, hold live data again and are left alone.
+func (pgr *Pager) scrubReleased() error {
+	if len(pgr.scrub) == 0 {
+		return nil
+	}
+
+	free := make(map[PageNum]struct{}, len(pgr.flist.Released))
+	for _, num := range pgr.flist.Released {
+		free[num] = struct{}{}
+	}
+	for _, pool := range pgr.classes.Pools {
+		for _, num := range pool {
+			free[num] = struct{}{}
+		}
+	}
+
+	zero := make([]byte, pgr.psize)
+	for num := range pgr.scrub {
+		if _, ok := free[num]; ok && num < pgr.flist.Max {
+			if err := pgr.writeSealed(num, zero); err != nil {
+				return fmt.Errorf("scrub page %d: %w", num, err)
+			}
+		}
+		delete(pgr.scrub, num)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package data_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_ZeroOnRelease(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPagerWithOptions(filename, psize, data.WithZeroOnRelease(true))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	var pages []*data.Page
+	for i := 0; i < 3; i++ {
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		pg.Write(bytes.Repeat([]byte("secret"), 16))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		pages = append(pages, pg)
+	}
+
+	released, kept := pages[1], pages[2]
+	pgr.ReleasePage(released.Num)
+	pgr.ReleasePage(kept.Num)
+
+	// A page handed out again before the flush keeps its new contents.
+	if num := nextPage(t, pgr); num != kept.Num {
+		t.Fatalf("Failed to compare reused page: expected %d, actual %d", kept.Num, num)
+	}
+	if err := pgr.Write(kept); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", kept, err)
+	}
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file %s, with error %s", filename, err)
+	}
+
+	page := func(num data.PageNum) []byte {
+		return b[int(num)*psize : int(num+1)*psize]
+	}
+
+	if !bytes.Equal(page(released.Num), make([]byte, psize)) {
+		t.Fatalf("Failed to check released page %d is zeroed", released.Num)
+	}
+
+	for _, pg := range []*data.Page{pages[0], kept} {
+		if !bytes.Contains(page(pg.Num), []byte("secret")) {
+			t.Fatalf("Failed to check live page %d keeps its data", pg.Num)
+		}
+	}
+}
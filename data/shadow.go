@@ -0,0 +1,169 @@
+package data
+
+import (
+	"slices"
+)
+
+// shadowChain tracks the pages of a chain holding a pager structure, such
+// as the freelist, for both meta slots. A flush never writes over the
+// chain the latest metainfo references: it writes the structure to the
+// chain of the metainfo in the other slot, the one it is about to
+// overwrite, so that a recovery falling back to the latest metainfo after
+// a torn flush still finds its structures intact.
+type shadowChain struct {
+	// cur is the chain the latest metainfo references, head first.
+	cur []PageNum
+	// spare holds the pages of the chain the metainfo in the other slot
+	// references that cur does not share.
+	spare []PageNum
+	// next is the chain written by the flush in progress. It becomes cur
+	// once the metainfo referencing it is written.
+	next    []PageNum
+	written bool
+}
+
+// target returns the pages the chain is to be written to: the spare
+// pages, or the ones a failed flush already wrote it to.
+func (sc *shadowChain) target() []PageNum {
+	if sc.written {
+		return slices.Clone(sc.next)
+	}
+	return slices.Clone(sc.spare)
+}
+
+// wrote records the pages the chain was written to by the flush in
+// progress.
+func (sc *shadowChain) wrote(nums []PageNum) {
+	sc.next, sc.written = nums, true
+}
+
+// commit makes the chain written by the flush cur, once its metainfo is
+// written. The old cur is now referenced by the metainfo in the other slot
+// only, and is what the next flush writes to.
+func (sc *shadowChain) commit() {
+	if !sc.written {
+		return
+	}
+
+	sc.spare, sc.cur = sc.cur, sc.next
+	sc.next, sc.written = nil, false
+}
+
+// dropSpare forgets the spare pages from begin on and returns them, for
+// the caller to free. Pages below begin, reserved heads, stay spares.
+func (sc *shadowChain) dropSpare(begin PageNum) []PageNum {
+	var dropped []PageNum
+	sc.spare = slices.DeleteFunc(sc.spare, func(num PageNum) bool {
+		if num < begin {
+			return false
+		}
+		dropped = append(dropped, num)
+		return true
+	})
+	return dropped
+}
+
+// pages returns every page the chain occupies.
+func (sc *shadowChain) pages() []PageNum {
+	return slices.Concat(sc.cur, sc.spare, sc.next)
+}
+
+// overflow returns the pages of cur past its head.
+func (sc *shadowChain) overflow() []PageNum {
+	if len(sc.cur) == 0 {
+		return nil
+	}
+	return sc.cur[1:]
+}
+
+// commitChains commits the chains of every structure once the metainfo of
+// the flush is written.
+func (pgr *Pager) commitChains() {
+	pgr.flistChain.commit()
+	pgr.classChain.commit()
+	pgr.idChain.commit()
+}
+
+// recoverSpares finds the chains the metainfo in the other slot references,
+// so that the next flush writes to them rather than leak them. Pages that
+// are free, belong to the chains of the latest metainfo or lie outside the
+// store are left out, and so is everything when the other slot does not
+// hold the metainfo that preceded the latest one. A reserved freelist page
+// the latest freelist does not use heads the spare freelist chain.
+func (pgr *Pager) recoverSpares() {
+	pgr.flistChain.spare = nil
+	pgr.classChain.spare = nil
+	pgr.idChain.spare = nil
+
+	taken := pgr.freePages()
+	defer func() {
+		for _, head := range pgr.flistHeads() {
+			if _, ok := taken[head]; !ok && !slices.Contains(pgr.flistChain.spare, head) {
+				pgr.flistChain.spare = slices.Insert(pgr.flistChain.spare, 0, head)
+				break
+			}
+		}
+	}()
+
+	prev, err := pgr.readMetaSlot(metaSlot(pgr.meta.TxID + 1))
+	if err != nil || prev.TxID >= pgr.meta.TxID {
+		pgr.log.Debug("no previous metainfo for spare chains", "err", err)
+		return
+	}
+
+	spare := func(head PageNum) []PageNum {
+		if head == 0 {
+			return nil
+		}
+
+		_, nums, err := pgr.readChain(head)
+		if err != nil {
+			pgr.log.Debug("skipped spare chain", "head", head, "err", err)
+			return nil
+		}
+
+		var kept []PageNum
+		for _, num := range nums {
+			if _, ok := taken[num]; ok || num >= pgr.flist.Max {
+				continue
+			}
+			if num < pgr.flist.begin && !slices.Contains(pgr.flistHeads(), num) {
+				continue
+			}
+
+			taken[num] = struct{}{}
+			kept = append(kept, num)
+		}
+		return kept
+	}
+
+	pgr.flistChain.spare = spare(prev.Freelist)
+	pgr.classChain.spare = spare(prev.Classes)
+	pgr.idChain.spare = spare(prev.IDs)
+}
+
+// flistHeads returns the reserved pages freelist chains start at: the
+// freelist page and, in stores whose reserved region holds it, the shadow
+// freelist page.
+func (pgr *Pager) flistHeads() []PageNum {
+	if pgr.flist.begin > ShadowFlistPage {
+		return []PageNum{DefaultFlistPage, ShadowFlistPage}
+	}
+	return []PageNum{DefaultFlistPage}
+}
+
+// resetFlistChain drops the freelist chains, the pages of which the caller
+// has accounted for, and starts over from the reserved freelist pages. The
+// next flush writes the freelist to one the latest metainfo does not
+// reference.
+func (pgr *Pager) resetFlistChain() {
+	pgr.flistChain = shadowChain{}
+	for _, head := range pgr.flistHeads() {
+		switch {
+		case head == pgr.meta.Freelist:
+			pgr.flistChain.cur = []PageNum{head}
+		case pgr.flistChain.spare == nil:
+			pgr.flistChain.spare = []PageNum{head}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+var errReflinkUnsupported = errors.New("reflink unsupported")
+
+// SnapshotTo flushes pending changes and writes a copy of the store to
+// path. A read-only pager is copied as the file stands.
+// When the filesystem supports reflinks the copy is a copy-on-write clone,
+// otherwise the file is copied page by page. Commits and writes wait until
+// the copy is done, as with CopyTo.
+func (pgr *Pager) SnapshotTo(path string) error {
+	pgr.commitMu.Lock()
+	defer pgr.commitMu.Unlock()
+
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.closed {
+		return fmt.Errorf("pager/snapshotTo: %w", ErrClosed)
+	}
+
+	if err := pgr.flushForCopy(); err != nil {
+		return fmt.Errorf("pager/snapshotTo: %w", err)
+	}
+
+	dst, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, DefaultFilePerm)
+	if err != nil {
+		return fmt.Errorf("pager/snapshotTo: open/create file: %w", err)
+	}
+
+	if err := pgr.reflinkTo(dst); err != nil {
+		if !errors.Is(err, errReflinkUnsupported) {
+			_ = dst.Close()
+			return fmt.Errorf("pager/snapshotTo: %w", err)
+		}
+
+		if err := pgr.copyPagesTo(dst); err != nil {
+			_ = dst.Close()
+			return fmt.Errorf("pager/snapshotTo: %w", err)
+		}
+	}
+
+	if err := dst.Sync(); err != nil {
+		_ = dst.Close()
+		return fmt.Errorf("pager/snapshotTo: sync file: %w", err)
+	}
+
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("pager/snapshotTo: close file: %w", err)
+	}
+
+	return nil
+}
+
+// CopyTo flushes pending changes and streams pages 0 to Max-1 to w, which
+// makes a store file NewPager can open. A read-only pager is copied as the
+// file stands. Commits and writes wait until the copy
+// is done, so it reflects a single flushed state.
+func (pgr *Pager) CopyTo(w io.Writer) error {
+	pgr.commitMu.Lock()
+	defer pgr.commitMu.Unlock()
+
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.closed {
+		return fmt.Errorf("pager/copyTo: %w", ErrClosed)
+	}
+
+	if err := pgr.flushForCopy(); err != nil {
+		return fmt.Errorf("pager/copyTo: %w", err)
+	}
+
+	buf := make([]byte, pgr.psize)
+	for num := PageNum(0); num < pgr.flist.Max; num++ {
+		n, err := pgr.store.ReadAt(buf, int64(num)*int64(pgr.psize))
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("pager/copyTo: read page %d: %w", num, err)
+		}
+		clear(buf[n:])
+
+		if _, err := w.Write(buf); err != nil {
+			return fmt.Errorf("pager/copyTo: write page %d: %w", num, err)
+		}
+	}
+
+	return nil
+}
+
+// flushForCopy makes the file hold the state of the pager before it is
+// copied. A read-only pager has nothing to write and is not flushed.
+func (pgr *Pager) flushForCopy() error {
+	if pgr.opts.ReadOnly || !pgr.needsFlush() {
+		return nil
+	}
+	return pgr.flush(context.Background())
+}
+
+// Snapshot is a read view of the pager that sees the pages allocated when
+// it was taken. It holds no lock: writers keep allocating and writing,
+// and pages within the snapshot read whatever was last written to them.
+type Snapshot struct {
+	pgr *Pager
+
+	max  PageNum
+	meta Metainfo
+
+	// closed is set by Close, which may run while other goroutines read.
+	closed atomic.Bool
+}
+
+// Snapshot captures the freelist Max and the metainfo of the pager.
+func (pgr *Pager) Snapshot() *Snapshot {
+	pgr.mu.RLock()
+	defer pgr.mu.RUnlock()
+
+	return &Snapshot{
+		pgr: pgr,
+
+		max:  pgr.flist.Max,
+		meta: *pgr.meta.Clone(),
+	}
+}
+
+// Read reads a page through the pager, refusing pages allocated after the
+// snapshot was taken with ErrPageOutOfRange.
+func (snap *Snapshot) Read(num PageNum) (*Page, error) {
+	if snap.closed.Load() {
+		return nil, fmt.Errorf("snapshot/read(num=%d): %w", num, ErrClosed)
+	}
+
+	if num < 0 || num >= snap.max {
+		return nil, fmt.Errorf(
+			"snapshot/read(num=%d): max %d: %w",
+			num, snap.max, ErrPageOutOfRange,
+		)
+	}
+
+	pg, err := snap.pgr.Read(num)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot/read(num=%d): %w", num, err)
+	}
+
+	return pg, nil
+}
+
+// Max returns the freelist Max at the time the snapshot was taken.
+func (snap *Snapshot) Max() PageNum {
+	return snap.max
+}
+
+// Meta returns a copy of the metainfo at the time the snapshot was taken.
+func (snap *Snapshot) Meta() Metainfo {
+	return snap.meta
+}
+
+// Close releases the snapshot. Reads through it fail afterwards.
+func (snap *Snapshot) Close() error {
+	snap.closed.Store(true)
+	return nil
+}
+
+func (pgr *Pager) reflinkTo(dst *os.File) error {
+	f, ok := pgr.file()
+	if !ok {
+		return errReflinkUnsupported
+	}
+
+	return reflink(dst, f)
+}
+
+func (pgr *Pager) copyPagesTo(w io.WriterAt) error {
+	fileSize, err := pgr.storeSize()
+	if err != nil {
+		return fmt.Errorf("copy pages: %w", err)
+	}
+
+	buf := make([]byte, pgr.psize)
+	for off := int64(0); off < fileSize; off += int64(pgr.psize) {
+		n, err := pgr.store.ReadAt(buf, off)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("copy pages: read(off=%d): %w", off, err)
+		}
+
+		if _, err := w.WriteAt(buf[:n], off); err != nil {
+			return fmt.Errorf("copy pages: write(off=%d): %w", off, err)
+		}
+	}
+
+	return nil
+}
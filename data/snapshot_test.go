@@ -0,0 +1,310 @@
+package data_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_SnapshotTo(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test_data")
+	snapname := filepath.Join(dir, "test_snapshot")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	for i := 0; i < 10; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+	}
+
+	if err := pgr.SnapshotTo(snapname); err != nil {
+		t.Fatalf("Failed to snapshot pager to %s, with error %s", snapname, err)
+	}
+
+	expectedb, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file %s, with error %s", filename, err)
+	}
+
+	actualb, err := os.ReadFile(snapname)
+	if err != nil {
+		t.Fatalf("Failed to read file %s, with error %s", snapname, err)
+	}
+
+	if !bytes.Equal(expectedb, actualb) {
+		t.Fatalf(
+			"Failed to compare snapshot: expected %d bytes, actual %d bytes",
+			len(expectedb), len(actualb),
+		)
+	}
+
+	snap, err := data.NewPager(snapname, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to open snapshot by path %s, with error %s",
+			snapname, err,
+		)
+	}
+	defer snap.Close()
+
+	if !pgr.Freelist().Equal(snap.Freelist()) {
+		t.Fatalf(
+			"Failed to compare snapshot freelist: expected %+v, actual %+v",
+			pgr.Freelist(), snap.Freelist(),
+		)
+	}
+}
+
+func TestPager_CopyTo(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test_data")
+	copyname := filepath.Join(dir, "test_copy")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	var pages []*data.Page
+	for i := 0; i < 10; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		pages = append(pages, pg)
+	}
+	pgr.Freelist().Release(pages[3].Num)
+
+	var buf bytes.Buffer
+	if err := pgr.CopyTo(&buf); err != nil {
+		t.Fatalf("Failed to copy pager, with error %s", err)
+	}
+
+	if err := os.WriteFile(copyname, buf.Bytes(), data.DefaultFilePerm); err != nil {
+		t.Fatalf("Failed to write copy to %s, with error %s", copyname, err)
+	}
+
+	copied, err := data.NewPager(copyname, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to open copied pager by path %s, with error %s",
+			copyname, err,
+		)
+	}
+	defer copied.Close()
+
+	if !pgr.Freelist().Equal(copied.Freelist()) {
+		t.Fatalf(
+			"Failed to compare copied freelist: expected %+v, actual %+v",
+			pgr.Freelist(), copied.Freelist(),
+		)
+	}
+
+	for _, expectedPg := range pages {
+		actualPg, err := copied.Read(expectedPg.Num)
+		if err != nil {
+			t.Fatalf("Failed to read copied page %d, with error %s", expectedPg.Num, err)
+		}
+
+		if !bytes.Equal(expectedPg.Data, actualPg.Data) {
+			t.Fatalf("Failed to compare copied page %d data", expectedPg.Num)
+		}
+	}
+}
+
+func TestPager_CopyToReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	var pages []*data.Page
+	for i := 0; i < 5; i++ {
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		pages = append(pages, pg)
+	}
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	ro, err := data.NewPagerWithOptions(filename, psize, data.WithReadOnly(true))
+	if err != nil {
+		t.Fatalf("Failed to open read-only pager by path %s, with error %s", filename, err)
+	}
+	defer ro.Close()
+
+	var buf bytes.Buffer
+	if err := ro.CopyTo(&buf); err != nil {
+		t.Fatalf("Failed to copy read-only pager, with error %s", err)
+	}
+
+	snapname := filepath.Join(dir, "test_snapshot")
+	if err := ro.SnapshotTo(snapname); err != nil {
+		t.Fatalf("Failed to snapshot read-only pager to %s, with error %s", snapname, err)
+	}
+
+	copyname := filepath.Join(dir, "test_copy")
+	if err := os.WriteFile(copyname, buf.Bytes(), data.DefaultFilePerm); err != nil {
+		t.Fatalf("Failed to write copy to %s, with error %s", copyname, err)
+	}
+
+	for _, name := range []string{copyname, snapname} {
+		copied, err := data.NewPager(name, psize)
+		if err != nil {
+			t.Fatalf("Failed to open copied pager by path %s, with error %s", name, err)
+		}
+
+		for _, expectedPg := range pages {
+			actualPg, err := copied.Read(expectedPg.Num)
+			if err != nil {
+				t.Fatalf("Failed to read copied page %d, with error %s", expectedPg.Num, err)
+			}
+
+			if !bytes.Equal(expectedPg.Data, actualPg.Data) {
+				t.Fatalf("Failed to compare copied page %d data", expectedPg.Num)
+			}
+		}
+		_ = copied.Close()
+	}
+}
+
+func TestPager_Snapshot(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	write := func(t *testing.T, i int) *data.Page {
+		t.Helper()
+
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+
+		return pg
+	}
+
+	var old []*data.Page
+	for i := 0; i < 3; i++ {
+		old = append(old, write(t, i))
+	}
+
+	snap := pgr.Snapshot()
+
+	var added []*data.Page
+	for i := 3; i < 6; i++ {
+		added = append(added, write(t, i))
+	}
+
+	for _, expectedPg := range old {
+		actualPg, err := snap.Read(expectedPg.Num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d through snapshot, with error %s", expectedPg.Num, err)
+		}
+
+		if !bytes.Equal(expectedPg.Data, actualPg.Data) {
+			t.Fatalf("Failed to compare page %d data through snapshot", expectedPg.Num)
+		}
+	}
+
+	for _, pg := range added {
+		if _, err := snap.Read(pg.Num); !errors.Is(err, data.ErrPageOutOfRange) {
+			t.Fatalf(
+				"Failed to read page %d through snapshot: expected error %s, actual %v",
+				pg.Num, data.ErrPageOutOfRange, err,
+			)
+		}
+	}
+
+	if err := snap.Close(); err != nil {
+		t.Fatalf("Failed to close snapshot, with error %s", err)
+	}
+
+	if _, err := snap.Read(old[0].Num); !errors.Is(err, data.ErrClosed) {
+		t.Fatalf(
+			"Failed to read closed snapshot: expected error %s, actual %v",
+			data.ErrClosed, err,
+		)
+	}
+}
+
+func TestSnapshot_CloseWhileReading(t *testing.T) {
+	pgr, err := data.NewMemPager(512)
+	if err != nil {
+		t.Fatalf("Failed to create in-memory pager, with error %s", err)
+	}
+	defer pgr.Close()
+
+	num, err := pgr.Append([]byte("data"))
+	if err != nil {
+		t.Fatalf("Failed to append page, with error %s", err)
+	}
+
+	snap := pgr.Snapshot()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				if _, err := snap.Read(num); err != nil && !errors.Is(err, data.ErrClosed) {
+					t.Errorf("Failed to read page %d through snapshot, with error %s", num, err)
+					return
+				}
+			}
+		}()
+	}
+
+	if err := snap.Close(); err != nil {
+		t.Fatalf("Failed to close snapshot, with error %s", err)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,107 @@
+package data
+
+import (
+	"bytes"
+	"maps"
+)
+
+// StateOptions configures StateEqual. The zero value compares the page
+// size, the metainfo and the freelist only.
+type StateOptions struct {
+	// Pages also compares the data and type of every live page.
+	Pages bool
+}
+
+type StateOption func(*StateOptions)
+
+func WithPageComparison(enabled bool) StateOption {
+	return func(opts *StateOptions) {
+		opts.Pages = enabled
+	}
+}
+
+// pagerState is what StateEqual compares, captured under the lock of a
+// single pager so that two pagers are never locked at once.
+type pagerState struct {
+	psize int
+	meta  *Metainfo
+	flist *Freelist
+	free  map[PageNum]struct{}
+}
+
+func (pgr *Pager) state() (pagerState, bool) {
+	pgr.mu.RLock()
+	defer pgr.mu.RUnlock()
+
+	if pgr.closed {
+		return pagerState{}, false
+	}
+
+	return pagerState{
+		psize: pgr.psize,
+		meta:  pgr.meta.Clone(),
+		flist: pgr.flist.Clone(),
+		free:  pgr.freePages(),
+	}, true
+}
+
+// StateEqual reports whether the two pagers hold the same logical state:
+// the same page size, metainfo and freelist and, with
+// WithPageComparison, the same data in every live page between the
+// reserved region and Max, such as a store and its backup. It is meant for
+// pagers nobody writes to meanwhile, and reports false for closed pagers
+// and pages that fail to read.
+func (pgr *Pager) StateEqual(other *Pager, opts ...StateOption) bool {
+	var options StateOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if pgr == other {
+		return true
+	}
+
+	state, ok := pgr.state()
+	if !ok {
+		return false
+	}
+	otherState, ok := other.state()
+	if !ok {
+		return false
+	}
+
+	if state.psize != otherState.psize ||
+		!state.meta.Equal(otherState.meta) ||
+		!state.flist.Equal(otherState.flist) {
+		return false
+	}
+
+	if !options.Pages {
+		return true
+	}
+
+	if !maps.Equal(state.free, otherState.free) {
+		return false
+	}
+
+	for num := state.flist.Begin(); num < state.flist.Max; num++ {
+		if _, ok := state.free[num]; ok {
+			continue
+		}
+
+		pg, err := pgr.Read(num)
+		if err != nil {
+			return false
+		}
+		otherPg, err := other.Read(num)
+		if err != nil {
+			return false
+		}
+
+		if pg.Type() != otherPg.Type() || !bytes.Equal(pg.Data, otherPg.Data) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,79 @@
+package data_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_StateEqual(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test_data")
+	copyname := filepath.Join(dir, "test_copy")
+
+	pgr, err := data.NewPager(filename, data.MinPageSize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	var pages []*data.Page
+	for i := 0; i < 10; i++ {
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		pages = append(pages, pg)
+	}
+	if err := pgr.ReleasePage(pages[3].Num); err != nil {
+		t.Fatalf("Failed to release page %d, with error %s", pages[3].Num, err)
+	}
+
+	var buf bytes.Buffer
+	if err := pgr.CopyTo(&buf); err != nil {
+		t.Fatalf("Failed to copy pager, with error %s", err)
+	}
+	if err := os.WriteFile(copyname, buf.Bytes(), data.DefaultFilePerm); err != nil {
+		t.Fatalf("Failed to write copy to %s, with error %s", copyname, err)
+	}
+
+	copied, err := data.NewPager(copyname, data.MinPageSize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to open copied pager by path %s, with error %s",
+			copyname, err,
+		)
+	}
+	defer copied.Close()
+
+	if !pgr.StateEqual(copied) {
+		t.Fatalf("Failed to compare state of copied pager: expected equal")
+	}
+	if !pgr.StateEqual(copied, data.WithPageComparison(true)) {
+		t.Fatalf("Failed to compare state and pages of copied pager: expected equal")
+	}
+
+	pg := copied.Alloc().WithNum(pages[5].Num)
+	pg.Write([]byte("changed"))
+	if err := copied.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %d, with error %s", pg.Num, err)
+	}
+
+	if pgr.StateEqual(copied, data.WithPageComparison(true)) {
+		t.Fatalf("Failed to compare pages of changed copy: expected not equal")
+	}
+
+	nextPage(t, copied)
+	if pgr.StateEqual(copied) {
+		t.Fatalf("Failed to compare state of grown copy: expected not equal")
+	}
+}
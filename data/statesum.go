@@ -0,0 +1,202 @@
+package data
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// StateChecksum returns a checksum of the stored contents of all data
+// pages.
+// Stores holding the same pages at the same page numbers report the same
+// checksum, whatever order the pages were written in. It is maintained
+// incrementally on every Write and persisted in Metainfo by Flush, and
+// recomputed from the file on recovery after an unclean shutdown. It is
+// zero unless the pager was opened WithStateChecksum.
+func (pgr *Pager) StateChecksum() uint64 {
+	pgr.sumMu.Lock()
+	defer pgr.sumMu.Unlock()
+
+	return pgr.stateSum
+}
+
+// pageContribution folds a page into the state checksum. Pages that were
+// never written read as zeros and contribute nothing.
+func pageContribution(num PageNum, b []byte) uint64 {
+	if isZero(b) {
+		return 0
+	}
+
+	x := uint64(num)<<32 | uint64(crc32.Checksum(b, castagnoli))
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return x
+}
+
+// updateStateSum swaps the contribution of a page for the one of its new
+// contents. The previous contribution is read back from disk when it is
+// not known yet.
+func (pgr *Pager) updateStateSum(num PageNum, b []byte) error {
+	if !pgr.opts.StateChecksum || num < pgr.flist.begin {
+		return nil
+	}
+
+	pgr.sumMu.Lock()
+	defer pgr.sumMu.Unlock()
+
+	prev, ok := pgr.pageSums[num]
+	if !ok {
+		var err error
+		if prev, err = pgr.readContribution(num); err != nil {
+			return fmt.Errorf("update state checksum: %w", err)
+		}
+	}
+
+	next := pageContribution(num, b)
+	pgr.stateSum ^= prev ^ next
+	pgr.pageSums[num] = next
+
+	return nil
+}
+
+// forgetPages drops pages [from, to) from the state checksum before they
+// are cut off the file. They are remembered as zero pages, which is what
+// they read as once truncated.
+func (pgr *Pager) forgetPages(from, to PageNum) error {
+	if !pgr.opts.StateChecksum {
+		return nil
+	}
+
+	pgr.sumMu.Lock()
+	defer pgr.sumMu.Unlock()
+
+	for num := max(from, pgr.flist.begin); num < to; num++ {
+		prev, ok := pgr.pageSums[num]
+		if !ok {
+			var err error
+			if prev, err = pgr.readContribution(num); err != nil {
+				return fmt.Errorf("forget pages: %w", err)
+			}
+		}
+
+		pgr.stateSum ^= prev
+		pgr.pageSums[num] = 0
+	}
+
+	return nil
+}
+
+func (pgr *Pager) readContribution(num PageNum) (uint64, error) {
+	b := make([]byte, pgr.psize)
+
+	n, err := pgr.store.ReadAt(b, int64(num)*int64(pgr.psize))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, fmt.Errorf("read page %d: %w", num, err)
+	}
+	clear(b[n:])
+
+	return pageContribution(num, b), nil
+}
+
+// recomputeStateSum rebuilds the state checksum from every page in the
+// file.
+func (pgr *Pager) recomputeStateSum() error {
+	if !pgr.opts.StateChecksum {
+		return nil
+	}
+
+	fileSize, err := pgr.storeSize()
+	if err != nil {
+		return fmt.Errorf("recompute state checksum: %w", err)
+	}
+
+	pgr.sumMu.Lock()
+	defer pgr.sumMu.Unlock()
+
+	pgr.stateSum = 0
+	pgr.pageSums = make(map[PageNum]uint64)
+
+	filePages := PageNum((fileSize + int64(pgr.psize) - 1) / int64(pgr.psize))
+	for num := pgr.flist.begin; num < filePages; num++ {
+		sum, err := pgr.readContribution(num)
+		if err != nil {
+			return fmt.Errorf("recompute state checksum: %w", err)
+		}
+
+		pgr.stateSum ^= sum
+		pgr.pageSums[num] = sum
+	}
+
+	return nil
+}
+
+func cleanPath(path string) string {
+	return path + ".clean"
+}
+
+// markClean records next to the store that it was closed with everything
+// flushed, keyed by the meta TxID, so that the next recovery can take the
+// state checksum from the metainfo. A failure is only logged: the next
+// recovery recomputes the checksum instead. Without sync the flushed
+// state may not be on disk yet, so no marker is written.
+func (pgr *Pager) markClean() {
+	if !pgr.opts.StateChecksum || pgr.opts.ReadOnly || pgr.opts.NoSync || pgr.path == "" || pgr.needsFlush() {
+		return
+	}
+
+	b := binary.LittleEndian.AppendUint64(nil, pgr.meta.TxID)
+	if err := os.WriteFile(cleanPath(pgr.path), b, DefaultFilePerm); err != nil {
+		pgr.log.Warn("write clean shutdown marker", "err", err)
+	}
+}
+
+// recoverStateSum takes the state checksum from the recovered metainfo
+// when the store was closed cleanly at its transaction and the file ends
+// within the freelist Max. Otherwise pages may have been written after the
+// last flush, and the checksum is recomputed from the file. The marker is
+// removed by a pager that may write, since a crash leaves it stale, even
+// one that does not maintain the checksum.
+func (pgr *Pager) recoverStateSum() error {
+	clean := false
+	if pgr.path != "" {
+		if pgr.opts.StateChecksum {
+			b, err := os.ReadFile(cleanPath(pgr.path))
+			clean = err == nil && len(b) == 8 && binary.LittleEndian.Uint64(b) == pgr.meta.TxID
+		}
+
+		if !pgr.opts.ReadOnly {
+			if err := os.Remove(cleanPath(pgr.path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("recover state checksum: remove clean shutdown marker: %w", err)
+			}
+		}
+	}
+
+	if !pgr.opts.StateChecksum {
+		return nil
+	}
+
+	fileSize, err := pgr.storeSize()
+	if err != nil {
+		return fmt.Errorf("recover state checksum: %w", err)
+	}
+
+	if clean && fileSize <= int64(pgr.flist.Max)*int64(pgr.psize) {
+		pgr.sumMu.Lock()
+		pgr.stateSum = pgr.meta.StateSum
+		pgr.pageSums = make(map[PageNum]uint64)
+		pgr.sumMu.Unlock()
+		return nil
+	}
+
+	pgr.log.Debug("recomputing state checksum", "clean", clean, "size", fileSize)
+	return pgr.recomputeStateSum()
+}
@@ -0,0 +1,213 @@
+package data_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_StateChecksum(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test_data")
+	copyname := filepath.Join(dir, "test_copy")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPagerWithOptions(filename, psize, data.WithStateChecksum(true))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	var pages []*data.Page
+	for i := 0; i < 5; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		pages = append(pages, pg)
+	}
+
+	if err := pgr.SnapshotTo(copyname); err != nil {
+		t.Fatalf("Failed to snapshot pager to %s, with error %s", copyname, err)
+	}
+
+	cp, err := data.NewPagerWithOptions(copyname, psize, data.WithStateChecksum(true))
+	if err != nil {
+		t.Fatalf(
+			"Failed to open pager by path %s, with error %s",
+			copyname, err,
+		)
+	}
+	defer cp.Close()
+
+	if pgr.StateChecksum() == 0 || pgr.StateChecksum() != cp.StateChecksum() {
+		t.Fatalf(
+			"Failed to compare state checksums of copies: expected %x, actual %x",
+			pgr.StateChecksum(), cp.StateChecksum(),
+		)
+	}
+
+	modified := cp.Alloc().WithNum(pages[2].Num)
+	modified.Write([]byte("modified"))
+	if err := cp.Write(modified); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", modified, err)
+	}
+
+	if pgr.StateChecksum() == cp.StateChecksum() {
+		t.Fatalf("Failed to check state checksum: unchanged after page modification")
+	}
+
+	if err := cp.Write(pages[2]); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pages[2], err)
+	}
+
+	if pgr.StateChecksum() != cp.StateChecksum() {
+		t.Fatalf(
+			"Failed to compare state checksums after restoring page: expected %x, actual %x",
+			pgr.StateChecksum(), cp.StateChecksum(),
+		)
+	}
+}
+
+func TestPager_StateChecksumUncleanClose(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPagerWithOptions(filename, psize, data.WithStateChecksum(true), data.WithFlushOnClose(false))
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	var pages []*data.Page
+	for i := 0; i < 3; i++ {
+		pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		pages = append(pages, pg)
+	}
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	// A page rewritten and a page appended after the last flush reach the
+	// file, but not the checksum stored in the metainfo.
+	pages[1].Write([]byte("rewritten"))
+	if err := pgr.Write(pages[1]); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pages[1], err)
+	}
+	pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+	pg.Write([]byte("appended"))
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	expected := pgr.StateChecksum()
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	reopened, err := data.NewPagerWithOptions(filename, psize, data.WithStateChecksum(true))
+	if err != nil {
+		t.Fatalf(
+			"Failed to reopen pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	if reopened.StateChecksum() != expected {
+		t.Fatalf(
+			"Failed to compare state checksum after unclean close: expected %x, actual %x",
+			expected, reopened.StateChecksum(),
+		)
+	}
+
+	// A clean close keeps the checksum stored in the metainfo valid.
+	expected = reopened.StateChecksum()
+	if err := reopened.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	clean, err := data.NewPagerWithOptions(filename, psize, data.WithStateChecksum(true))
+	if err != nil {
+		t.Fatalf(
+			"Failed to reopen pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer clean.Close()
+
+	if clean.StateChecksum() != expected {
+		t.Fatalf(
+			"Failed to compare state checksum after clean close: expected %x, actual %x",
+			expected, clean.StateChecksum(),
+		)
+	}
+}
+
+func TestPager_StateChecksumMarker(t *testing.T) {
+	psize := os.Getpagesize()
+
+	tests := map[string]struct {
+		opts               []data.PagerOption
+		maintained, marked bool
+	}{
+		"disabled": {opts: nil},
+		"enabled":  {opts: []data.PagerOption{data.WithStateChecksum(true)}, maintained: true, marked: true},
+		"no sync":  {opts: []data.PagerOption{data.WithStateChecksum(true), data.WithSync(false)}, maintained: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "test_data")
+
+			pgr, err := data.NewPagerWithOptions(filename, psize, tt.opts...)
+			if err != nil {
+				t.Fatalf(
+					"Failed to create pager by path %s, with error %s",
+					filename, err,
+				)
+			}
+
+			if _, err := pgr.Append([]byte("data")); err != nil {
+				t.Fatalf("Failed to append page, with error %s", err)
+			}
+			if sum := pgr.StateChecksum(); (sum != 0) != tt.maintained {
+				t.Fatalf("Failed to check state checksum is maintained: expected %t, checksum %x", tt.maintained, sum)
+			}
+			if err := pgr.Close(); err != nil {
+				t.Fatalf("Failed to close pager, with error %s", err)
+			}
+
+			if _, err := os.Stat(filename + ".clean"); (err == nil) != tt.marked {
+				t.Fatalf("Failed to check clean shutdown marker: expected %t, stat error %v", tt.marked, err)
+			}
+
+			// A read-only pager neither removes nor writes the marker.
+			ro, err := data.NewPagerWithOptions(filename, psize, append(tt.opts, data.WithReadOnly(true))...)
+			if err != nil {
+				t.Fatalf("Failed to open pager read-only, with error %s", err)
+			}
+			if err := ro.Close(); err != nil {
+				t.Fatalf("Failed to close pager, with error %s", err)
+			}
+
+			if _, err := os.Stat(filename + ".clean"); (err == nil) != tt.marked {
+				t.Fatalf("Failed to check clean shutdown marker after read-only open: expected %t, stat error %v", tt.marked, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package data
+
+import (
+	"fmt"
+)
+
+// Stats is a snapshot of the space usage of a pager.
+type Stats struct {
+	PageSize int
+
+	// HighWaterMark is the freelist Max, one past the highest page
+	// number ever handed out.
+	HighWaterMark PageNum
+
+	// FreePages counts the pages released to the primary freelist.
+	FreePages int
+
+	// AllocatedPages counts the pages in use, which excludes the
+	// reserved pages and every page released to the freelist or to a
+	// class pool.
+	AllocatedPages int64
+
+	// PreallocatedPages counts the whole pages the file holds past
+	// HighWaterMark, which WithGrowChunk extends it by ahead of use.
+	PreallocatedPages int64
+
+	FileSize int64
+}
+
+func (pgr *Pager) Stats() (Stats, error) {
+	pgr.mu.RLock()
+	defer pgr.mu.RUnlock()
+
+	if pgr.closed {
+		return Stats{}, fmt.Errorf("pager/stats: %w", ErrClosed)
+	}
+
+	fileSize, err := pgr.storeSize()
+	if err != nil {
+		return Stats{}, fmt.Errorf("pager/stats: %w", err)
+	}
+
+	pooled := 0
+	for _, pool := range pgr.classes.Pools {
+		pooled += len(pool)
+	}
+
+	return Stats{
+		PageSize:          pgr.psize,
+		HighWaterMark:     pgr.flist.Max,
+		FreePages:         pgr.flist.Count(),
+		AllocatedPages:    pgr.flist.Allocated() - int64(pooled),
+		PreallocatedPages: max(0, fileSize/int64(pgr.psize)-int64(pgr.flist.Max)),
+		FileSize:          fileSize,
+	}, nil
+}
+
+// PageSize returns the size of a page on disk, header included.
+func (pgr *Pager) PageSize() int {
+	return pgr.psize
+}
+
+// PageForOffset returns the page holding the byte at offset off of the
+// file, which must not be negative.
+func (pgr *Pager) PageForOffset(off int64) PageNum {
+	return PageNum(off / int64(pgr.psize))
+}
+
+// OffsetForPage returns the offset of the first byte of page num in the
+// file.
+func (pgr *Pager) OffsetForPage(num PageNum) int64 {
+	return int64(num) * int64(pgr.psize)
+}
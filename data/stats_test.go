@@ -0,0 +1,110 @@
+package data_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_Stats(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	var nums []data.PageNum
+	for i := 0; i < 10; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		nums = append(nums, pg.Num)
+	}
+
+	for _, num := range nums[:3] {
+		pgr.Freelist().Release(num)
+	}
+	if err := pgr.Release(1, nums[3]); err != nil {
+		t.Fatalf("Failed to release page %d, with error %s", nums[3], err)
+	}
+
+	stats, err := pgr.Stats()
+	if err != nil {
+		t.Fatalf("Failed to get pager stats, with error %s", err)
+	}
+
+	expectedStats := data.Stats{
+		PageSize:       psize,
+		HighWaterMark:  data.BeginFreeBlocks + 10,
+		FreePages:      3,
+		AllocatedPages: 6,
+		FileSize:       int64(data.BeginFreeBlocks+10) * int64(psize),
+	}
+
+	if stats != expectedStats {
+		t.Fatalf(
+			"Failed to compare pager stats: expected %+v, actual %+v",
+			expectedStats, stats,
+		)
+	}
+}
+
+func TestPager_Geometry(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	if pgr.PageSize() != psize {
+		t.Fatalf("Failed to compare page size: expected %d, actual %d", psize, pgr.PageSize())
+	}
+
+	for _, num := range []data.PageNum{data.DefaultMetaPage, data.BeginFreeBlocks, 12345} {
+		off := pgr.OffsetForPage(num)
+		if off != int64(num)*int64(psize) {
+			t.Fatalf("Failed to compare offset of page %d: expected %d, actual %d", num, int64(num)*int64(psize), off)
+		}
+
+		for _, inPage := range []int64{0, 1, int64(psize) - 1} {
+			if actual := pgr.PageForOffset(off + inPage); actual != num {
+				t.Fatalf("Failed to compare page of offset %d: expected %d, actual %d", off+inPage, num, actual)
+			}
+		}
+		if actual := pgr.PageForOffset(off + int64(psize)); actual != num+1 {
+			t.Fatalf("Failed to compare page of offset %d: expected %d, actual %d", off+int64(psize), num+1, actual)
+		}
+	}
+
+	pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+	pg.Write([]byte("geometry"))
+	if err := pgr.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file %s, with error %s", filename, err)
+	}
+
+	off := pgr.OffsetForPage(pg.Num)
+	if !bytes.Contains(raw[off:off+int64(psize)], []byte("geometry")) {
+		t.Fatalf("Failed to find page %d at offset %d of the file", pg.Num, off)
+	}
+}
@@ -0,0 +1,267 @@
+package data
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Store keeps keyed records on top of a Pager. Keys are kept in a B-tree
+// whose root is the Root of the metainfo, and every value lives in an
+// overflow chain of its own that the tree points to. Buckets keep their
+// keys in trees of their own.
+//
+// All access goes through transactions: View for reads, Update for
+// writes, each commit of which is made durable with a flush of the pager.
+// Put, Get, Delete and the bucket methods of the store run as single
+// operation transactions.
+type Store struct {
+	pgr *Pager
+
+	// writer serializes Update transactions.
+	writer sync.Mutex
+
+	// mu guards readers. It is taken after the pager lock.
+	mu      sync.Mutex
+	readers map[uint64]int
+}
+
+// keyspace is a set of keys kept in one tree: the top level of the store
+// or a bucket. Its methods are called with the pager lock held.
+type keyspace interface {
+	loadRoot() (PageNum, error)
+	storeRoot(root PageNum) error
+}
+
+// OpenStore opens the store kept in pgr, which is empty when the pager
+// holds none. The store takes ownership of the pager.
+func OpenStore(pgr *Pager) (*Store, error) {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	s := &Store{
+		pgr:     pgr,
+		readers: make(map[uint64]int),
+	}
+
+	for _, root := range []PageNum{pgr.meta.Root, pgr.meta.Buckets} {
+		if root == 0 {
+			continue
+		}
+
+		if _, err := s.tree(root, nil).readNode(root); err != nil {
+			return nil, fmt.Errorf("store/open: %w", err)
+		}
+	}
+	pgr.owned = true
+
+	return s, nil
+}
+
+func (s *Store) tree(root PageNum, pages *txPages) *btree {
+	return &btree{pgr: s.pgr, root: root, pages: pages}
+}
+
+// Put stores value under key, replacing and freeing any previous value.
+// Keys are limited to about a quarter of a page.
+func (s *Store) Put(key, value []byte) error {
+	return s.Update(func(tx *StoreTx) error {
+		return tx.Put(key, value)
+	})
+}
+
+// Get returns the value stored under key, or ErrKeyNotFound.
+func (s *Store) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := s.View(func(tx *StoreTx) error {
+		var err error
+		value, err = tx.Get(key)
+		return err
+	})
+
+	return value, err
+}
+
+// Delete removes key and frees its value, or returns ErrKeyNotFound.
+func (s *Store) Delete(key []byte) error {
+	return s.Update(func(tx *StoreTx) error {
+		return tx.Delete(key)
+	})
+}
+
+// Cursor returns a cursor positioned before the first key of the store.
+// Outside of a transaction the pages under the cursor can be reused once
+// the store is modified, so it must Seek again after every modification.
+func (s *Store) Cursor() *Cursor {
+	return newCursor(s, func() (PageNum, error) {
+		return s.pgr.meta.Root, nil
+	})
+}
+
+// Flush makes the changes to the store durable.
+func (s *Store) Flush() error {
+	if _, err := s.pgr.Flush(); err != nil {
+		return fmt.Errorf("store/flush: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the pager, which flushes the store unless the pager was
+// opened with WithFlushOnClose(false). Pages still held back for readers
+// are released first when none are left.
+func (s *Store) Close() error {
+	s.pgr.mu.Lock()
+	relErr := s.processRelease()
+	s.pgr.mu.Unlock()
+
+	if err := s.pgr.Close(); err != nil {
+		return fmt.Errorf("store/close: %w", err)
+	}
+
+	if relErr != nil {
+		return fmt.Errorf("store/close: %w", relErr)
+	}
+
+	return nil
+}
+
+// processRelease releases the pending pages no reader can reach any more.
+// It is called with the pager lock held for writing.
+func (s *Store) processRelease() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	oldest := uint64(math.MaxUint64)
+	for txid := range s.readers {
+		oldest = min(oldest, txid)
+	}
+
+	return s.pgr.flist.ProcessRelease(oldest)
+}
+
+// put stores value under key in space. The new value chain belongs to the
+// transaction tracked by pages.
+func (s *Store) put(space keyspace, pages *txPages, key, value []byte) error {
+	root, err := space.loadRoot()
+	if err != nil {
+		return err
+	}
+	tree := s.tree(root, pages)
+
+	if 2+2+len(key)+8 > tree.maxEntrySize() {
+		return fmt.Errorf(
+			"key of %d bytes, max %d: %w",
+			len(key), tree.maxEntrySize()-2-2-8, ErrDataTooLarge,
+		)
+	}
+
+	old, replaced, err := tree.get(key)
+	if err != nil {
+		return err
+	}
+
+	nums, err := s.pgr.writeChain(nil, PageTypeOverflow, value)
+	if err != nil {
+		return err
+	}
+	for _, num := range nums {
+		pages.fresh[num] = struct{}{}
+	}
+
+	err = tree.put(key, binary.LittleEndian.AppendUint64(nil, uint64(nums[0])))
+	if err := s.saveRoot(space, root, tree.root); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if replaced {
+		if err := s.freeValue(tree, old); err != nil {
+			return fmt.Errorf("free previous value: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) get(space keyspace, key []byte) ([]byte, error) {
+	if s.pgr.closed {
+		return nil, ErrClosed
+	}
+
+	root, err := space.loadRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	head, ok, err := s.tree(root, nil).get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	value, _, err := s.pgr.readChain(valueHead(head))
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func (s *Store) delete(space keyspace, pages *txPages, key []byte) error {
+	root, err := space.loadRoot()
+	if err != nil {
+		return err
+	}
+	tree := s.tree(root, pages)
+
+	head, ok, err := tree.get(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrKeyNotFound
+	}
+
+	_, err = tree.delete(key)
+	if err := s.saveRoot(space, root, tree.root); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.freeValue(tree, head)
+}
+
+// freeValue releases the chain of a value stored in tree.
+func (s *Store) freeValue(tree *btree, head []byte) error {
+	_, nums, err := s.pgr.readChain(valueHead(head))
+	if err != nil {
+		return err
+	}
+
+	for _, num := range nums {
+		tree.release(num)
+	}
+
+	return nil
+}
+
+// saveRoot records the root of the tree of space when it changed.
+func (s *Store) saveRoot(space keyspace, old, root PageNum) error {
+	if old == root {
+		return nil
+	}
+	return space.storeRoot(root)
+}
+
+// valueHead decodes the head of a value chain as stored in the tree.
+func valueHead(b []byte) PageNum {
+	return PageNum(binary.LittleEndian.Uint64(b))
+}
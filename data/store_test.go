@@ -0,0 +1,138 @@
+package data_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestStore(t *testing.T) {
+	psize := os.Getpagesize()
+
+	openStore := func(t *testing.T, filename string) *data.Store {
+		t.Helper()
+
+		pgr, err := data.NewPager(filename, psize)
+		if err != nil {
+			t.Fatalf(
+				"Failed to create pager by path %s, with error %s",
+				filename, err,
+			)
+		}
+
+		s, err := data.OpenStore(pgr)
+		if err != nil {
+			_ = pgr.Close()
+			t.Fatalf("Failed to open store, with error %s", err)
+		}
+
+		return s
+	}
+
+	get := func(t *testing.T, s *data.Store, key string, expected []byte) {
+		t.Helper()
+
+		actual, err := s.Get([]byte(key))
+		if err != nil {
+			t.Fatalf("Failed to get key %q, with error %s", key, err)
+		}
+
+		if !bytes.Equal(expected, actual) {
+			t.Fatalf(
+				"Failed to compare value of key %q: expected %d bytes, actual %d bytes",
+				key, len(expected), len(actual),
+			)
+		}
+	}
+
+	t.Run("put get delete", func(t *testing.T) {
+		s := openStore(t, filepath.Join(t.TempDir(), "test_data"))
+		defer s.Close()
+
+		large := bytes.Repeat([]byte{0xab, 0xcd, 0xef}, psize)
+		values := map[string][]byte{
+			"empty": {},
+			"small": []byte("value"),
+			"large": large,
+		}
+
+		for key, value := range values {
+			if err := s.Put([]byte(key), value); err != nil {
+				t.Fatalf("Failed to put key %q, with error %s", key, err)
+			}
+		}
+
+		for key, value := range values {
+			get(t, s, key, value)
+		}
+
+		if err := s.Delete([]byte("small")); err != nil {
+			t.Fatalf("Failed to delete key %q, with error %s", "small", err)
+		}
+
+		if _, err := s.Get([]byte("small")); !errors.Is(err, data.ErrKeyNotFound) {
+			t.Fatalf("Failed to get deleted key: expected error %s, actual %v", data.ErrKeyNotFound, err)
+		}
+		get(t, s, "large", large)
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		s := openStore(t, filepath.Join(t.TempDir(), "test_data"))
+		defer s.Close()
+
+		if err := s.Put([]byte("key"), bytes.Repeat([]byte("old"), psize)); err != nil {
+			t.Fatalf("Failed to put key, with error %s", err)
+		}
+
+		if err := s.Put([]byte("key"), []byte("new")); err != nil {
+			t.Fatalf("Failed to overwrite key, with error %s", err)
+		}
+
+		get(t, s, "key", []byte("new"))
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		s := openStore(t, filepath.Join(t.TempDir(), "test_data"))
+		defer s.Close()
+
+		if _, err := s.Get([]byte("missing")); !errors.Is(err, data.ErrKeyNotFound) {
+			t.Fatalf("Failed to get missing key: expected error %s, actual %v", data.ErrKeyNotFound, err)
+		}
+
+		if err := s.Delete([]byte("missing")); !errors.Is(err, data.ErrKeyNotFound) {
+			t.Fatalf("Failed to delete missing key: expected error %s, actual %v", data.ErrKeyNotFound, err)
+		}
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "test_data")
+
+		s := openStore(t, filename)
+		for _, key := range []string{"a", "b", "c"} {
+			if err := s.Put([]byte(key), []byte("value "+key)); err != nil {
+				t.Fatalf("Failed to put key %q, with error %s", key, err)
+			}
+		}
+		if err := s.Delete([]byte("b")); err != nil {
+			t.Fatalf("Failed to delete key %q, with error %s", "b", err)
+		}
+
+		if err := s.Close(); err != nil {
+			t.Fatalf("Failed to close store, with error %s", err)
+		}
+
+		reopened := openStore(t, filename)
+		defer reopened.Close()
+
+		get(t, reopened, "a", []byte("value a"))
+		get(t, reopened, "c", []byte("value c"))
+
+		if _, err := reopened.Get([]byte("b")); !errors.Is(err, data.ErrKeyNotFound) {
+			t.Fatalf("Failed to get deleted key: expected error %s, actual %v", data.ErrKeyNotFound, err)
+		}
+	})
+}
@@ -0,0 +1,243 @@
+package data
+
+import (
+	"context"
+	"fmt"
+)
+
+// StoreTx is a transaction of a store. It works against the roots of the
+// store as they were when it began: a read-only transaction keeps seeing
+// them however many updates commit in the meantime, a read-write one
+// modifies copies of the nodes it touches and makes them current when it
+// commits. The pages it replaces are released once no reader needs them.
+type StoreTx struct {
+	s *Store
+
+	txid     uint64
+	writable bool
+
+	root    PageNum
+	buckets PageNum
+	pages   *txPages
+
+	done bool
+}
+
+// View runs fn in a read-only transaction. Any number of them run
+// concurrently with each other and with an Update.
+func (s *Store) View(fn func(tx *StoreTx) error) (err error) {
+	tx, err := s.begin(false)
+	if err != nil {
+		return fmt.Errorf("store/view: %w", err)
+	}
+	defer func() {
+		if endErr := s.endView(tx); endErr != nil && err == nil {
+			err = fmt.Errorf("store/view: %w", endErr)
+		}
+	}()
+
+	return fn(tx)
+}
+
+// Update runs fn in a read-write transaction, which commits when fn
+// returns nil and rolls back otherwise. Updates run one at a time. The
+// commit flushes the pager, whose alternating meta slots make the new
+// roots current all at once.
+func (s *Store) Update(fn func(tx *StoreTx) error) error {
+	s.writer.Lock()
+	defer s.writer.Unlock()
+
+	tx, err := s.begin(true)
+	if err != nil {
+		return fmt.Errorf("store/update: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+
+	if err := tx.commit(); err != nil {
+		return fmt.Errorf("store/update: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) begin(writable bool) (*StoreTx, error) {
+	s.pgr.mu.RLock()
+	defer s.pgr.mu.RUnlock()
+
+	if s.pgr.closed {
+		return nil, ErrClosed
+	}
+
+	if writable && s.pgr.opts.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	tx := &StoreTx{
+		s: s,
+
+		txid:     s.pgr.meta.TxID,
+		writable: writable,
+
+		root:    s.pgr.meta.Root,
+		buckets: s.pgr.meta.Buckets,
+	}
+
+	if writable {
+		tx.pages = newTxPages()
+		return tx, nil
+	}
+
+	s.mu.Lock()
+	s.readers[tx.txid]++
+	s.mu.Unlock()
+
+	return tx, nil
+}
+
+func (s *Store) endView(tx *StoreTx) error {
+	tx.done = true
+
+	s.mu.Lock()
+	if s.readers[tx.txid]--; s.readers[tx.txid] == 0 {
+		delete(s.readers, tx.txid)
+	}
+	s.mu.Unlock()
+
+	s.pgr.mu.Lock()
+	defer s.pgr.mu.Unlock()
+
+	if len(s.pgr.flist.Pending) > 0 {
+		return s.processRelease()
+	}
+
+	return nil
+}
+
+// commit makes the roots of the transaction current and flushes the
+// pager. The pages the transaction freed are pending for the id the commit
+// takes, so the flush records them as free for the next open while readers
+// of older snapshots keep them for now. When the flush fails the
+// transaction is rolled back.
+func (tx *StoreTx) commit() error {
+	tx.done = true
+
+	pgr := tx.s.pgr
+
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	root, buckets := pgr.meta.Root, pgr.meta.Buckets
+	pgr.meta.Root, pgr.meta.Buckets = tx.root, tx.buckets
+
+	txid := pgr.meta.TxID + 1
+	for _, num := range tx.pages.freed {
+		pgr.flist.ReleasePending(txid, num)
+	}
+
+	if err := pgr.flush(context.Background()); err != nil {
+		pgr.meta.Root, pgr.meta.Buckets = root, buckets
+		delete(pgr.flist.Pending, txid)
+		tx.releaseFresh()
+		return err
+	}
+
+	return tx.s.processRelease()
+}
+
+// rollback releases the pages the transaction allocated. The pages it
+// freed are still part of the current trees and stay in use.
+func (tx *StoreTx) rollback() {
+	tx.done = true
+
+	tx.s.pgr.mu.Lock()
+	defer tx.s.pgr.mu.Unlock()
+
+	tx.releaseFresh()
+}
+
+func (tx *StoreTx) releaseFresh() {
+	for num := range tx.pages.fresh {
+		tx.s.pgr.flist.Release(num)
+	}
+	clear(tx.pages.fresh)
+}
+
+func (tx *StoreTx) loadRoot() (PageNum, error) {
+	return tx.root, nil
+}
+
+func (tx *StoreTx) storeRoot(root PageNum) error {
+	tx.root = root
+	return nil
+}
+
+func (tx *StoreTx) check(write bool) error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	if write && !tx.writable {
+		return fmt.Errorf("read-only transaction: %w", ErrReadOnly)
+	}
+
+	return nil
+}
+
+// Get returns the value stored under key, or ErrKeyNotFound.
+func (tx *StoreTx) Get(key []byte) ([]byte, error) {
+	tx.s.pgr.mu.RLock()
+	defer tx.s.pgr.mu.RUnlock()
+
+	if err := tx.check(false); err != nil {
+		return nil, fmt.Errorf("storeTx/get: %w", err)
+	}
+
+	value, err := tx.s.get(tx, key)
+	if err != nil {
+		return nil, fmt.Errorf("storeTx/get: %w", err)
+	}
+
+	return value, nil
+}
+
+// Put stores value under key, replacing and freeing any previous value.
+func (tx *StoreTx) Put(key, value []byte) error {
+	tx.s.pgr.mu.Lock()
+	defer tx.s.pgr.mu.Unlock()
+
+	if err := tx.check(true); err != nil {
+		return fmt.Errorf("storeTx/put: %w", err)
+	}
+
+	if err := tx.s.put(tx, tx.pages, key, value); err != nil {
+		return fmt.Errorf("storeTx/put: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes key and frees its value, or returns ErrKeyNotFound.
+func (tx *StoreTx) Delete(key []byte) error {
+	tx.s.pgr.mu.Lock()
+	defer tx.s.pgr.mu.Unlock()
+
+	if err := tx.check(true); err != nil {
+		return fmt.Errorf("storeTx/delete: %w", err)
+	}
+
+	if err := tx.s.delete(tx, tx.pages, key); err != nil {
+		return fmt.Errorf("storeTx/delete: %w", err)
+	}
+
+	return nil
+}
+
+// Cursor returns a cursor over the keys the transaction sees, positioned
+// before the first one.
+func (tx *StoreTx) Cursor() *Cursor {
+	return newCursor(tx.s, tx.loadRoot)
+}
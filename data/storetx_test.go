@@ -0,0 +1,205 @@
+package data_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestStore_Transactions(t *testing.T) {
+	openStore := func(t *testing.T) (*data.Store, *data.Pager) {
+		t.Helper()
+
+		pgr, err := data.NewMemPager(512)
+		if err != nil {
+			t.Fatalf("Failed to create in-memory pager, with error %s", err)
+		}
+
+		s, err := data.OpenStore(pgr)
+		if err != nil {
+			_ = pgr.Close()
+			t.Fatalf("Failed to open store, with error %s", err)
+		}
+		t.Cleanup(func() { _ = s.Close() })
+
+		return s, pgr
+	}
+
+	keys := make([]string, 200)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%04d", i)
+	}
+
+	putAll := func(tx *data.StoreTx, version string) error {
+		for _, key := range keys {
+			if err := tx.Put([]byte(key), []byte(version)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// checkAll fails unless every key holds the same version and returns
+	// that version.
+	checkAll := func(tx *data.StoreTx) (string, error) {
+		var version string
+		for _, key := range keys {
+			value, err := tx.Get([]byte(key))
+			if err != nil {
+				return "", fmt.Errorf("get key %s: %w", key, err)
+			}
+
+			if version == "" {
+				version = string(value)
+			}
+			if string(value) != version {
+				return "", fmt.Errorf("key %s holds %s, key %s holds %s", keys[0], version, key, value)
+			}
+		}
+		return version, nil
+	}
+
+	t.Run("concurrent readers", func(t *testing.T) {
+		s, _ := openStore(t)
+
+		if err := s.Update(func(tx *data.StoreTx) error { return putAll(tx, "v1") }); err != nil {
+			t.Fatalf("Failed to put keys, with error %s", err)
+		}
+
+		inFlight, proceed := make(chan struct{}), make(chan struct{})
+		updated := make(chan error, 1)
+		go func() {
+			updated <- s.Update(func(tx *data.StoreTx) error {
+				if err := putAll(tx, "v2"); err != nil {
+					return err
+				}
+				close(inFlight)
+				<-proceed
+				return nil
+			})
+		}()
+		<-inFlight
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				err := s.View(func(tx *data.StoreTx) error {
+					version, err := checkAll(tx)
+					if err == nil && version != "v1" {
+						err = fmt.Errorf("read %s during update", version)
+					}
+					return err
+				})
+				if err != nil {
+					t.Errorf("Failed to read consistent snapshot, with error %s", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		// A reader that began before the commit keeps its snapshot, even
+		// while later updates free and reuse pages.
+		began, check, checked := make(chan struct{}), make(chan struct{}), make(chan error, 1)
+		go func() {
+			checked <- s.View(func(tx *data.StoreTx) error {
+				close(began)
+				<-check
+
+				version, err := checkAll(tx)
+				if err == nil && version != "v1" {
+					err = fmt.Errorf("read %s after commit", version)
+				}
+				return err
+			})
+		}()
+		<-began
+
+		close(proceed)
+		if err := <-updated; err != nil {
+			t.Fatalf("Failed to commit update, with error %s", err)
+		}
+
+		for _, version := range []string{"v3", "v4"} {
+			if err := s.Update(func(tx *data.StoreTx) error { return putAll(tx, version) }); err != nil {
+				t.Fatalf("Failed to put keys, with error %s", err)
+			}
+		}
+
+		close(check)
+		if err := <-checked; err != nil {
+			t.Fatalf("Failed to read snapshot of old reader, with error %s", err)
+		}
+
+		err := s.View(func(tx *data.StoreTx) error {
+			version, err := checkAll(tx)
+			if err == nil && version != "v4" {
+				err = fmt.Errorf("read %s after commits", version)
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Failed to read latest commit, with error %s", err)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		s, pgr := openStore(t)
+
+		if err := s.Update(func(tx *data.StoreTx) error { return putAll(tx, "v1") }); err != nil {
+			t.Fatalf("Failed to put keys, with error %s", err)
+		}
+		used := int(pgr.Freelist().Max) - len(pgr.Freelist().Released)
+
+		errAbort := errors.New("abort")
+		err := s.Update(func(tx *data.StoreTx) error {
+			if err := putAll(tx, "v2"); err != nil {
+				return err
+			}
+			if err := tx.Delete([]byte(keys[0])); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Fatalf("Failed to abort update: expected error %s, actual %v", errAbort, err)
+		}
+
+		err = s.View(func(tx *data.StoreTx) error {
+			version, err := checkAll(tx)
+			if err == nil && version != "v1" {
+				err = fmt.Errorf("read %s after rollback", version)
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Failed to read store after rollback, with error %s", err)
+		}
+
+		if actual := int(pgr.Freelist().Max) - len(pgr.Freelist().Released); actual != used {
+			t.Fatalf("Failed to compare used pages after rollback: expected %d, actual %d", used, actual)
+		}
+	})
+
+	t.Run("read-only transaction", func(t *testing.T) {
+		s, _ := openStore(t)
+
+		var leaked *data.StoreTx
+		err := s.View(func(tx *data.StoreTx) error {
+			leaked = tx
+			return tx.Put([]byte("key"), []byte("value"))
+		})
+		if !errors.Is(err, data.ErrReadOnly) {
+			t.Fatalf("Failed to put in view: expected error %s, actual %v", data.ErrReadOnly, err)
+		}
+
+		if _, err := leaked.Get([]byte("key")); !errors.Is(err, data.ErrTxDone) {
+			t.Fatalf("Failed to get after view: expected error %s, actual %v", data.ErrTxDone, err)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package data
+
+import (
+	"sync"
+)
+
+const DefaultSubscriptionBuffer = 64
+
+type subscription struct {
+	ch   chan PageNum
+	nums []PageNum
+	once sync.Once
+}
+
+func (sub *subscription) close() {
+	sub.once.Do(func() { close(sub.ch) })
+}
+
+// Subscribe returns a channel receiving the number of any page in nums
+// after it has been written, and a function cancelling the subscription.
+// Like AllocEvents, delivery never blocks the writer: notifications for a
+// subscriber whose buffer is full are dropped.
+func (pgr *Pager) Subscribe(nums []PageNum) (<-chan PageNum, func()) {
+	sub := &subscription{
+		ch:   make(chan PageNum, DefaultSubscriptionBuffer),
+		nums: append([]PageNum(nil), nums...),
+	}
+
+	pgr.subsMu.Lock()
+	defer pgr.subsMu.Unlock()
+
+	if pgr.subs == nil {
+		pgr.subs = make(map[PageNum]map[*subscription]struct{})
+	}
+
+	for _, num := range sub.nums {
+		if pgr.subs[num] == nil {
+			pgr.subs[num] = make(map[*subscription]struct{})
+		}
+		pgr.subs[num][sub] = struct{}{}
+	}
+
+	return sub.ch, func() { pgr.unsubscribe(sub) }
+}
+
+func (pgr *Pager) unsubscribe(sub *subscription) {
+	pgr.subsMu.Lock()
+	defer pgr.subsMu.Unlock()
+
+	for _, num := range sub.nums {
+		delete(pgr.subs[num], sub)
+		if len(pgr.subs[num]) == 0 {
+			delete(pgr.subs, num)
+		}
+	}
+
+	sub.close()
+}
+
+func (pgr *Pager) notifyWrite(num PageNum) {
+	pgr.subsMu.Lock()
+	defer pgr.subsMu.Unlock()
+
+	for sub := range pgr.subs[num] {
+		select {
+		case sub.ch <- num:
+		default:
+		}
+	}
+}
+
+func (pgr *Pager) closeSubscriptions() {
+	pgr.subsMu.Lock()
+	defer pgr.subsMu.Unlock()
+
+	for _, subs := range pgr.subs {
+		for sub := range subs {
+			sub.close()
+		}
+	}
+	pgr.subs = nil
+}
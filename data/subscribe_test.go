@@ -0,0 +1,66 @@
+package data_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_Subscribe(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+
+	pgr, err := data.NewPager(filename, os.Getpagesize())
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	watched := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+	other := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+
+	first, unsubscribeFirst := pgr.Subscribe([]data.PageNum{watched.Num})
+	second, unsubscribeSecond := pgr.Subscribe([]data.PageNum{watched.Num})
+	defer unsubscribeSecond()
+
+	for _, pg := range []*data.Page{other, watched} {
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+	}
+
+	for _, notifications := range []<-chan data.PageNum{first, second} {
+		select {
+		case num := <-notifications:
+			if num != watched.Num {
+				t.Fatalf(
+					"Failed to compare notified page: expected %d, actual %d",
+					watched.Num, num,
+				)
+			}
+		default:
+			t.Fatalf("Failed to receive notification for page %d", watched.Num)
+		}
+	}
+
+	unsubscribeFirst()
+
+	if err := pgr.Write(watched); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", watched, err)
+	}
+
+	if _, ok := <-first; ok {
+		t.Fatalf("Failed to unsubscribe: notification delivered after unsubscribe")
+	}
+
+	if num := <-second; num != watched.Num {
+		t.Fatalf(
+			"Failed to compare notified page: expected %d, actual %d",
+			watched.Num, num,
+		)
+	}
+}
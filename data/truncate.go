@@ -0,0 +1,341 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// ShrinkToFit trims every free page at the tail of the file, lowering the
+// freelist Max, and truncates the file to the new high-water mark, short
+// of the pages WithGrowChunk preallocates past it. Live pages are never
+// moved, so holes in the middle of the file are kept.
+func (pgr *Pager) ShrinkToFit() (int64, error) {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.closed {
+		return 0, fmt.Errorf("pager/shrinkToFit: %w", ErrClosed)
+	}
+
+	if pgr.opts.ReadOnly {
+		return 0, fmt.Errorf("pager/shrinkToFit: %w", ErrReadOnly)
+	}
+
+	fileSize, err := pgr.storeSize()
+	if err != nil {
+		return 0, fmt.Errorf("pager/shrinkToFit: %w", err)
+	}
+
+	pgr.flist.Defragment()
+	if pgr.flist.trimTail() == 0 {
+		return 0, nil
+	}
+
+	filePages := PageNum((fileSize + int64(pgr.psize) - 1) / int64(pgr.psize))
+	if err := pgr.forgetPages(pgr.flist.Max, filePages); err != nil {
+		return 0, fmt.Errorf("pager/shrinkToFit: %w", err)
+	}
+
+	if err := pgr.flush(context.Background()); err != nil {
+		return 0, fmt.Errorf("pager/shrinkToFit: %w", err)
+	}
+
+	size := pgr.tailSize()
+	if fileSize <= size {
+		return 0, nil
+	}
+
+	if err := pgr.truncateStore(size); err != nil {
+		return 0, fmt.Errorf("pager/shrinkToFit: truncate file: %w", err)
+	}
+	pgr.fileSize = size
+
+	return fileSize - size, nil
+}
+
+// Truncate is ShrinkToFit for callers that only care whether the tail was
+// reclaimed.
+func (pgr *Pager) Truncate() error {
+	if _, err := pgr.ShrinkToFit(); err != nil {
+		return fmt.Errorf("pager/truncate: %w", err)
+	}
+
+	return nil
+}
+
+// Grow reserves n contiguous page numbers past the freelist Max and
+// extends the file to hold them, so writes to the run do not extend it
+// one page at a time. It returns the first page of the run.
+func (pgr *Pager) Grow(n int) (PageNum, error) {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.closed {
+		return 0, fmt.Errorf("pager/grow(n=%d): %w", n, ErrClosed)
+	}
+
+	if pgr.opts.ReadOnly {
+		return 0, fmt.Errorf("pager/grow(n=%d): %w", n, ErrReadOnly)
+	}
+
+	if n <= 0 {
+		return 0, fmt.Errorf("pager/grow(n=%d): count must be positive", n)
+	}
+
+	fileSize, err := pgr.storeSize()
+	if err != nil {
+		return 0, fmt.Errorf("pager/grow(n=%d): %w", n, err)
+	}
+
+	// The file is extended first, so that a failure leaves the freelist
+	// as it was.
+	if size := int64(pgr.flist.Max+PageNum(n)) * int64(pgr.psize); fileSize < size {
+		if err := pgr.truncateStore(size); err != nil {
+			return 0, fmt.Errorf("pager/grow(n=%d): extend file: %w", n, err)
+		}
+		pgr.fileSize = size
+	}
+
+	return pgr.flist.grow(n), nil
+}
+
+// grow hands out the n page numbers starting at Max.
+func (flist *Freelist) grow(n int) PageNum {
+	first := flist.Max
+	flist.Max += PageNum(n)
+	flist.set(flistDirty, true)
+
+	for num := first; num < flist.Max; num++ {
+		flist.emit(OpAlloc, num)
+	}
+
+	return first
+}
+
+// autoShrink lowers Max past the released pages at the very end of the
+// page space that sit at an end of Released, for WithAutoShrink. Unlike
+// ShrinkToFit it only looks at the run of pages released last, or first
+// with LowestFirst, so a flush does not have to sort the freelist. The
+// file is truncated by truncateTail once the lowered Max is durable. It
+// returns the number of pages Max was lowered by.
+func (pgr *Pager) autoShrink() (int, error) {
+	n := pgr.flist.tailRun()
+	if n == 0 {
+		return 0, nil
+	}
+
+	top := pgr.flist.Max
+	if err := pgr.forgetPages(top-PageNum(n), top); err != nil {
+		return 0, fmt.Errorf("auto shrink: %w", err)
+	}
+	pgr.flist.dropTailRun(n)
+
+	pgr.log.Debug("shrank freelist", "max", pgr.flist.Max, "pages", n)
+	return n, nil
+}
+
+// truncateTail trims the file to the freelist Max, keeping the pages
+// preallocated past it. It runs after the metainfo was written, which
+// makes a failure harmless: the file only keeps free pages past Max.
+func (pgr *Pager) truncateTail() {
+	fileSize, err := pgr.storeSize()
+	if err != nil {
+		pgr.log.Warn("truncate tail", "err", err)
+		return
+	}
+	pgr.fileSize = fileSize
+
+	if size := pgr.tailSize(); fileSize > size {
+		if err := pgr.truncateStore(size); err != nil {
+			pgr.log.Warn("truncate tail", "size", size, "err", err)
+			return
+		}
+		pgr.fileSize = size
+	}
+}
+
+// tailRun returns how many of the pages just below Max are released and
+// make up a run of consecutive page numbers at the end of Released, in
+// either order. With LowestFirst, Released is sorted from the highest
+// page down and the run is looked for at its start instead.
+func (flist *Freelist) tailRun() int {
+	rel := flist.Released
+	top := flist.Max - 1
+
+	if flist.strategy == LowestFirst {
+		n := 0
+		for n < len(rel) && top-PageNum(n) >= flist.begin && rel[n] == top-PageNum(n) {
+			n++
+		}
+		return n
+	}
+
+	// Released from the bottom up, Max-1 is the last entry.
+	up := 0
+	for up < len(rel) && top-PageNum(up) >= flist.begin && rel[len(rel)-1-up] == top-PageNum(up) {
+		up++
+	}
+
+	// Released from the top down, the run ends with its lowest page.
+	down := 0
+	for i := len(rel) - 1; i >= 0 && rel[i] >= flist.begin; i-- {
+		if i < len(rel)-1 && rel[i] != rel[i+1]+1 {
+			break
+		}
+		if down++; rel[i] == top {
+			return max(up, down)
+		}
+	}
+
+	return up
+}
+
+// dropTailRun removes the run of n pages found by tailRun from Released
+// and lowers Max past them.
+func (flist *Freelist) dropTailRun(n int) {
+	if flist.strategy == LowestFirst {
+		flist.Released = slices.Delete(flist.Released, 0, n)
+	} else {
+		flist.Released = flist.Released[:len(flist.Released)-n]
+	}
+	flist.Max -= PageNum(n)
+	flist.set(flistDirty, true)
+}
+
+func (flist *Freelist) trimTail() int {
+	released := make(map[PageNum]struct{}, len(flist.Released))
+	for _, num := range flist.Released {
+		released[num] = struct{}{}
+	}
+
+	trimmed := 0
+	for flist.Max > flist.begin {
+		if _, ok := released[flist.Max-1]; !ok {
+			break
+		}
+
+		delete(released, flist.Max-1)
+		flist.Max -= 1
+		trimmed += 1
+	}
+
+	if trimmed == 0 {
+		return 0
+	}
+
+	kept := flist.Released[:0]
+	for _, num := range flist.Released {
+		if _, ok := released[num]; ok {
+			kept = append(kept, num)
+		}
+	}
+	flist.Released = kept
+	flist.set(flistDirty, true)
+
+	return trimmed
+}
+
+// ValidateAndTruncate recovers the valid prefix of a file whose tail a
+// crash left corrupt. It scans back from the freelist Max for the last page
+// that passes its checksum, or that the freelist holds as free and whose
+// bytes do not matter, lowers Max past it and flushes the new Max before
+// cutting off the file behind it: the torn pages and whatever lies past
+// Max, such as a partial page. Pages preallocated with WithGrowChunk are
+// kept, zeroed. It returns the number of bytes trimmed.
+func (pgr *Pager) ValidateAndTruncate() (int64, error) {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.closed {
+		return 0, fmt.Errorf("pager/validateAndTruncate: %w", ErrClosed)
+	}
+
+	if pgr.opts.ReadOnly {
+		return 0, fmt.Errorf("pager/validateAndTruncate: %w", ErrReadOnly)
+	}
+
+	fileSize, err := pgr.storeSize()
+	if err != nil {
+		return 0, fmt.Errorf("pager/validateAndTruncate: %w", err)
+	}
+
+	valid, err := pgr.validPrefix(fileSize)
+	if err != nil {
+		return 0, fmt.Errorf("pager/validateAndTruncate: %w", err)
+	}
+
+	size := int64(valid) * int64(pgr.psize)
+	if fileSize <= size && valid == pgr.flist.Max {
+		return 0, nil
+	}
+
+	filePages := PageNum((fileSize + int64(pgr.psize) - 1) / int64(pgr.psize))
+	if err := pgr.forgetPages(valid, max(filePages, pgr.flist.Max)); err != nil {
+		return 0, fmt.Errorf("pager/validateAndTruncate: %w", err)
+	}
+
+	if valid < pgr.flist.Max {
+		pgr.log.Warn("dropping corrupt tail pages", "from", valid, "max", pgr.flist.Max)
+		pgr.flist.Max = valid
+		pgr.flist.set(flistDirty, true)
+	}
+
+	if err := pgr.flush(context.Background()); err != nil {
+		return 0, fmt.Errorf("pager/validateAndTruncate: %w", err)
+	}
+
+	// Cutting the file at Max before extending it again zeroes anything
+	// the preallocated pages held.
+	if fileSize > size {
+		if err := pgr.truncateStore(size); err != nil {
+			return 0, fmt.Errorf("pager/validateAndTruncate: truncate file: %w", err)
+		}
+	}
+
+	kept := min(fileSize, pgr.tailSize())
+	if kept > size {
+		if err := pgr.truncateStore(kept); err != nil {
+			return 0, fmt.Errorf("pager/validateAndTruncate: extend file: %w", err)
+		}
+	}
+	pgr.fileSize = max(kept, size)
+
+	return fileSize - pgr.fileSize, nil
+}
+
+// validPrefix returns one past the last page below Max that passes its
+// checksum or is free, scanning back from Max. Pages past the end of the
+// file or cut short by it are torn.
+func (pgr *Pager) validPrefix(fileSize int64) (PageNum, error) {
+	free := pgr.freePages()
+
+	b := make([]byte, pgr.psize)
+	dst := make([]byte, pgr.payloadSize())
+	for num := pgr.flist.Max - 1; num >= pgr.flist.begin; num-- {
+		if _, ok := free[num]; ok {
+			return num + 1, nil
+		}
+
+		if (int64(num)+1)*int64(pgr.psize) > fileSize {
+			continue
+		}
+
+		if _, err := pgr.store.ReadAt(b, int64(num)*int64(pgr.psize)); err != nil {
+			return 0, fmt.Errorf("read page %d: %w", num, err)
+		}
+		if err := pgr.openPage(num, b, dst); err == nil {
+			return num + 1, nil
+		}
+	}
+
+	return pgr.flist.begin, nil
+}
+
+// truncateStore cuts or extends the store to size, dropping the cached
+// pages that no longer lie before its end.
+func (pgr *Pager) truncateStore(size int64) error {
+	pgr.cache.truncate(PageNum(size / int64(pgr.psize)))
+
+	return pgr.store.Truncate(size)
+}
@@ -0,0 +1,525 @@
+package data_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_ShrinkToFit(t *testing.T) {
+	psize := os.Getpagesize()
+
+	newPopulatedPager := func(t *testing.T, n int) (*data.Pager, string) {
+		t.Helper()
+
+		filename := filepath.Join(t.TempDir(), "test_data")
+
+		pgr, err := data.NewPager(filename, psize)
+		if err != nil {
+			t.Fatalf(
+				"Failed to create pager by path %s, with error %s",
+				filename, err,
+			)
+		}
+
+		for i := 0; i < n; i++ {
+			pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+			pg.Write([]byte("data"))
+
+			if err := pgr.Write(pg); err != nil {
+				t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+			}
+		}
+
+		return pgr, filename
+	}
+
+	t.Run("free tail", func(t *testing.T) {
+		pgr, filename := newPopulatedPager(t, 5)
+		defer pgr.Close()
+
+		max := pgr.Freelist().Max
+		pgr.Freelist().Release(max - 1)
+		pgr.Freelist().Release(max - 2)
+
+		reclaimed, err := pgr.ShrinkToFit()
+		if err != nil {
+			t.Fatalf("Failed to shrink pager, with error %s", err)
+		}
+
+		if expected := int64(2 * psize); reclaimed != expected {
+			t.Fatalf(
+				"Failed to compare reclaimed bytes: expected %d, actual %d",
+				expected, reclaimed,
+			)
+		}
+
+		if pgr.Freelist().Max != max-2 || len(pgr.Freelist().Released) != 0 {
+			t.Fatalf("Failed to check freelist after shrink: %+v", pgr.Freelist())
+		}
+
+		info, err := os.Stat(filename)
+		if err != nil {
+			t.Fatalf("Failed to stat file %s, with error %s", filename, err)
+		}
+
+		if expected := int64(max-2) * int64(psize); info.Size() != expected {
+			t.Fatalf(
+				"Failed to compare file size: expected %d, actual %d",
+				expected, info.Size(),
+			)
+		}
+	})
+
+	t.Run("holes in the middle", func(t *testing.T) {
+		pgr, _ := newPopulatedPager(t, 5)
+		defer pgr.Close()
+
+		max := pgr.Freelist().Max
+		pgr.Freelist().Release(data.BeginFreeBlocks + 2)
+
+		reclaimed, err := pgr.ShrinkToFit()
+		if err != nil {
+			t.Fatalf("Failed to shrink pager, with error %s", err)
+		}
+
+		if reclaimed != 0 || pgr.Freelist().Max != max {
+			t.Fatalf(
+				"Failed to check shrink without free tail: reclaimed %d, freelist %+v",
+				reclaimed, pgr.Freelist(),
+			)
+		}
+	})
+}
+
+func TestPager_Truncate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	for i := 0; i < 6; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+	}
+
+	max := pgr.Freelist().Max
+	for _, num := range []data.PageNum{max - 2, max - 1, max - 3} {
+		pgr.Freelist().Release(num)
+	}
+
+	if err := pgr.Truncate(); err != nil {
+		t.Fatalf("Failed to truncate pager, with error %s", err)
+	}
+
+	if pgr.Freelist().Max != max-3 {
+		t.Fatalf(
+			"Failed to compare freelist max: expected %d, actual %d",
+			max-3, pgr.Freelist().Max,
+		)
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("Failed to stat file %s, with error %s", filename, err)
+	}
+
+	if expected := int64(max-3) * int64(psize); info.Size() != expected {
+		t.Fatalf(
+			"Failed to compare file size: expected %d, actual %d",
+			expected, info.Size(),
+		)
+	}
+}
+
+func TestPager_Grow(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	first, err := pgr.Grow(100)
+	if err != nil {
+		t.Fatalf("Failed to grow pager, with error %s", err)
+	}
+
+	if pgr.Freelist().Max != first+100 {
+		t.Fatalf(
+			"Failed to compare freelist max: expected %d, actual %d",
+			first+100, pgr.Freelist().Max,
+		)
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("Failed to stat file %s, with error %s", filename, err)
+	}
+
+	if expected := int64(first+100) * int64(psize); info.Size() != expected {
+		t.Fatalf(
+			"Failed to compare file size: expected %d, actual %d",
+			expected, info.Size(),
+		)
+	}
+
+	for _, num := range []data.PageNum{first, first + 99} {
+		pg := pgr.Alloc().WithNum(num)
+		pg.Write([]byte(fmt.Sprintf("data%d", num)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+
+		actualPg, err := pgr.Read(num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", num, err)
+		}
+
+		if !bytes.Equal(pg.Data, actualPg.Data) {
+			t.Fatalf("Failed to compare page %d data", num)
+		}
+	}
+}
+
+func TestPager_GrowFailure(t *testing.T) {
+	psize := os.Getpagesize()
+	be := new(failingBackend)
+
+	pgr, err := data.NewPagerFromBackend(be, psize)
+	if err != nil {
+		t.Fatalf("Failed to create pager from backend, with error %s", err)
+	}
+	defer pgr.Close()
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+	max := pgr.Freelist().Max
+
+	be.failTruncate = true
+	if _, err := pgr.Grow(10); !errors.Is(err, errBackend) {
+		t.Fatalf("Failed to grow pager: expected error %s, actual %v", errBackend, err)
+	}
+	be.failTruncate = false
+
+	if pgr.Freelist().Max != max {
+		t.Fatalf("Failed to keep freelist max after failed grow: expected %d, actual %d", max, pgr.Freelist().Max)
+	}
+	if changed, err := pgr.Flush(); err != nil || changed {
+		t.Fatalf("Failed to flush pager after failed grow: changed %t, error %v", changed, err)
+	}
+
+	first, err := pgr.Grow(10)
+	if err != nil {
+		t.Fatalf("Failed to grow pager, with error %s", err)
+	}
+	if first != max {
+		t.Fatalf("Failed to compare first grown page: expected %d, actual %d", max, first)
+	}
+}
+
+func TestPager_ValidateAndTruncate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	var pages []*data.Page
+	for i := 0; i < 3; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := pgr.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		pages = append(pages, pg)
+	}
+
+	if _, err := pgr.Flush(); err != nil {
+		t.Fatalf("Failed to flush pager, with error %s", err)
+	}
+
+	garbage := bytes.Repeat([]byte{0xde, 0xad}, psize+psize/4)
+
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_APPEND, data.DefaultFilePerm)
+	if err != nil {
+		t.Fatalf("Failed to open file %s, with error %s", filename, err)
+	}
+	if _, err := f.Write(garbage); err != nil {
+		t.Fatalf("Failed to append garbage, with error %s", err)
+	}
+	_ = f.Close()
+
+	trimmed, err := pgr.ValidateAndTruncate()
+	if err != nil {
+		t.Fatalf("Failed to validate and truncate pager, with error %s", err)
+	}
+
+	if trimmed != int64(len(garbage)) {
+		t.Fatalf(
+			"Failed to compare trimmed bytes: expected %d, actual %d",
+			len(garbage), trimmed,
+		)
+	}
+
+	for _, expectedPg := range pages {
+		actualPg, err := pgr.Read(expectedPg.Num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", expectedPg.Num, err)
+		}
+
+		if !bytes.Equal(expectedPg.Data, actualPg.Data) {
+			t.Fatalf("Failed to compare page %d data after truncation", expectedPg.Num)
+		}
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("Failed to stat file %s, with error %s", filename, err)
+	}
+
+	if expected := int64(pgr.Freelist().Max) * int64(psize); info.Size() != expected {
+		t.Fatalf(
+			"Failed to compare file size: expected %d, actual %d",
+			expected, info.Size(),
+		)
+	}
+}
+
+func TestPager_ValidateAndTruncateTorn(t *testing.T) {
+	psize := data.MinPageSize
+
+	fileSize := func(t *testing.T, filename string) int64 {
+		t.Helper()
+
+		info, err := os.Stat(filename)
+		if err != nil {
+			t.Fatalf("Failed to stat file %s, with error %s", filename, err)
+		}
+		return info.Size()
+	}
+
+	// setup returns the pager, its file, the pages written and the file
+	// size before the corruption.
+	setup := func(t *testing.T, opts ...data.PagerOption) (*data.Pager, string, []*data.Page, int64) {
+		t.Helper()
+
+		filename := filepath.Join(t.TempDir(), "test_data")
+
+		pgr, err := data.NewPagerWithOptions(filename, psize, opts...)
+		if err != nil {
+			t.Fatalf(
+				"Failed to create pager by path %s, with error %s",
+				filename, err,
+			)
+		}
+		t.Cleanup(func() { _ = pgr.Close() })
+
+		var pages []*data.Page
+		for i := 0; i < 5; i++ {
+			pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+			pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+			if err := pgr.Write(pg); err != nil {
+				t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+			}
+			pages = append(pages, pg)
+		}
+		if _, err := pgr.Flush(); err != nil {
+			t.Fatalf("Failed to flush pager, with error %s", err)
+		}
+
+		size := fileSize(t, filename)
+
+		// The last two pages are torn, and garbage follows them.
+		f, err := os.OpenFile(filename, os.O_RDWR, data.DefaultFilePerm)
+		if err != nil {
+			t.Fatalf("Failed to open file %s, with error %s", filename, err)
+		}
+		defer f.Close()
+
+		for _, pg := range pages[3:] {
+			if _, err := f.WriteAt([]byte{0xde, 0xad}, int64(pg.Num)*int64(psize)+int64(psize/2)); err != nil {
+				t.Fatalf("Failed to corrupt page %d, with error %s", pg.Num, err)
+			}
+		}
+		if _, err := f.WriteAt(bytes.Repeat([]byte{0xde, 0xad}, psize), int64(pgr.Freelist().Max)*int64(psize)); err != nil {
+			t.Fatalf("Failed to append garbage, with error %s", err)
+		}
+
+		return pgr, filename, pages, size
+	}
+
+	t.Run("torn pages", func(t *testing.T) {
+		pgr, filename, pages, _ := setup(t)
+
+		if _, err := pgr.ValidateAndTruncate(); err != nil {
+			t.Fatalf("Failed to validate and truncate pager, with error %s", err)
+		}
+
+		if expected := pages[3].Num; pgr.Freelist().Max != expected {
+			t.Fatalf("Failed to compare freelist max: expected %d, actual %d", expected, pgr.Freelist().Max)
+		}
+		if expected := int64(pages[3].Num) * int64(psize); fileSize(t, filename) != expected {
+			t.Fatalf("Failed to compare file size: expected %d, actual %d", expected, fileSize(t, filename))
+		}
+
+		// The lowered Max is flushed, so a crash right after the
+		// truncation does not leave a freelist reaching past the file.
+		stored, err := pgr.ReadFreelistAt(pgr.Meta().Freelist)
+		if err != nil {
+			t.Fatalf("Failed to read stored freelist, with error %s", err)
+		}
+		if stored.Max != pgr.Freelist().Max {
+			t.Fatalf("Failed to compare stored freelist max: expected %d, actual %d", pgr.Freelist().Max, stored.Max)
+		}
+
+		for _, expectedPg := range pages[:3] {
+			actualPg, err := pgr.Read(expectedPg.Num)
+			if err != nil {
+				t.Fatalf("Failed to read page %d, with error %s", expectedPg.Num, err)
+			}
+			if !bytes.Equal(expectedPg.Data, actualPg.Data) {
+				t.Fatalf("Failed to compare page %d data after truncation", expectedPg.Num)
+			}
+		}
+	})
+
+	t.Run("grow chunk", func(t *testing.T) {
+		const chunk = 8
+
+		pgr, filename, pages, before := setup(t, data.WithGrowChunk(chunk))
+
+		if _, err := pgr.ValidateAndTruncate(); err != nil {
+			t.Fatalf("Failed to validate and truncate pager, with error %s", err)
+		}
+
+		if expected := pages[3].Num; pgr.Freelist().Max != expected {
+			t.Fatalf("Failed to compare freelist max: expected %d, actual %d", expected, pgr.Freelist().Max)
+		}
+
+		end := int64(pgr.Freelist().Max) * int64(psize)
+		expected := min(before, end+chunk*int64(psize))
+		if fileSize(t, filename) != expected {
+			t.Fatalf("Failed to keep preallocated pages: expected %d bytes, actual %d", expected, fileSize(t, filename))
+		}
+
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("Failed to read file %s, with error %s", filename, err)
+		}
+		if i := bytes.IndexFunc(b[end:], func(r rune) bool { return r != 0 }); i >= 0 {
+			t.Fatalf("Failed to zero preallocated pages: byte %d past max is set", i)
+		}
+	})
+}
+
+func TestPager_AutoShrink(t *testing.T) {
+	psize := data.MinPageSize
+
+	for name, tc := range map[string]struct {
+		opts    []data.PagerOption
+		release []int
+		shrunk  int
+	}{
+		"top down":     {release: []int{4, 3, 2}, shrunk: 3},
+		"bottom up":    {release: []int{2, 3, 4}, shrunk: 3},
+		"lowest first": {opts: []data.PagerOption{data.WithAllocStrategy(data.LowestFirst)}, release: []int{3, 2, 4}, shrunk: 3},
+		"below top":    {release: []int{1, 2, 3}, shrunk: 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "test_data")
+
+			opts := append([]data.PagerOption{data.WithAutoShrink(true)}, tc.opts...)
+			pgr, err := data.NewPagerWithOptions(filename, psize, opts...)
+			if err != nil {
+				t.Fatalf(
+					"Failed to create pager by path %s, with error %s",
+					filename, err,
+				)
+			}
+			defer pgr.Close()
+
+			var nums []data.PageNum
+			for i := 0; i < 5; i++ {
+				pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+				pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+				if err := pgr.Write(pg); err != nil {
+					t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+				}
+				nums = append(nums, pg.Num)
+			}
+			if _, err := pgr.Flush(); err != nil {
+				t.Fatalf("Failed to flush pager, with error %s", err)
+			}
+
+			max := pgr.Freelist().Max
+			info, err := os.Stat(filename)
+			if err != nil {
+				t.Fatalf("Failed to stat file %s, with error %s", filename, err)
+			}
+			size := info.Size()
+
+			for _, i := range tc.release {
+				if err := pgr.ReleasePage(nums[i]); err != nil {
+					t.Fatalf("Failed to release page %d, with error %s", nums[i], err)
+				}
+			}
+			if _, err := pgr.Flush(); err != nil {
+				t.Fatalf("Failed to flush pager, with error %s", err)
+			}
+
+			if expected := max - data.PageNum(tc.shrunk); pgr.Freelist().Max != expected {
+				t.Fatalf("Failed to compare freelist max: expected %d, actual %d", expected, pgr.Freelist().Max)
+			}
+			if expected := len(tc.release) - tc.shrunk; pgr.Freelist().Count() != expected {
+				t.Fatalf("Failed to compare free pages: expected %d, actual %d", expected, pgr.Freelist().Count())
+			}
+
+			info, err = os.Stat(filename)
+			if err != nil {
+				t.Fatalf("Failed to stat file %s, with error %s", filename, err)
+			}
+			if expected := size - int64(tc.shrunk*psize); info.Size() != expected {
+				t.Fatalf("Failed to compare file size: expected %d, actual %d", expected, info.Size())
+			}
+
+			pg, err := pgr.Read(nums[0])
+			if err != nil {
+				t.Fatalf("Failed to read page %d after shrink, with error %s", nums[0], err)
+			}
+			if !bytes.HasPrefix(pg.Data, []byte("data1")) {
+				t.Fatalf("Failed to compare page %d data after shrink", nums[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Tx groups page writes that reach the store all together or not at all.
+// Writes are buffered until Commit, which logs them to the write-ahead log
+// next to the store, syncs the log and only then applies the pages and
+// flushes the pager. Recovery replays a committed log that was not
+// applied completely. Pagers without a path, such as in-memory ones,
+// apply commits without a log.
+type Tx struct {
+	pgr *Pager
+
+	pages map[PageNum][]byte
+	order []PageNum
+
+	// max, released and pending snapshot the freelist at Begin for
+	// Rollback.
+	max      PageNum
+	released []PageNum
+	pending  map[uint64][]PageNum
+
+	done bool
+}
+
+// Begin starts a transaction. Only one transaction can be in progress at
+// a time, writes outside of it go to the store directly as before.
+func (pgr *Pager) Begin() (*Tx, error) {
+	if pgr.opts.ReadOnly {
+		return nil, fmt.Errorf("pager/begin: %w", ErrReadOnly)
+	}
+
+	pgr.commitMu.Lock()
+	defer pgr.commitMu.Unlock()
+
+	if pgr.tx != nil {
+		return nil, fmt.Errorf("pager/begin: %w", ErrTxInProgress)
+	}
+
+	pgr.mu.RLock()
+	defer pgr.mu.RUnlock()
+
+	if pgr.closed {
+		return nil, fmt.Errorf("pager/begin: %w", ErrClosed)
+	}
+
+	pgr.tx = &Tx{
+		pgr: pgr,
+
+		pages: make(map[PageNum][]byte),
+
+		max:      pgr.flist.Max,
+		released: slices.Clone(pgr.flist.Released),
+		pending:  clonePending(pgr.flist.Pending),
+	}
+
+	return pgr.tx, nil
+}
+
+// Write buffers the page until Commit. The page is sealed right away, so
+// errors about its size surface here rather than on Commit.
+func (tx *Tx) Write(pg *Page) error {
+	if tx.done {
+		return fmt.Errorf("tx/write(num=%d): %w", pg.Num, ErrTxDone)
+	}
+
+	if tx.pgr.opts.StrictWrites && len(pg.Data) != tx.pgr.payloadSize() {
+		return fmt.Errorf(
+			"tx/write(num=%d,size=%d): %w",
+			pg.Num, len(pg.Data), ErrWrongPageSize,
+		)
+	}
+
+	b, err := tx.pgr.sealPage(pg.Num, pg.typ, pg.Data)
+	if err != nil {
+		return fmt.Errorf("tx/write(num=%d,size=%d): %w", pg.Num, len(pg.Data), err)
+	}
+
+	if _, ok := tx.pages[pg.Num]; !ok {
+		tx.order = append(tx.order, pg.Num)
+	}
+	tx.pages[pg.Num] = b
+
+	return nil
+}
+
+// Read returns the page as written in the transaction, or as stored when
+// the transaction did not write it.
+func (tx *Tx) Read(num PageNum) (*Page, error) {
+	if tx.done {
+		return nil, fmt.Errorf("tx/read(num=%d): %w", num, ErrTxDone)
+	}
+
+	b, ok := tx.pages[num]
+	if !ok {
+		return tx.pgr.Read(num)
+	}
+
+	pg := tx.pgr.Alloc().WithNum(num).WithType(tx.pgr.storedType(num, b))
+	if err := tx.pgr.openPage(num, b, pg.Data); err != nil {
+		return nil, fmt.Errorf("tx/read(num=%d): %w", num, err)
+	}
+
+	return pg, nil
+}
+
+// Commit logs the transaction, applies it to the store and flushes the
+// pager. Once the log is synced the transaction survives a crash, even if
+// applying it does not complete. A commit that fails to log leaves the
+// transaction in progress, to be committed again or rolled back. A closed
+// pager returns ErrClosed.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return fmt.Errorf("tx/commit: %w", ErrTxDone)
+	}
+
+	pgr := tx.pgr
+
+	pgr.commitMu.Lock()
+	defer pgr.commitMu.Unlock()
+
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.closed {
+		return fmt.Errorf("tx/commit: %w", ErrClosed)
+	}
+
+	if err := tx.log(); err != nil {
+		return fmt.Errorf("tx/commit: %w", err)
+	}
+
+	tx.done = true
+	pgr.tx = nil
+
+	if err := tx.apply(); err != nil {
+		return fmt.Errorf("tx/commit: %w", err)
+	}
+
+	if err := pgr.flush(context.Background()); err != nil {
+		return fmt.Errorf("tx/commit: %w", err)
+	}
+
+	if pgr.wal != nil {
+		if err := pgr.wal.reset(!pgr.opts.NoSync); err != nil {
+			return fmt.Errorf("tx/commit: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Rollback discards the buffered writes and restores the freelist to its
+// state at Begin, pages released or left pending for readers since
+// included. A closed pager returns ErrClosed.
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return fmt.Errorf("tx/rollback: %w", ErrTxDone)
+	}
+
+	pgr := tx.pgr
+
+	pgr.commitMu.Lock()
+	defer pgr.commitMu.Unlock()
+
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.closed {
+		return fmt.Errorf("tx/rollback: %w", ErrClosed)
+	}
+
+	tx.done = true
+	pgr.tx = nil
+
+	pgr.flist.Max = tx.max
+	pgr.flist.Released = tx.released
+	pgr.flist.Pending = tx.pending
+	pgr.flist.set(flistDirty, true)
+
+	return nil
+}
+
+// log writes the transaction to the write-ahead log. The transaction id
+// is the TxID of the flush that completes the commit.
+func (tx *Tx) log() error {
+	pgr := tx.pgr
+	if pgr.path == "" {
+		return nil
+	}
+
+	if pgr.wal == nil {
+		w, err := openWAL(pgr.path, pgr.opts.filePerm(), !pgr.opts.NoSync)
+		if err != nil {
+			return err
+		}
+		pgr.wal = w
+	}
+
+	id := pgr.meta.TxID + 1
+
+	recs := make([]walRecord, 0, len(tx.order)+1)
+	for _, num := range tx.order {
+		recs = append(recs, walRecord{TxID: id, Num: num, Payload: tx.pages[num]})
+	}
+	flistb, err := pgr.encryptBlob(walCommitNum, pgr.flist.Serialize())
+	if err != nil {
+		return err
+	}
+	recs = append(recs, walRecord{TxID: id, Num: walCommitNum, Payload: flistb})
+
+	return pgr.wal.log(recs, !pgr.opts.NoSync)
+}
+
+func (tx *Tx) apply() error {
+	for _, num := range tx.order {
+		if err := tx.pgr.writeSealed(num, tx.pages[num]); err != nil {
+			return fmt.Errorf("apply page %d: %w", num, err)
+		}
+	}
+
+	return nil
+}
+
+// replayWAL applies a transaction that was committed to the log but may
+// not have been applied to the store. When the flush completing it did
+// not happen either, the freelist is restored from the commit record.
+func (pgr *Pager) replayWAL() error {
+	if pgr.path == "" || pgr.opts.ReadOnly {
+		return nil
+	}
+
+	exists, err := isFsEntryExists(walPath(pgr.path))
+	if err != nil || !exists {
+		return err
+	}
+
+	w, err := openWAL(pgr.path, pgr.opts.filePerm(), !pgr.opts.NoSync)
+	if err != nil {
+		return err
+	}
+	pgr.wal = w
+
+	recs, err := w.records()
+	if err != nil {
+		return err
+	}
+
+	committed := committedWAL(recs)
+	if committed == nil {
+		return w.reset(!pgr.opts.NoSync)
+	}
+
+	for _, rec := range committed[:len(committed)-1] {
+		if len(rec.Payload) != pgr.psize {
+			return fmt.Errorf("replay wal: page %d: %w", rec.Num, ErrWrongBytes)
+		}
+
+		if err := pgr.writeSealed(rec.Num, rec.Payload); err != nil {
+			return fmt.Errorf("replay wal: page %d: %w", rec.Num, err)
+		}
+	}
+
+	commit := committed[len(committed)-1]
+	if pgr.meta.TxID < commit.TxID {
+		flistb, err := pgr.decryptBlob(walCommitNum, commit.Payload)
+		if err != nil {
+			return fmt.Errorf("replay wal: %w", err)
+		}
+
+		flist := NewFreelist()
+		flist.Order, flist.begin = pgr.meta.Order, pgr.flist.begin
+		if err := flist.Deserialize(flistb); err != nil {
+			return fmt.Errorf("replay wal: %w", err)
+		}
+
+		pgr.flist.Max = flist.Max
+		pgr.flist.Released = flist.Released
+		pgr.flist.set(flistDirty, true)
+	}
+
+	if err := pgr.flush(context.Background()); err != nil {
+		return fmt.Errorf("replay wal: %w", err)
+	}
+
+	return w.reset(!pgr.opts.NoSync)
+}
@@ -0,0 +1,239 @@
+package data_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_Tx(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+
+	tx, err := pgr.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction, with error %s", err)
+	}
+
+	if _, err := pgr.Begin(); !errors.Is(err, data.ErrTxInProgress) {
+		t.Fatalf(
+			"Failed to begin second transaction: expected error %s, actual %v",
+			data.ErrTxInProgress, err,
+		)
+	}
+
+	var pages []*data.Page
+	for i := 0; i < 3; i++ {
+		pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+		pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+		if err := tx.Write(pg); err != nil {
+			t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+		}
+		pages = append(pages, pg)
+	}
+
+	pg, err := tx.Read(pages[0].Num)
+	if err != nil {
+		t.Fatalf("Failed to read page %d in transaction, with error %s", pages[0].Num, err)
+	}
+	if !bytes.Equal(pages[0].Data, pg.Data) {
+		t.Fatalf("Failed to compare page %d data in transaction", pages[0].Num)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit transaction, with error %s", err)
+	}
+
+	if err := tx.Commit(); !errors.Is(err, data.ErrTxDone) {
+		t.Fatalf(
+			"Failed to commit transaction twice: expected error %s, actual %v",
+			data.ErrTxDone, err,
+		)
+	}
+
+	rolledBack, err := pgr.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction, with error %s", err)
+	}
+
+	max := pgr.Freelist().Max
+	discarded := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+	if err := rolledBack.Write(discarded); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", discarded, err)
+	}
+
+	if err := rolledBack.Rollback(); err != nil {
+		t.Fatalf("Failed to roll back transaction, with error %s", err)
+	}
+
+	if pgr.Freelist().Max != max {
+		t.Fatalf(
+			"Failed to compare freelist max after rollback: expected %d, actual %d",
+			max, pgr.Freelist().Max,
+		)
+	}
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	reopened, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to reopen pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer reopened.Close()
+
+	for _, expectedPg := range pages {
+		actualPg, err := reopened.Read(expectedPg.Num)
+		if err != nil {
+			t.Fatalf("Failed to read page %d, with error %s", expectedPg.Num, err)
+		}
+
+		if !bytes.Equal(expectedPg.Data, actualPg.Data) {
+			t.Fatalf("Failed to compare page %d data after commit", expectedPg.Num)
+		}
+	}
+
+	if reopened.Freelist().Max != max {
+		t.Fatalf(
+			"Failed to compare freelist max after reopen: expected %d, actual %d",
+			max, reopened.Freelist().Max,
+		)
+	}
+}
+
+func TestPager_TxRollbackPending(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	tx, err := pgr.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction, with error %s", err)
+	}
+
+	num := nextFree(t, pgr.Freelist())
+	pgr.Freelist().ReleasePending(1, num)
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Failed to roll back transaction, with error %s", err)
+	}
+
+	if len(pgr.Freelist().Pending) != 0 {
+		t.Fatalf(
+			"Failed to compare pending pages after rollback: expected none, actual %v",
+			pgr.Freelist().Pending,
+		)
+	}
+}
+
+func TestPager_TxClosed(t *testing.T) {
+	pgr, err := data.NewMemPager(512)
+	if err != nil {
+		t.Fatalf("Failed to create in-memory pager, with error %s", err)
+	}
+
+	tx, err := pgr.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction, with error %s", err)
+	}
+
+	pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+	pg.Write([]byte("data"))
+	if err := tx.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	if err := pgr.Close(); err != nil {
+		t.Fatalf("Failed to close pager, with error %s", err)
+	}
+
+	if err := tx.Commit(); !errors.Is(err, data.ErrClosed) {
+		t.Fatalf("Failed to commit on closed pager: expected error %s, actual %v", data.ErrClosed, err)
+	}
+	if err := tx.Rollback(); !errors.Is(err, data.ErrClosed) {
+		t.Fatalf("Failed to roll back on closed pager: expected error %s, actual %v", data.ErrClosed, err)
+	}
+}
+
+func TestPager_TxCommitLogFailure(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_data")
+	psize := os.Getpagesize()
+
+	pgr, err := data.NewPager(filename, psize)
+	if err != nil {
+		t.Fatalf(
+			"Failed to create pager by path %s, with error %s",
+			filename, err,
+		)
+	}
+	defer pgr.Close()
+
+	// A directory in place of the log makes opening it fail.
+	if err := os.Mkdir(filename+".wal", 0o755); err != nil {
+		t.Fatalf("Failed to create directory, with error %s", err)
+	}
+
+	tx, err := pgr.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction, with error %s", err)
+	}
+
+	pg := pgr.Alloc().WithNum(nextFree(t, pgr.Freelist()))
+	pg.Write([]byte("data"))
+	if err := tx.Write(pg); err != nil {
+		t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+	}
+
+	if err := tx.Commit(); err == nil {
+		t.Fatalf("Failed to commit transaction: expected error, actual nil")
+	}
+
+	if _, err := pgr.Begin(); !errors.Is(err, data.ErrTxInProgress) {
+		t.Fatalf(
+			"Failed to begin transaction after failed commit: expected error %s, actual %v",
+			data.ErrTxInProgress, err,
+		)
+	}
+
+	if err := os.Remove(filename + ".wal"); err != nil {
+		t.Fatalf("Failed to remove directory, with error %s", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit transaction again, with error %s", err)
+	}
+
+	actualPg, err := pgr.Read(pg.Num)
+	if err != nil {
+		t.Fatalf("Failed to read page %d, with error %s", pg.Num, err)
+	}
+	if !bytes.Equal(pg.Data, actualPg.Data) {
+		t.Fatalf("Failed to compare page %d data after commit", pg.Num)
+	}
+}
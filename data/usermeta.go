@@ -0,0 +1,149 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// MetaUserCapacity is the most bytes the user fields of the metainfo
+// take in the meta page, keys and values counted with their length
+// prefixes.
+const MetaUserCapacity = 256
+
+// SetUser stores val under key in the user fields of the metainfo, for
+// the few bytes of metadata of an application, such as a schema version.
+// A nil val removes key. It returns ErrDataTooLarge when the fields would
+// no longer fit in MetaUserCapacity, leaving them as they were.
+func (meta *Metainfo) SetUser(key string, val []byte) error {
+	if val == nil {
+		delete(meta.user, key)
+		return nil
+	}
+
+	size := userSize(meta.user) + userEntrySize(key, val)
+	if old, ok := meta.user[key]; ok {
+		size -= userEntrySize(key, old)
+	}
+	if size > MetaUserCapacity {
+		return fmt.Errorf(
+			"meta/setUser(key=%q): %d bytes, max %d: %w",
+			key, size, MetaUserCapacity, ErrDataTooLarge,
+		)
+	}
+
+	if meta.user == nil {
+		meta.user = make(map[string][]byte)
+	}
+	meta.user[key] = bytes.Clone(val)
+
+	return nil
+}
+
+// GetUser returns a copy of the value stored under key in the user
+// fields.
+func (meta *Metainfo) GetUser(key string) ([]byte, bool) {
+	val, ok := meta.user[key]
+	if !ok {
+		return nil, false
+	}
+
+	return bytes.Clone(val), true
+}
+
+// SetUserMeta is Metainfo.SetUser on the metainfo of the pager, made
+// durable by the next flush.
+func (pgr *Pager) SetUserMeta(key string, val []byte) error {
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.opts.ReadOnly {
+		return fmt.Errorf("pager/setUserMeta(key=%q): %w", key, ErrReadOnly)
+	}
+
+	if err := pgr.meta.SetUser(key, val); err != nil {
+		return fmt.Errorf("pager/setUserMeta: %w", err)
+	}
+
+	return nil
+}
+
+// UserMeta is Metainfo.GetUser on the metainfo of the pager.
+func (pgr *Pager) UserMeta(key string) ([]byte, bool) {
+	pgr.mu.RLock()
+	defer pgr.mu.RUnlock()
+
+	return pgr.meta.GetUser(key)
+}
+
+// userEntrySize is the encoded size of one user field: a one byte key
+// length, the key, a two byte value length and the value.
+func userEntrySize(key string, val []byte) int {
+	return 1 + len(key) + 2 + len(val)
+}
+
+func userSize(user map[string][]byte) int {
+	size := 0
+	for key, val := range user {
+		size += userEntrySize(key, val)
+	}
+	return size
+}
+
+// appendUser appends the user fields to b in key order, after a two byte
+// length of the whole area.
+func (meta *Metainfo) appendUser(b []byte) []byte {
+	order := meta.Order.binary()
+
+	b = append(b, 0, 0)
+	order.PutUint16(b[len(b)-2:], uint16(userSize(meta.user)))
+	for _, key := range slices.Sorted(maps.Keys(meta.user)) {
+		val := meta.user[key]
+
+		b = append(b, byte(len(key)))
+		b = append(b, key...)
+		b = append(b, 0, 0)
+		order.PutUint16(b[len(b)-2:], uint16(len(val)))
+		b = append(b, val...)
+	}
+
+	return b
+}
+
+// decodeUser reads the user fields written by appendUser from the start
+// of b.
+func (meta *Metainfo) decodeUser(b []byte) error {
+	order := meta.Order.binary()
+
+	if len(b) < 2 {
+		return fmt.Errorf("decode user fields: %w", ErrWrongBytes)
+	}
+	size := int(order.Uint16(b))
+	if size > MetaUserCapacity || len(b) < 2+size {
+		return fmt.Errorf("decode user fields: %d bytes: %w", size, ErrWrongBytes)
+	}
+
+	meta.user = nil
+	for area := b[2 : 2+size]; len(area) > 0; {
+		klen := int(area[0])
+		if len(area) < 1+klen+2 {
+			return fmt.Errorf("decode user fields: %w", ErrWrongBytes)
+		}
+		key := string(area[1 : 1+klen])
+
+		vlen := int(order.Uint16(area[1+klen:]))
+		area = area[1+klen+2:]
+		if len(area) < vlen {
+			return fmt.Errorf("decode user field %q: %w", key, ErrWrongBytes)
+		}
+
+		if meta.user == nil {
+			meta.user = make(map[string][]byte)
+		}
+		meta.user[key] = bytes.Clone(area[:vlen])
+		area = area[vlen:]
+	}
+
+	return nil
+}
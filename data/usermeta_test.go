@@ -0,0 +1,114 @@
+package data_test
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestMetainfo_User(t *testing.T) {
+	t.Run("serialize and deserialize", func(t *testing.T) {
+		meta := data.NewMetainfo()
+		meta.Order = data.BigEndian
+
+		if err := meta.SetUser("schema", []byte{0, 3}); err != nil {
+			t.Fatalf("Failed to set user field, with error %s", err)
+		}
+		if err := meta.SetUser("app", []byte("embed")); err != nil {
+			t.Fatalf("Failed to set user field, with error %s", err)
+		}
+		if err := meta.SetUser("empty", []byte{}); err != nil {
+			t.Fatalf("Failed to set user field, with error %s", err)
+		}
+
+		actual := new(data.Metainfo)
+		if err := actual.Deserialize(meta.Serialize()); err != nil {
+			t.Fatalf("Failed to deserialize metainfo, with error %s", err)
+		}
+
+		if !meta.Equal(actual) {
+			t.Fatalf("Failed to compare metainfo: expected %+v, actual %+v", meta, actual)
+		}
+
+		for key, expected := range map[string][]byte{"schema": {0, 3}, "app": []byte("embed"), "empty": {}} {
+			val, ok := actual.GetUser(key)
+			if !ok || !bytes.Equal(val, expected) {
+				t.Fatalf("Failed to compare user field %q: expected %q, actual %q (found %t)", key, expected, val, ok)
+			}
+		}
+
+		if err := actual.SetUser("app", nil); err != nil {
+			t.Fatalf("Failed to remove user field, with error %s", err)
+		}
+		if _, ok := actual.GetUser("app"); ok {
+			t.Fatalf("Failed to remove user field %q", "app")
+		}
+	})
+
+	t.Run("over capacity", func(t *testing.T) {
+		meta := data.NewMetainfo()
+
+		if err := meta.SetUser("blob", make([]byte, data.MetaUserCapacity)); !errors.Is(err, data.ErrDataTooLarge) {
+			t.Fatalf(
+				"Failed to set oversized user field: expected error %s, actual %v",
+				data.ErrDataTooLarge, err,
+			)
+		}
+
+		half := make([]byte, data.MetaUserCapacity/2)
+		if err := meta.SetUser("first", half); err != nil {
+			t.Fatalf("Failed to set user field, with error %s", err)
+		}
+		if err := meta.SetUser("second", half); !errors.Is(err, data.ErrDataTooLarge) {
+			t.Fatalf(
+				"Failed to set user field past capacity: expected error %s, actual %v",
+				data.ErrDataTooLarge, err,
+			)
+		}
+
+		// Replacing a field only counts its new value.
+		if err := meta.SetUser("first", half); err != nil {
+			t.Fatalf("Failed to replace user field, with error %s", err)
+		}
+		if _, ok := meta.GetUser("second"); ok {
+			t.Fatalf("Failed to leave user fields as they were after an error")
+		}
+	})
+
+	t.Run("pager", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "test_data")
+
+		pgr, err := data.NewPager(filename, data.MinPageSize)
+		if err != nil {
+			t.Fatalf(
+				"Failed to create pager by path %s, with error %s",
+				filename, err,
+			)
+		}
+
+		full := make([]byte, data.MetaUserCapacity-1-len("k")-2)
+		if err := pgr.SetUserMeta("k", full); err != nil {
+			t.Fatalf("Failed to set user meta, with error %s", err)
+		}
+
+		if err := pgr.Close(); err != nil {
+			t.Fatalf("Failed to close pager, with error %s", err)
+		}
+
+		reopened, err := data.NewPager(filename, data.MinPageSize)
+		if err != nil {
+			t.Fatalf(
+				"Failed to reopen pager by path %s, with error %s",
+				filename, err,
+			)
+		}
+		defer reopened.Close()
+
+		if val, ok := reopened.UserMeta("k"); !ok || !bytes.Equal(val, full) {
+			t.Fatalf("Failed to compare user meta after reopen: expected %d bytes, actual %d (found %t)", len(full), len(val), ok)
+		}
+	})
+}
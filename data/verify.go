@@ -0,0 +1,124 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Verify checks that the store is internally consistent: the current meta
+// page and the stored freelist decode, the freelist is within bounds and
+// free of duplicates, every page in the file passes its checksum and the
+// pages of the pager's own structures carry their page type. It reports
+// every problem found, joined with errors.Join.
+func (pgr *Pager) Verify() error {
+	pgr.mu.RLock()
+	defer pgr.mu.RUnlock()
+
+	if pgr.closed {
+		return fmt.Errorf("pager/verify: %w", ErrClosed)
+	}
+
+	var problems []error
+	report := func(err error) {
+		problems = append(problems, fmt.Errorf("pager/verify: %w", err))
+	}
+
+	if _, err := pgr.readMetaSlot(metaSlot(pgr.meta.TxID)); err != nil {
+		report(err)
+	}
+
+	if b, _, err := pgr.readChain(pgr.meta.Freelist); err != nil {
+		report(fmt.Errorf("stored freelist: %w", err))
+	} else if err := (&Freelist{Order: pgr.meta.Order}).Deserialize(b); err != nil {
+		report(fmt.Errorf("stored freelist: %w", err))
+	}
+
+	for _, err := range pgr.flist.verify() {
+		report(err)
+	}
+
+	fileSize, err := pgr.storeSize()
+	if err != nil {
+		report(err)
+		return errors.Join(problems...)
+	}
+
+	want := pgr.structureTypes()
+
+	b := make([]byte, pgr.psize)
+	dst := make([]byte, pgr.payloadSize())
+	for num := PageNum(0); int64(num)*int64(pgr.psize) < fileSize; num++ {
+		if _, err := pgr.store.ReadAt(b, int64(num)*int64(pgr.psize)); err != nil {
+			report(fmt.Errorf("read page %d: %w", num, err))
+			continue
+		}
+
+		if err := pgr.openPage(num, b, dst); err != nil {
+			report(err)
+		}
+
+		if typ, ok := want[num]; ok && !isZero(b) && pgr.storedType(num, b) != typ {
+			report(fmt.Errorf(
+				"page %d: expected %s, stored %s: %w",
+				num, typ, pgr.storedType(num, b), ErrPageTypeMismatch,
+			))
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// structureTypes returns the page type expected for every page holding the
+// metainfo, the freelist, the size classes or the stable ids.
+func (pgr *Pager) structureTypes() map[PageNum]PageType {
+	want := make(map[PageNum]PageType)
+	for _, num := range metaSlots {
+		want[num] = PageTypeMeta
+	}
+
+	want[pgr.meta.Freelist] = PageTypeFreelist
+	for _, num := range pgr.flistChain.pages() {
+		want[num] = PageTypeFreelist
+	}
+
+	for _, num := range slices.Concat(pgr.classChain.pages(), pgr.idChain.pages()) {
+		want[num] = PageTypeOverflow
+	}
+
+	return want
+}
+
+// verify returns the violations of the freelist invariants.
+func (flist *Freelist) verify() []error {
+	var problems []error
+
+	if flist.Max < flist.begin {
+		problems = append(problems, fmt.Errorf("freelist: max %d: %w", flist.Max, ErrFreelistCorrupt))
+	}
+
+	free := slices.Clone(flist.Released)
+	for _, nums := range flist.Pending {
+		free = append(free, nums...)
+	}
+
+	seen := make(map[PageNum]struct{}, len(free))
+	for _, num := range free {
+		if num < flist.begin || num >= flist.Max {
+			problems = append(problems, fmt.Errorf(
+				"freelist: released page %d, max %d: %w",
+				num, flist.Max, ErrFreelistCorrupt,
+			))
+		}
+
+		if _, ok := seen[num]; ok {
+			problems = append(problems, fmt.Errorf(
+				"freelist: page %d released twice: %w",
+				num, ErrFreelistCorrupt,
+			))
+		}
+		seen[num] = struct{}{}
+	}
+
+	return problems
+}
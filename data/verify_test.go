@@ -0,0 +1,157 @@
+package data_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protomem/embedstore/data"
+)
+
+func TestPager_Verify(t *testing.T) {
+	psize := os.Getpagesize()
+
+	newPager := func(t *testing.T) (*data.Pager, string) {
+		t.Helper()
+
+		filename := filepath.Join(t.TempDir(), "test_data")
+
+		pgr, err := data.NewPager(filename, psize)
+		if err != nil {
+			t.Fatalf(
+				"Failed to create pager by path %s, with error %s",
+				filename, err,
+			)
+		}
+		t.Cleanup(func() { _ = pgr.Close() })
+
+		for i := 0; i < 5; i++ {
+			pg := pgr.Alloc().WithNum(nextPage(t, pgr))
+			pg.Write([]byte(fmt.Sprintf("data%d", i+1)))
+
+			if err := pgr.Write(pg); err != nil {
+				t.Fatalf("Failed to write page %+v, with error %s", pg, err)
+			}
+		}
+
+		if _, err := pgr.Flush(); err != nil {
+			t.Fatalf("Failed to flush pager, with error %s", err)
+		}
+
+		return pgr, filename
+	}
+
+	corrupt := func(t *testing.T, filename string, num data.PageNum) {
+		t.Helper()
+
+		f, err := os.OpenFile(filename, os.O_RDWR, data.DefaultFilePerm)
+		if err != nil {
+			t.Fatalf("Failed to open file %s, with error %s", filename, err)
+		}
+		defer f.Close()
+
+		if _, err := f.WriteAt([]byte{0xff}, int64(num)*int64(psize)+int64(psize)/2); err != nil {
+			t.Fatalf("Failed to corrupt page %d, with error %s", num, err)
+		}
+	}
+
+	problems := func(err error) int {
+		var joined interface{ Unwrap() []error }
+		if errors.As(err, &joined) {
+			return len(joined.Unwrap())
+		}
+		if err != nil {
+			return 1
+		}
+		return 0
+	}
+
+	t.Run("consistent", func(t *testing.T) {
+		pgr, _ := newPager(t)
+
+		if err := pgr.Verify(); err != nil {
+			t.Fatalf("Failed to verify consistent pager, with error %s", err)
+		}
+	})
+
+	t.Run("corrupt data page", func(t *testing.T) {
+		pgr, filename := newPager(t)
+		corrupt(t, filename, data.BeginFreeBlocks+2)
+
+		if err := pgr.Verify(); !errors.Is(err, data.ErrChecksumMismatch) {
+			t.Fatalf("Failed to verify corrupt page: expected error %s, actual %v", data.ErrChecksumMismatch, err)
+		}
+	})
+
+	t.Run("corrupt meta page", func(t *testing.T) {
+		pgr, filename := newPager(t)
+		corrupt(t, filename, data.DefaultMetaPage)
+		corrupt(t, filename, data.ShadowMetaPage)
+
+		// Both meta slots fail their checksum, one of them is also the
+		// current meta page.
+		if err := pgr.Verify(); !errors.Is(err, data.ErrChecksumMismatch) || problems(err) != 3 {
+			t.Fatalf("Failed to verify corrupt meta pages: expected 3 checksum problems, actual %v", err)
+		}
+	})
+
+	t.Run("corrupt stored freelist", func(t *testing.T) {
+		pgr, filename := newPager(t)
+		corrupt(t, filename, pgr.Meta().Freelist)
+
+		if err := pgr.Verify(); !errors.Is(err, data.ErrChecksumMismatch) || problems(err) != 2 {
+			t.Fatalf("Failed to verify corrupt freelist page: expected 2 checksum problems, actual %v", err)
+		}
+	})
+
+	t.Run("freelist out of bounds", func(t *testing.T) {
+		pgr, _ := newPager(t)
+
+		flist := pgr.Freelist()
+		flist.Released = append(flist.Released, flist.Max, data.DefaultMetaPage)
+
+		if err := pgr.Verify(); !errors.Is(err, data.ErrFreelistCorrupt) || problems(err) != 2 {
+			t.Fatalf("Failed to verify out of bounds releases: expected 2 freelist problems, actual %v", err)
+		}
+	})
+
+	t.Run("freelist duplicates", func(t *testing.T) {
+		pgr, _ := newPager(t)
+
+		flist := pgr.Freelist()
+		flist.Released = append(flist.Released, data.BeginFreeBlocks, data.BeginFreeBlocks)
+
+		if err := pgr.Verify(); !errors.Is(err, data.ErrFreelistCorrupt) || problems(err) != 1 {
+			t.Fatalf("Failed to verify duplicate releases: expected 1 freelist problem, actual %v", err)
+		}
+	})
+
+	t.Run("freelist max", func(t *testing.T) {
+		pgr, _ := newPager(t)
+
+		pgr.Freelist().Max = data.BeginFreeBlocks - 1
+
+		if err := pgr.Verify(); !errors.Is(err, data.ErrFreelistCorrupt) {
+			t.Fatalf("Failed to verify freelist max: expected error %s, actual %v", data.ErrFreelistCorrupt, err)
+		}
+	})
+
+	t.Run("wrong page type", func(t *testing.T) {
+		pgr, _ := newPager(t)
+
+		pg, err := pgr.Read(pgr.Meta().Freelist)
+		if err != nil {
+			t.Fatalf("Failed to read freelist page, with error %s", err)
+		}
+
+		if err := pgr.Write(pg.Clone().WithType(data.PageTypeData)); err != nil {
+			t.Fatalf("Failed to retag freelist page, with error %s", err)
+		}
+
+		if err := pgr.Verify(); !errors.Is(err, data.ErrPageTypeMismatch) || problems(err) != 1 {
+			t.Fatalf("Failed to verify retagged freelist page: expected 1 type problem, actual %v", err)
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package data
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// walRecordHeaderSize covers the transaction id, the page number and the
+// length and checksum of the record payload.
+const walRecordHeaderSize = 8 + 8 + 4 + 4
+
+// walCommitNum marks the record closing a transaction. Its payload is the
+// freelist as of the commit.
+const walCommitNum PageNum = -1
+
+func walPath(path string) string {
+	return path + ".wal"
+}
+
+type walRecord struct {
+	TxID    uint64
+	Num     PageNum
+	Payload []byte
+}
+
+func (rec walRecord) appendTo(b []byte) []byte {
+	var head [walRecordHeaderSize]byte
+	binary.LittleEndian.PutUint64(head[:8], rec.TxID)
+	binary.LittleEndian.PutUint64(head[8:16], uint64(rec.Num))
+	binary.LittleEndian.PutUint32(head[16:20], uint32(len(rec.Payload)))
+
+	sum := crc32.Update(crc32.Checksum(head[:20], castagnoli), castagnoli, rec.Payload)
+	binary.LittleEndian.PutUint32(head[20:24], sum)
+
+	return append(append(b, head[:]...), rec.Payload...)
+}
+
+// decodeWALRecords decodes records up to the first incomplete or damaged
+// one, which is where a crash during logging left the log.
+func decodeWALRecords(b []byte) []walRecord {
+	var recs []walRecord
+	for len(b) >= walRecordHeaderSize {
+		size := int(binary.LittleEndian.Uint32(b[16:20]))
+		if len(b) < walRecordHeaderSize+size {
+			break
+		}
+
+		payload := b[walRecordHeaderSize : walRecordHeaderSize+size]
+		sum := crc32.Update(crc32.Checksum(b[:20], castagnoli), castagnoli, payload)
+		if sum != binary.LittleEndian.Uint32(b[20:24]) {
+			break
+		}
+
+		recs = append(recs, walRecord{
+			TxID:    binary.LittleEndian.Uint64(b[:8]),
+			Num:     PageNum(binary.LittleEndian.Uint64(b[8:16])),
+			Payload: payload,
+		})
+		b = b[walRecordHeaderSize+size:]
+	}
+
+	return recs
+}
+
+// committedWAL returns the records of the transaction in the log, up to
+// and including its commit record. It returns nil when the log holds no
+// committed transaction.
+func committedWAL(recs []walRecord) []walRecord {
+	for i, rec := range recs {
+		if rec.TxID != recs[0].TxID {
+			return nil
+		}
+		if rec.Num == walCommitNum {
+			return recs[:i+1]
+		}
+	}
+
+	return nil
+}
+
+// wal is the write-ahead log of a file backed pager. It holds at most one
+// transaction, which is logged and synced before any of its pages reach
+// the store.
+type wal struct {
+	f *os.File
+}
+
+// openWAL opens the log of the store at path, creating it when it does not
+// exist. A log it creates has its directory entry synced with sync, or a
+// crash could lose the log of a commit reported durable.
+func openWAL(path string, perm os.FileMode, sync bool) (*wal, error) {
+	exists, err := isFsEntryExists(walPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("open wal: %w", err)
+	}
+
+	f, err := os.OpenFile(walPath(path), os.O_RDWR|os.O_CREATE, perm)
+	if err != nil {
+		return nil, fmt.Errorf("open wal: %w", err)
+	}
+
+	if !exists && sync {
+		if err := syncDir(walPath(path)); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("open wal: %w", err)
+		}
+	}
+
+	return &wal{f: f}, nil
+}
+
+func (w *wal) log(recs []walRecord, sync bool) error {
+	var b []byte
+	for _, rec := range recs {
+		b = rec.appendTo(b)
+	}
+
+	if err := w.f.Truncate(0); err != nil {
+		return fmt.Errorf("log wal: %w", err)
+	}
+
+	if _, err := w.f.WriteAt(b, 0); err != nil {
+		return fmt.Errorf("log wal: %w", err)
+	}
+
+	if sync {
+		if err := w.f.Sync(); err != nil {
+			return fmt.Errorf("log wal: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (w *wal) records() ([]walRecord, error) {
+	b, err := io.ReadAll(io.NewSectionReader(w.f, 0, 1<<62))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("read wal: %w", err)
+	}
+
+	return decodeWALRecords(b), nil
+}
+
+func (w *wal) reset(sync bool) error {
+	if err := w.f.Truncate(0); err != nil {
+		return fmt.Errorf("reset wal: %w", err)
+	}
+
+	if sync {
+		if err := w.f.Sync(); err != nil {
+			return fmt.Errorf("reset wal: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (w *wal) close() error {
+	return w.f.Close()
+}
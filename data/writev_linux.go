@@ -0,0 +1,27 @@
+//go:build linux
+
+package data
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func pwritev(f *os.File, bufs [][]byte, off int64) error {
+	total := 0
+	for _, b := range bufs {
+		total += len(b)
+	}
+
+	n, err := unix.Pwritev(int(f.Fd()), bufs, off)
+	if err != nil {
+		return fmt.Errorf("pwritev(off=%d): %w", off, err)
+	}
+	if n != total {
+		return fmt.Errorf("pwritev(off=%d): wrote %d of %d bytes: %w", off, n, total, ErrShortWrite)
+	}
+
+	return nil
+}
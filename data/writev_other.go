@@ -0,0 +1,11 @@
+//go:build !linux
+
+package data
+
+import (
+	"os"
+)
+
+func pwritev(_ *os.File, _ [][]byte, _ int64) error {
+	return errVectoredUnsupported
+}
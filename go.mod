@@ -1,3 +1,5 @@
 module github.com/protomem/embedstore
 
 go 1.23.1
+
+require golang.org/x/sys v0.35.0
@@ -4,11 +4,41 @@ import (
 	"math/rand/v2"
 )
 
+// Rand is a deterministic generator: two of them created with the same
+// seed return the same sequence. It is not safe for concurrent use.
+type Rand struct {
+	r *rand.Rand
+}
+
+func NewRand(seed uint64) *Rand {
+	return &Rand{r: rand.New(rand.NewPCG(seed, seed))}
+}
+
+func (r *Rand) Bool() bool {
+	return r.r.IntN(2) == 1
+}
+
+// Range is the package-level Range drawing from r.
+func (r *Rand) Range(min, max int) int {
+	return rangeN(r.r.IntN, min, max)
+}
+
+// Bool, like Range, draws from the shared source of math/rand/v2, which
+// is randomly seeded and safe for concurrent use.
 func Bool() bool {
 	v := rand.IntN(2)
 	return v == 1
 }
 
+// Range returns a random number in the half-open range [min, max). When
+// the range holds at most one number, Range returns min.
 func Range(min, max int) int {
-	return rand.IntN(max-min) + min
+	return rangeN(rand.IntN, min, max)
+}
+
+func rangeN(intN func(int) int, min, max int) int {
+	if max <= min+1 {
+		return min
+	}
+	return intN(max-min) + min
 }
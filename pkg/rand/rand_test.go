@@ -0,0 +1,49 @@
+package rand_test
+
+import (
+	"testing"
+
+	"github.com/protomem/embedstore/pkg/rand"
+)
+
+func TestRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max int
+		low, hi  int
+	}{
+		{name: "normal", min: 3, max: 10, low: 3, hi: 10},
+		{name: "negative", min: -5, max: 5, low: -5, hi: 5},
+		{name: "single", min: 4, max: 5, low: 4, hi: 5},
+		{name: "min equals max", min: 5, max: 5, low: 5, hi: 6},
+		{name: "min greater than max", min: 10, max: 3, low: 10, hi: 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				v := rand.Range(tt.min, tt.max)
+				if v < tt.low || v >= tt.hi {
+					t.Fatalf(
+						"Failed to check range(%d, %d): expected [%d, %d), actual %d",
+						tt.min, tt.max, tt.low, tt.hi, v,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestRand_Seed(t *testing.T) {
+	a, b := rand.NewRand(42), rand.NewRand(42)
+
+	for i := 0; i < 1000; i++ {
+		if va, vb := a.Range(0, 1<<20), b.Range(0, 1<<20); va != vb {
+			t.Fatalf("Failed to compare range #%d: expected %d, actual %d", i, va, vb)
+		}
+
+		if va, vb := a.Bool(), b.Bool(); va != vb {
+			t.Fatalf("Failed to compare bool #%d: expected %t, actual %t", i, va, vb)
+		}
+	}
+}